@@ -0,0 +1,335 @@
+package persistent
+
+// AggregateQueue is a persistent queue that also maintains a summary of its elements, computed with a Monoid. The
+// summary of the whole queue is available in O(1) with Aggregate, which makes an AggregateQueue a good fit for sliding
+// window computations, such as the maximum or sum of the last N values. Combine need not be commutative: elements are
+// always combined from front to back.
+//
+// An AggregateQueue may have a capacity. Enqueueing onto a full queue first drops the element at the front.
+//
+// Like Queue, an AggregateQueue is immutable, uses structural sharing, and may be accessed from multiple go-routines
+// without synchronization. A nil *AggregateQueue is a valid empty queue for all read-only operations, but queues must
+// be created with NewAggregateQueue before they can be updated. Every operation is worst-case O(1), even when the same
+// version is dequeued repeatedly.
+//
+// The implementation is the same Hood-Melville real-time queue as Queue, with stack nodes that cache a summary of the
+// node and everything below it. Front stack nodes summarize the elements behind them in the queue, and rear stack
+// nodes the elements ahead of them, so both summaries can be extended in O(1) by a push. While the rear stack is being
+// rotated onto the front, its summary is kept aside. Measure may be called more than once for the same element.
+type AggregateQueue[T any, S any] struct {
+	front     *aggregateStack[T, S]
+	frontSize int // Includes elements still being rotated onto front.
+	rear      *aggregateStack[T, S]
+	rotation  aggregateRotation[T, S]
+	monoid    *Monoid[T, S]
+	capacity  int
+}
+
+// aggregateStack is a node of one of the stacks of an AggregateQueue. summary covers the node and every node below
+// it, in queue order.
+type aggregateStack[T any, S any] struct {
+	next    *aggregateStack[T, S]
+	value   T
+	size    int
+	summary S
+}
+
+// aggregateRotation is the queueRotation of an AggregateQueue. result is built like a front stack, so its summaries are
+// correct once the rotation is done. The summaries of reversedFront are not used. rearSummary is the summary of the
+// whole rear stack being rotated.
+type aggregateRotation[T any, S any] struct {
+	phase         rotationPhase
+	valid         int
+	front         *aggregateStack[T, S]
+	reversedFront *aggregateStack[T, S]
+	rear          *aggregateStack[T, S]
+	rearSummary   S
+	result        *aggregateStack[T, S]
+}
+
+// AggregateQueueIterator defines an iterator over an AggregateQueue.
+type AggregateQueueIterator[T any, S any] struct {
+	segments [4]aggregateSegment[T, S]
+	count    int
+	next     int // The index of the next segment to visit.
+
+	// The rest of the segment being visited is in either stack or reversed. Elements of reversed are visited from the
+	// end of the slice.
+	stack    *aggregateStack[T, S]
+	reversed []T
+
+	current T
+	valid   bool
+}
+
+// aggregateSegment is the queueSegment of an AggregateQueue.
+type aggregateSegment[T any, S any] struct {
+	stack    *aggregateStack[T, S]
+	skip     int
+	reversed bool
+}
+
+// NewAggregateQueue returns an empty queue that summarizes its elements with m. If capacity is positive, the queue
+// never holds more than capacity elements; otherwise it is unbounded.
+func NewAggregateQueue[T any, S any](m Monoid[T, S], capacity int) *AggregateQueue[T, S] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &AggregateQueue[T, S]{monoid: &m, capacity: capacity}
+}
+
+// Enqueue returns a new queue with 'value' added to the end. If the queue is full, the element at the front is
+// dropped. This is O(1).
+func (q *AggregateQueue[T, S]) Enqueue(value T) *AggregateQueue[T, S] {
+	if q == nil || q.monoid == nil {
+		panic("enqueue: aggregate queues must be created with NewAggregateQueue")
+	}
+	ret := *q
+	if ret.capacity > 0 && ret.Size() >= ret.capacity {
+		ret.pop()
+	}
+	ret.rear = ret.rear.pushRear(ret.monoid, value)
+	ret.balance()
+	return &ret
+}
+
+// Dequeue removes the element at the front of the queue, and returns the dequeued value along with a new queue with
+// the value removed. If the queue is empty, the returned 'value' will be the zero value of T and q is returned. This
+// is O(1).
+func (q *AggregateQueue[T, S]) Dequeue() (value T, queue *AggregateQueue[T, S]) {
+	if q.IsEmpty() {
+		return value, q
+	}
+	ret := *q
+	value = ret.pop()
+	return value, &ret
+}
+
+// pop removes the element at the front of the non-empty queue q in place and returns it.
+func (q *AggregateQueue[T, S]) pop() T {
+	value := q.front.value
+	q.front = q.front.next
+	q.frontSize--
+	q.rotation.invalidate()
+	q.balance()
+	return value
+}
+
+// balance starts a new rotation if the rear stack has grown larger than the front, and then advances the current
+// rotation by two steps. See Queue.balance.
+func (q *AggregateQueue[T, S]) balance() {
+	if q.rear.Size() > q.frontSize {
+		q.rotation = aggregateRotation[T, S]{
+			phase:       rotationReversing,
+			front:       q.front,
+			rear:        q.rear,
+			rearSummary: q.rear.summary,
+		}
+		q.frontSize += q.rear.Size()
+		q.rear = nil
+	}
+
+	q.rotation.step(q.monoid)
+	q.rotation.step(q.monoid)
+	if q.rotation.phase == rotationDone {
+		q.front = q.rotation.result
+		q.rotation = aggregateRotation[T, S]{}
+	}
+}
+
+// step performs a single, constant time, step of the rotation. See queueRotation.step.
+func (r *aggregateRotation[T, S]) step(m *Monoid[T, S]) {
+	switch r.phase {
+	case rotationReversing:
+		if r.front != nil {
+			var unused S
+			r.reversedFront = r.reversedFront.push(r.front.value, unused)
+			r.front = r.front.next
+			r.result = r.result.pushFront(m, r.rear.value)
+			r.rear = r.rear.next
+			r.valid++
+		} else {
+			r.result = r.result.pushFront(m, r.rear.value)
+			r.rear = nil
+			r.phase = rotationAppending
+		}
+	case rotationAppending:
+		if r.valid == 0 {
+			r.phase = rotationDone
+		} else {
+			r.result = r.result.pushFront(m, r.reversedFront.value)
+			r.reversedFront = r.reversedFront.next
+			r.valid--
+		}
+	}
+}
+
+// invalidate records that an element was dequeued from the front while the rotation was in progress. See
+// queueRotation.invalidate.
+func (r *aggregateRotation[T, S]) invalidate() {
+	switch r.phase {
+	case rotationReversing:
+		r.valid--
+	case rotationAppending:
+		if r.valid == 0 {
+			r.result = r.result.next
+			r.phase = rotationDone
+		} else {
+			r.valid--
+		}
+	}
+}
+
+// Top returns the element at the front of the queue without removing it. If the queue is empty, the returned value
+// will be the zero value of T. This is O(1).
+func (q *AggregateQueue[T, S]) Top() T {
+	if q.IsEmpty() {
+		var zv T
+		return zv
+	}
+	return q.front.value
+}
+
+// Aggregate returns the combined measure of every element in the queue, from front to back, or the identity of the
+// monoid if the queue is empty. Aggregate is O(1).
+func (q *AggregateQueue[T, S]) Aggregate() S {
+	if q == nil || q.monoid == nil {
+		var ret S
+		return ret
+	}
+	m := q.monoid
+	ret := q.front.summaryOr(m.Identity)
+	if q.rotation.phase != rotationIdle {
+		ret = m.Combine(ret, q.rotation.rearSummary)
+	}
+	if q.rear != nil {
+		ret = m.Combine(ret, q.rear.summary)
+	}
+	return ret
+}
+
+// Size returns the number of elements in the queue.
+func (q *AggregateQueue[T, S]) Size() int {
+	if q == nil {
+		return 0
+	}
+	return q.frontSize + q.rear.Size()
+}
+
+// IsEmpty returns true iif the queue is empty.
+func (q *AggregateQueue[T, S]) IsEmpty() bool {
+	return q.Size() == 0
+}
+
+// Capacity returns the maximum number of elements the queue can hold, or 0 if it is unbounded.
+func (q *AggregateQueue[T, S]) Capacity() int {
+	if q == nil {
+		return 0
+	}
+	return q.capacity
+}
+
+// Iter returns an iterator over the elements of the queue from front to back.
+func (q *AggregateQueue[T, S]) Iter() Iterator[T] {
+	ret := &AggregateQueueIterator[T, S]{}
+	ret.segments, ret.count = q.segments()
+	return ret
+}
+
+// segments returns the runs of elements making up q, from front to back. See Queue.segments.
+func (q *AggregateQueue[T, S]) segments() (ret [4]aggregateSegment[T, S], count int) {
+	if q.IsEmpty() {
+		return ret, 0
+	}
+	add := func(seg aggregateSegment[T, S]) {
+		if seg.stack.Size() > seg.skip {
+			ret[count] = seg
+			count++
+		}
+	}
+
+	add(aggregateSegment[T, S]{stack: q.front})
+	rotating := q.frontSize - q.front.Size()
+	switch r := &q.rotation; r.phase {
+	case rotationReversing:
+		add(aggregateSegment[T, S]{stack: r.rear, reversed: true})
+		add(aggregateSegment[T, S]{stack: r.result})
+	case rotationAppending:
+		add(aggregateSegment[T, S]{stack: r.result, skip: r.result.Size() - rotating})
+	}
+	add(aggregateSegment[T, S]{stack: q.rear, reversed: true})
+	return ret, count
+}
+
+func (i *AggregateQueueIterator[T, S]) Next() bool {
+	for {
+		if n := len(i.reversed); n != 0 {
+			i.current, i.reversed = i.reversed[n-1], i.reversed[:n-1]
+			i.valid = true
+			return true
+		}
+		if i.stack != nil {
+			i.current, i.stack = i.stack.value, i.stack.next
+			i.valid = true
+			return true
+		}
+		if i.next == i.count {
+			var zv T
+			i.current, i.valid = zv, false
+			return false
+		}
+
+		seg := i.segments[i.next]
+		i.next++
+		if seg.reversed {
+			for s := seg.stack; s != nil; s = s.next {
+				i.reversed = append(i.reversed, s.value)
+			}
+		} else {
+			i.stack = seg.stack
+			for k := 0; k < seg.skip; k++ {
+				i.stack = i.stack.next
+			}
+		}
+	}
+}
+
+func (i *AggregateQueueIterator[T, S]) Current() T {
+	if !i.valid {
+		panic("invalid iterator position")
+	}
+	return i.current
+}
+
+func (s *aggregateStack[T, S]) push(value T, summary S) *aggregateStack[T, S] {
+	return &aggregateStack[T, S]{
+		next:    s,
+		value:   value,
+		size:    s.Size() + 1,
+		summary: summary,
+	}
+}
+
+// pushFront pushes 'value' onto a front stack, where it comes before the elements below it.
+func (s *aggregateStack[T, S]) pushFront(m *Monoid[T, S], value T) *aggregateStack[T, S] {
+	return s.push(value, m.Combine(m.Measure(value), s.summaryOr(m.Identity)))
+}
+
+// pushRear pushes 'value' onto a rear stack, where it comes after the elements below it.
+func (s *aggregateStack[T, S]) pushRear(m *Monoid[T, S], value T) *aggregateStack[T, S] {
+	return s.push(value, m.Combine(s.summaryOr(m.Identity), m.Measure(value)))
+}
+
+func (s *aggregateStack[T, S]) Size() int {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+func (s *aggregateStack[T, S]) summaryOr(identity S) S {
+	if s == nil {
+		return identity
+	}
+	return s.summary
+}
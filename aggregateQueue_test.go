@@ -0,0 +1,167 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func maxMonoid() Monoid[int, int] {
+	return Monoid[int, int]{
+		Identity: math.MinInt,
+		Measure:  func(v int) int { return v },
+		Combine: func(a, b int) int {
+			if a > b {
+				return a
+			}
+			return b
+		},
+	}
+}
+
+// concatMonoid concatenates elements, so that it detects elements combined out of order.
+func concatMonoid() Monoid[int, []int] {
+	return Monoid[int, []int]{
+		Measure: func(v int) []int { return []int{v} },
+		Combine: func(a, b []int) []int { return append(append([]int(nil), a...), b...) },
+	}
+}
+
+// countingMonoid wraps m, counting its calls to Measure in 'calls'.
+func countingMonoid[T any, S any](m Monoid[T, S], calls *int) Monoid[T, S] {
+	measure := m.Measure
+	m.Measure = func(value T) S {
+		*calls++
+		return measure(value)
+	}
+	return m
+}
+
+// requireValidAggregateQueue checks the invariants that make every operation on q O(1).
+func requireValidAggregateQueue[T any, S any](t *testing.T, q *AggregateQueue[T, S]) {
+	t.Helper()
+	if q.IsEmpty() {
+		return
+	}
+	require.LessOrEqual(t, q.rear.Size(), q.frontSize)
+	require.NotNil(t, q.front)
+	require.NotEqual(t, rotationDone, q.rotation.phase)
+}
+
+func aggregateQueueElems[T any, S any](q *AggregateQueue[T, S]) []T {
+	var ret []T
+	it := q.Iter()
+	for it.Next() {
+		ret = append(ret, it.Current())
+	}
+	return ret
+}
+
+func TestAggregateQueue(t *testing.T) {
+	q := NewAggregateQueue(maxMonoid(), 0)
+	require.True(t, q.IsEmpty())
+	require.Equal(t, math.MinInt, q.Aggregate())
+
+	q = q.Enqueue(3).Enqueue(7).Enqueue(5)
+	require.Equal(t, 3, q.Size())
+	require.Equal(t, 3, q.Top())
+	require.Equal(t, 7, q.Aggregate())
+	require.Equal(t, []int{3, 7, 5}, aggregateQueueElems(q))
+
+	v, rest := q.Dequeue()
+	require.Equal(t, 3, v)
+	v, rest = rest.Dequeue()
+	require.Equal(t, 7, v)
+	require.Equal(t, 5, rest.Aggregate())
+	require.Equal(t, 7, q.Aggregate())
+
+	v, rest = rest.Dequeue()
+	require.Equal(t, 5, v)
+	require.True(t, rest.IsEmpty())
+	require.Equal(t, math.MinInt, rest.Aggregate())
+	require.Equal(t, 1, rest.Enqueue(1).Aggregate())
+}
+
+func TestAggregateQueueCapacity(t *testing.T) {
+	q := NewAggregateQueue(maxMonoid(), 3)
+	require.Equal(t, 3, q.Capacity())
+	values := []int{5, 1, 2, 0, 9, 3, 4, 1, 1}
+	expected := []int{5, 5, 5, 2, 9, 9, 9, 4, 4}
+	for i, v := range values {
+		q = q.Enqueue(v)
+		require.Equal(t, expected[i], q.Aggregate())
+		require.LessOrEqual(t, q.Size(), 3)
+	}
+	require.Equal(t, []int{4, 1, 1}, aggregateQueueElems(q))
+	require.Equal(t, 0, NewAggregateQueue(maxMonoid(), -1).Capacity())
+}
+
+func TestAggregateQueueOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(25))
+	queues := []*AggregateQueue[int, []int]{NewAggregateQueue(concatMonoid(), 0)}
+	for i := 0; i < 2000; i++ {
+		q := queues[r.Intn(len(queues))]
+		if r.Intn(3) == 0 {
+			_, q = q.Dequeue()
+		} else {
+			q = q.Enqueue(i)
+		}
+		requireValidAggregateQueue(t, q)
+		elems := aggregateQueueElems(q)
+		require.Equal(t, elems, q.Aggregate())
+		require.Equal(t, len(elems), q.Size())
+		if len(elems) != 0 {
+			require.Equal(t, elems[0], q.Top())
+		}
+		queues = append(queues, q)
+	}
+}
+
+func TestAggregateQueueNil(t *testing.T) {
+	var q *AggregateQueue[int, int]
+	require.True(t, q.IsEmpty())
+	require.Equal(t, 0, q.Aggregate())
+	require.Equal(t, 0, q.Top())
+	require.False(t, q.Iter().Next())
+	v, rest := q.Dequeue()
+	require.Equal(t, 0, v)
+	require.Nil(t, rest)
+	require.PanicsWithValue(t, "enqueue: aggregate queues must be created with NewAggregateQueue", func() {
+		q.Enqueue(1)
+	})
+}
+
+func TestAggregateQueueSharedSnapshot(t *testing.T) {
+	calls := 0
+	q := NewAggregateQueue(countingMonoid(maxMonoid(), &calls), 0)
+	for i := 0; i < 1000; i++ {
+		q = q.Enqueue(i)
+		requireValidAggregateQueue(t, q)
+	}
+
+	// Dequeuing the same snapshot over and over must not repeat any O(n) work.
+	for i := 0; i < 1000; i++ {
+		calls = 0
+		v, rest := q.Dequeue()
+		require.Equal(t, 0, v)
+		require.Equal(t, 999, rest.Aggregate())
+		require.Equal(t, 999, rest.Size())
+		requireValidAggregateQueue(t, rest)
+		require.LessOrEqual(t, calls, 2)
+	}
+}
+
+func TestAggregateQueueCapacityIsConstantTime(t *testing.T) {
+	calls := 0
+	q := NewAggregateQueue(countingMonoid(maxMonoid(), &calls), 1000)
+	for i := 0; i < 5000; i++ {
+		calls = 0
+		q = q.Enqueue(i)
+		requireValidAggregateQueue(t, q)
+		require.LessOrEqual(t, calls, 5)
+		require.Equal(t, i, q.Aggregate())
+	}
+	require.Equal(t, 1000, q.Size())
+	require.Equal(t, 4000, q.Top())
+}
@@ -0,0 +1,354 @@
+package persistent
+
+// AugmentedTreeEx is a persistent AVL tree whose nodes also cache a summary of their subtree. Summaries are computed
+// with a Monoid, which measures each entry and combines the measures in key order. They allow aggregates over any key
+// range to be computed in O(log(N)) with Aggregate.
+//
+// Like TreeEx, an AugmentedTreeEx is immutable, uses structural sharing, and may be accessed from multiple go-routines
+// without synchronization. A nil *AugmentedTreeEx is a valid empty tree for all read-only operations, but trees must be
+// created with NewAugmentedTreeEx before they can be updated.
+type AugmentedTreeEx[K Ordered[K], V any, S any] struct {
+	root   *augmentedNodeEx[K, V, S]
+	monoid *Monoid[Pair[K, V], S]
+}
+
+type augmentedNodeEx[K Ordered[K], V any, S any] struct {
+	left    *augmentedNodeEx[K, V, S]
+	right   *augmentedNodeEx[K, V, S]
+	key     K
+	value   V
+	size    int
+	height  int
+	summary S
+}
+
+// AugmentedTreeExIterator defines an iterator over an AugmentedTreeEx.
+type AugmentedTreeExIterator[K Ordered[K], V any, S any] struct {
+	stack   []*augmentedNodeEx[K, V, S]
+	current *augmentedNodeEx[K, V, S]
+}
+
+// NewAugmentedTreeEx returns an empty tree that summarizes its entries with m.
+func NewAugmentedTreeEx[K Ordered[K], V any, S any](m Monoid[Pair[K, V], S]) *AugmentedTreeEx[K, V, S] {
+	return &AugmentedTreeEx[K, V, S]{monoid: &m}
+}
+
+// Update returns a new tree with the value for 'key' set to 'value'.
+func (t *AugmentedTreeEx[K, V, S]) Update(key K, value V) *AugmentedTreeEx[K, V, S] {
+	if t == nil || t.monoid == nil {
+		panic("update: augmented trees must be created with NewAugmentedTreeEx")
+	}
+	return &AugmentedTreeEx[K, V, S]{root: t.root.update(t.monoid, key, value), monoid: t.monoid}
+}
+
+// Delete returns a new tree with the entry for 'key' removed. If there is no such entry, t is returned unchanged.
+func (t *AugmentedTreeEx[K, V, S]) Delete(key K) *AugmentedTreeEx[K, V, S] {
+	if t == nil {
+		return nil
+	}
+	root, removed := t.root.delete(t.monoid, key)
+	if !removed {
+		return t
+	}
+	return &AugmentedTreeEx[K, V, S]{root: root, monoid: t.monoid}
+}
+
+// Find returns the value associated with key in the tree. Will return a zero value if no such item exists.
+func (t *AugmentedTreeEx[K, V, S]) Find(key K) V {
+	value, _ := t.FindOpt(key)
+	return value
+}
+
+// FindOpt returns the value associated with key in the tree. Returns true if found; otherwise false.
+func (t *AugmentedTreeEx[K, V, S]) FindOpt(key K) (V, bool) {
+	n := t.node()
+	for n != nil {
+		if n.key.Less(key) {
+			n = n.right
+		} else if key.Less(n.key) {
+			n = n.left
+		} else {
+			return n.value, true
+		}
+	}
+	var ret V
+	return ret, false
+}
+
+// Contains returns true if the tree contains the key.
+func (t *AugmentedTreeEx[K, V, S]) Contains(key K) bool {
+	_, found := t.FindOpt(key)
+	return found
+}
+
+// Size returns the number of entries in the tree.
+func (t *AugmentedTreeEx[K, V, S]) Size() int {
+	return t.node().Size()
+}
+
+// IsEmpty returns true iif the tree is empty.
+func (t *AugmentedTreeEx[K, V, S]) IsEmpty() bool {
+	return t.node() == nil
+}
+
+// Summary returns the combined measure of every entry in the tree, or the identity of the monoid if the tree is
+// empty. Summary is O(1).
+func (t *AugmentedTreeEx[K, V, S]) Summary() S {
+	if t == nil || t.root == nil {
+		return t.identity()
+	}
+	return t.root.summary
+}
+
+// Aggregate returns the combined measure of every entry with a key k such that lo <= k <= hi, in key order. Use
+// 'bounds' to exclude either endpoint from the range. If the range is empty, the identity of the monoid is returned.
+// Aggregate is O(log(N)).
+func (t *AugmentedTreeEx[K, V, S]) Aggregate(lo K, hi K, bounds Bounds) S {
+	n := t.node()
+	for n != nil {
+		if !aboveLowEx(n.key, lo, bounds) {
+			n = n.right
+		} else if !belowHighEx(n.key, hi, bounds) {
+			n = n.left
+		} else {
+			break
+		}
+	}
+	if n == nil {
+		return t.identity()
+	}
+
+	m := t.monoid
+	ret := m.Measure(Pair[K, V]{Key: n.key, Value: n.value})
+
+	// Every entry of the left subtree is below hi, so only lo needs to be checked on the way down.
+	for l := n.left; l != nil; {
+		if aboveLowEx(l.key, lo, bounds) {
+			ret = m.Combine(m.Combine(m.Measure(Pair[K, V]{Key: l.key, Value: l.value}), l.right.summaryOr(m)), ret)
+			l = l.left
+		} else {
+			l = l.right
+		}
+	}
+
+	for r := n.right; r != nil; {
+		if belowHighEx(r.key, hi, bounds) {
+			ret = m.Combine(ret, m.Combine(r.left.summaryOr(m), m.Measure(Pair[K, V]{Key: r.key, Value: r.value})))
+			r = r.right
+		} else {
+			r = r.left
+		}
+	}
+	return ret
+}
+
+// Iter returns an in-order iterator for the tree.
+func (t *AugmentedTreeEx[K, V, S]) Iter() Iterator[Pair[K, V]] {
+	ret := &AugmentedTreeExIterator[K, V, S]{}
+	ret.pushLeft(t.node())
+	return ret
+}
+
+func (t *AugmentedTreeEx[K, V, S]) node() *augmentedNodeEx[K, V, S] {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+func (t *AugmentedTreeEx[K, V, S]) identity() S {
+	if t == nil || t.monoid == nil {
+		var ret S
+		return ret
+	}
+	return t.monoid.Identity
+}
+
+// aboveLowEx returns true if key is within the lower bound of a range.
+func aboveLowEx[K Ordered[K]](key K, lo K, bounds Bounds) bool {
+	if bounds.excludesLow() {
+		return lo.Less(key)
+	}
+	return !key.Less(lo)
+}
+
+// belowHighEx returns true if key is within the upper bound of a range.
+func belowHighEx[K Ordered[K]](key K, hi K, bounds Bounds) bool {
+	if bounds.excludesHigh() {
+		return key.Less(hi)
+	}
+	return !hi.Less(key)
+}
+
+func newAugmentedNodeEx[K Ordered[K], V any, S any](
+	m *Monoid[Pair[K, V], S],
+	left *augmentedNodeEx[K, V, S],
+	right *augmentedNodeEx[K, V, S],
+	key K,
+	value V,
+) *augmentedNodeEx[K, V, S] {
+	return &augmentedNodeEx[K, V, S]{
+		left:    left,
+		right:   right,
+		key:     key,
+		value:   value,
+		size:    left.Size() + right.Size() + 1,
+		height:  max(left.Height(), right.Height()) + 1,
+		summary: m.Combine(m.Combine(left.summaryOr(m), m.Measure(Pair[K, V]{Key: key, Value: value})), right.summaryOr(m)),
+	}
+}
+
+func (n *augmentedNodeEx[K, V, S]) Size() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *augmentedNodeEx[K, V, S]) Height() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+// summaryOr returns the summary of n, or the identity of m if n is empty.
+func (n *augmentedNodeEx[K, V, S]) summaryOr(m *Monoid[Pair[K, V], S]) S {
+	if n == nil {
+		return m.Identity
+	}
+	return n.summary
+}
+
+func (n *augmentedNodeEx[K, V, S]) update(m *Monoid[Pair[K, V], S], key K, value V) *augmentedNodeEx[K, V, S] {
+	if n == nil {
+		return newAugmentedNodeEx(m, nil, nil, key, value)
+	}
+
+	if n.key.Less(key) {
+		return newAugmentedNodeEx(m, n.left, n.right.update(m, key, value), n.key, n.value).rebalance(m)
+	}
+
+	if key.Less(n.key) {
+		return newAugmentedNodeEx(m, n.left.update(m, key, value), n.right, n.key, n.value).rebalance(m)
+	}
+
+	return newAugmentedNodeEx(m, n.left, n.right, key, value)
+}
+
+func (n *augmentedNodeEx[K, V, S]) delete(m *Monoid[Pair[K, V], S], key K) (*augmentedNodeEx[K, V, S], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if n.key.Less(key) {
+		right, removed := n.right.delete(m, key)
+		if !removed {
+			return n, false
+		}
+		return newAugmentedNodeEx(m, n.left, right, n.key, n.value).rebalance(m), true
+	}
+
+	if key.Less(n.key) {
+		left, removed := n.left.delete(m, key)
+		if !removed {
+			return n, false
+		}
+		return newAugmentedNodeEx(m, left, n.right, n.key, n.value).rebalance(m), true
+	}
+
+	if n.left == nil {
+		return n.right, true
+	}
+	if n.right == nil {
+		return n.left, true
+	}
+	left, most := n.left.removeMost(m)
+	return newAugmentedNodeEx(m, left, n.right, most.key, most.value).rebalance(m), true
+}
+
+// removeMost returns n with its greatest entry removed, along with the node holding that entry.
+func (n *augmentedNodeEx[K, V, S]) removeMost(
+	m *Monoid[Pair[K, V], S],
+) (*augmentedNodeEx[K, V, S], *augmentedNodeEx[K, V, S]) {
+	if n.right == nil {
+		return n.left, n
+	}
+	right, most := n.right.removeMost(m)
+	return newAugmentedNodeEx(m, n.left, right, n.key, n.value).rebalance(m), most
+}
+
+func (n *augmentedNodeEx[K, V, S]) balanceFactor() int {
+	if n == nil {
+		return 0
+	}
+	return n.right.Height() - n.left.Height()
+}
+
+func (n *augmentedNodeEx[K, V, S]) rebalance(m *Monoid[Pair[K, V], S]) *augmentedNodeEx[K, V, S] {
+	balance := n.balanceFactor()
+	if abs(balance) <= 1 {
+		return n
+	}
+
+	if balance > 0 {
+		if n.right.balanceFactor() >= 0 {
+			return n.rotateLeft(m)
+		}
+		return newAugmentedNodeEx(m, n.left, n.right.rotateRight(m), n.key, n.value).rotateLeft(m)
+	}
+
+	if n.left.balanceFactor() <= 0 {
+		return n.rotateRight(m)
+	}
+	return newAugmentedNodeEx(m, n.left.rotateLeft(m), n.right, n.key, n.value).rotateRight(m)
+}
+
+func (n *augmentedNodeEx[K, V, S]) rotateLeft(m *Monoid[Pair[K, V], S]) *augmentedNodeEx[K, V, S] {
+	return newAugmentedNodeEx(
+		m,
+		newAugmentedNodeEx(m, n.left, n.right.left, n.key, n.value),
+		n.right.right,
+		n.right.key,
+		n.right.value,
+	)
+}
+
+func (n *augmentedNodeEx[K, V, S]) rotateRight(m *Monoid[Pair[K, V], S]) *augmentedNodeEx[K, V, S] {
+	return newAugmentedNodeEx(
+		m,
+		n.left.left,
+		newAugmentedNodeEx(m, n.left.right, n.right, n.key, n.value),
+		n.left.key,
+		n.left.value,
+	)
+}
+
+func (i *AugmentedTreeExIterator[K, V, S]) Next() bool {
+	if i.current != nil {
+		i.pushLeft(i.current.right)
+		i.current = nil
+	}
+
+	if len(i.stack) == 0 {
+		return false
+	}
+	i.current = i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+	return true
+}
+
+func (i *AugmentedTreeExIterator[K, V, S]) Current() Pair[K, V] {
+	if i.current == nil {
+		panic("invalid iterator position")
+	}
+	return Pair[K, V]{Key: i.current.key, Value: i.current.value}
+}
+
+// pushLeft pushes n and the left spine below it onto the stack.
+func (i *AugmentedTreeExIterator[K, V, S]) pushLeft(n *augmentedNodeEx[K, V, S]) {
+	for n != nil {
+		i.stack = append(i.stack, n)
+		n = n.left
+	}
+}
@@ -0,0 +1,81 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAugmentedTreeExAggregate(t *testing.T) {
+	tree := NewAugmentedTreeEx(keysMonoid[Int]())
+	for i := 0; i < 50; i += 2 {
+		tree = tree.Update(Int(i), i)
+	}
+
+	require.Equal(t, []Int{10, 12, 14, 16, 18, 20}, tree.Aggregate(10, 20, Closed))
+	require.Equal(t, []Int{12, 14, 16, 18}, tree.Aggregate(10, 20, Open))
+	require.Empty(t, tree.Aggregate(20, 10, Closed))
+
+	tree = tree.Delete(Int(14)).Update(Int(15), 15)
+	require.Equal(t, []Int{12, 15, 16, 18}, tree.Aggregate(10, 20, Open))
+	require.Equal(t, 25, tree.Size())
+}
+
+func TestAugmentedTreeExSummary(t *testing.T) {
+	tree := NewAugmentedTreeEx(sumMonoid[Int]())
+	for i := 0; i < 100; i++ {
+		tree = tree.Update(Int(i), i)
+	}
+	require.Equal(t, 4950, tree.Summary())
+	require.Equal(t, 4950-45, tree.Aggregate(10, 1000, Closed))
+
+	for i := 0; i < 100; i++ {
+		tree = tree.Delete(Int(i))
+	}
+	require.True(t, tree.IsEmpty())
+	require.Equal(t, 0, tree.Summary())
+}
+
+func requireValidAugmentedNodeEx[K Ordered[K], V any, S any](
+	t *testing.T,
+	m *Monoid[Pair[K, V], S],
+	n *augmentedNodeEx[K, V, S],
+) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+	requireValidAugmentedNodeEx(t, m, n.left)
+	requireValidAugmentedNodeEx(t, m, n.right)
+	require.LessOrEqual(t, abs(n.balanceFactor()), 1)
+	require.Equal(t, n.left.Size()+n.right.Size()+1, n.size)
+	expected := m.Combine(m.Combine(n.left.summaryOr(m), m.Measure(Pair[K, V]{n.key, n.value})), n.right.summaryOr(m))
+	require.Equal(t, expected, n.summary)
+}
+
+func TestAugmentedTreeExDeleteRotations(t *testing.T) {
+	m := keysMonoid[Int]()
+
+	// A single and a double rotation at the root.
+	tree := NewAugmentedTreeEx(m).Update(2, 2).Update(1, 1).Update(3, 3).Update(4, 4).Delete(1)
+	require.Equal(t, Int(3), tree.root.key)
+	requireValidAugmentedNodeEx(t, tree.monoid, tree.root)
+	require.Equal(t, []Int{2, 3, 4}, tree.Summary())
+
+	tree = NewAugmentedTreeEx(m).Update(3, 3).Update(4, 4).Update(1, 1).Update(2, 2).Delete(4)
+	require.Equal(t, Int(2), tree.root.key)
+	requireValidAugmentedNodeEx(t, tree.monoid, tree.root)
+	require.Equal(t, []Int{1, 2, 3}, tree.Summary())
+
+	tree = NewAugmentedTreeEx(m)
+	var expected []Int
+	for i := 0; i < 64; i++ {
+		tree = tree.Update(Int(i), i)
+		expected = append(expected, Int(i))
+	}
+	for i := 63; i >= 16; i-- {
+		tree = tree.Delete(Int(i))
+		expected = expected[:len(expected)-1]
+		requireValidAugmentedNodeEx(t, tree.monoid, tree.root)
+		require.Equal(t, expected, tree.Summary())
+	}
+}
@@ -0,0 +1,182 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/constraints"
+	"math/rand"
+	"testing"
+)
+
+func sumMonoid[K any]() Monoid[Pair[K, int], int] {
+	return Monoid[Pair[K, int], int]{
+		Measure: func(p Pair[K, int]) int { return p.Value },
+		Combine: func(a, b int) int { return a + b },
+	}
+}
+
+// keysMonoid concatenates keys, so that it detects entries combined out of order.
+func keysMonoid[K any]() Monoid[Pair[K, int], []K] {
+	return Monoid[Pair[K, int], []K]{
+		Measure: func(p Pair[K, int]) []K { return []K{p.Key} },
+		Combine: func(a, b []K) []K { return append(append([]K(nil), a...), b...) },
+	}
+}
+
+func requireValidAugmentedNode[K constraints.Ordered, V any, S any](
+	t *testing.T,
+	m *Monoid[Pair[K, V], S],
+	n *augmentedNode[K, V, S],
+) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+	requireValidAugmentedNode(t, m, n.left)
+	requireValidAugmentedNode(t, m, n.right)
+	require.LessOrEqual(t, abs(n.balanceFactor()), 1)
+	require.Equal(t, max(n.left.Height(), n.right.Height())+1, n.height)
+	require.Equal(t, n.left.Size()+n.right.Size()+1, n.size)
+	expected := m.Combine(m.Combine(n.left.summaryOr(m), m.Measure(Pair[K, V]{n.key, n.value})), n.right.summaryOr(m))
+	require.Equal(t, expected, n.summary)
+}
+
+func TestAugmentedTreeUpdateDelete(t *testing.T) {
+	m := sumMonoid[int]()
+	tree := NewAugmentedTree(m)
+	require.True(t, tree.IsEmpty())
+	require.Equal(t, 0, tree.Summary())
+
+	for i := 0; i < 100; i++ {
+		tree = tree.Update(i, i)
+	}
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, 100, tree.Size())
+	require.Equal(t, 4950, tree.Summary())
+	require.Equal(t, 42, tree.Find(42))
+	require.True(t, tree.Contains(99))
+
+	tree = tree.Update(42, 0)
+	require.Equal(t, 4908, tree.Summary())
+
+	for i := 0; i < 100; i += 2 {
+		tree = tree.Delete(i)
+	}
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, 2500, tree.Summary())
+	require.False(t, tree.Contains(42))
+	require.Same(t, tree, tree.Delete(42))
+
+	var keys []int
+	iter := tree.Iter()
+	for iter.Next() {
+		keys = append(keys, iter.Current().Key)
+	}
+	require.Len(t, keys, 50)
+	require.Equal(t, 1, keys[0])
+	require.Equal(t, 99, keys[49])
+}
+
+func TestAugmentedTreeDeleteRotations(t *testing.T) {
+	m := keysMonoid[int]()
+
+	// Deleting 1 leaves the root right-heavy with a right-heavy child: a single rotation.
+	tree := NewAugmentedTree(m).Update(2, 2).Update(1, 1).Update(3, 3).Update(4, 4)
+	tree = tree.Delete(1)
+	require.Equal(t, 3, tree.root.key)
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, []int{2, 3, 4}, tree.Summary())
+
+	// Deleting 1 leaves the root right-heavy with a left-heavy child: a double rotation.
+	tree = NewAugmentedTree(m).Update(2, 2).Update(1, 1).Update(4, 4).Update(3, 3)
+	tree = tree.Delete(1)
+	require.Equal(t, 3, tree.root.key)
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, []int{2, 3, 4}, tree.Summary())
+
+	// The mirror images, deleting from the right.
+	tree = NewAugmentedTree(m).Update(3, 3).Update(4, 4).Update(2, 2).Update(1, 1)
+	tree = tree.Delete(4)
+	require.Equal(t, 2, tree.root.key)
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, []int{1, 2, 3}, tree.Summary())
+
+	tree = NewAugmentedTree(m).Update(3, 3).Update(4, 4).Update(1, 1).Update(2, 2)
+	tree = tree.Delete(4)
+	require.Equal(t, 2, tree.root.key)
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+	require.Equal(t, []int{1, 2, 3}, tree.Summary())
+
+	// Deleting the left half of a larger tree key by key rotates at every level, including the root and nodes whose
+	// children are both non-empty. Check every summary after every delete.
+	tree = NewAugmentedTree(m)
+	var expected []int
+	for i := 0; i < 64; i++ {
+		tree = tree.Update(i, i)
+		expected = append(expected, i)
+	}
+	roots := map[int]bool{}
+	for i := 0; i < 48; i++ {
+		tree = tree.Delete(i)
+		expected = expected[1:]
+		roots[tree.root.key] = true
+		requireValidAugmentedNode(t, tree.monoid, tree.root)
+		require.Equal(t, expected, tree.Summary())
+		require.Equal(t, expected[:5], tree.Aggregate(0, expected[0]+4, Closed))
+	}
+	require.Greater(t, len(roots), 2)
+}
+
+func TestAugmentedTreeNil(t *testing.T) {
+	var tree *AugmentedTree[int, int, int]
+	require.True(t, tree.IsEmpty())
+	require.Equal(t, 0, tree.Summary())
+	require.Equal(t, 0, tree.Aggregate(0, 10, Closed))
+	require.False(t, tree.Contains(1))
+	require.False(t, tree.Iter().Next())
+	require.Nil(t, tree.Delete(1))
+	require.Panics(t, func() { tree.Update(1, 1) })
+}
+
+func TestAugmentedTreeAggregate(t *testing.T) {
+	tree := NewAugmentedTree(keysMonoid[int]())
+	for i := 0; i < 50; i += 2 {
+		tree = tree.Update(i, i)
+	}
+
+	require.Equal(t, []int{10, 12, 14, 16, 18, 20}, tree.Aggregate(10, 20, Closed))
+	require.Equal(t, []int{12, 14, 16, 18}, tree.Aggregate(10, 20, Open))
+	require.Equal(t, []int{12, 14, 16, 18, 20}, tree.Aggregate(10, 20, OpenLow))
+	require.Equal(t, []int{10, 12, 14, 16, 18}, tree.Aggregate(10, 20, OpenHigh))
+	require.Equal(t, []int{10, 12}, tree.Aggregate(9, 13, Closed))
+	require.Empty(t, tree.Aggregate(20, 10, Closed))
+	require.Empty(t, tree.Aggregate(11, 11, Closed))
+	require.Len(t, tree.Aggregate(-100, 100, Closed), 25)
+}
+
+func TestAugmentedTreeAggregateRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(12))
+	tree := NewAugmentedTree(sumMonoid[int]())
+	values := map[int]int{}
+	for i := 0; i < 500; i++ {
+		key := r.Intn(300)
+		if r.Intn(4) == 0 {
+			tree = tree.Delete(key)
+			delete(values, key)
+		} else {
+			tree = tree.Update(key, i)
+			values[key] = i
+		}
+	}
+	requireValidAugmentedNode(t, tree.monoid, tree.root)
+
+	for i := 0; i < 200; i++ {
+		lo, hi, bounds := r.Intn(320)-10, r.Intn(320)-10, Bounds(r.Intn(4))
+		expected := 0
+		for k, v := range values {
+			if aboveLow(k, lo, bounds) && belowHigh(k, hi, bounds) {
+				expected += v
+			}
+		}
+		require.Equal(t, expected, tree.Aggregate(lo, hi, bounds))
+	}
+}
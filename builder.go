@@ -0,0 +1,556 @@
+package persistent
+
+import "golang.org/x/exp/constraints"
+
+// owner identifies the builder allowed to modify a node in place. Nodes created outside of a builder have a nil
+// owner and are never modified. Every builder allocates its own owner, so the nodes of a built tree are immutable to
+// all other builders.
+type owner struct {
+	_ byte
+}
+
+// TreeBuilder applies a batch of updates to a Tree[K, V] without copying a root-to-leaf path for every update.
+//
+// A builder mutates the nodes it allocated itself in place and copies any node it shares with the tree it was created
+// from, so the original tree is never modified. Call Build to freeze the result into an immutable *Tree[K, V]. After
+// Build returns, the builder can no longer be used and all of its methods panic.
+//
+// A TreeBuilder is not concurrency safe. Sharing a builder between go-routines requires explicit synchronization.
+type TreeBuilder[K constraints.Ordered, V any] struct {
+	root  *Tree[K, V]
+	owner *owner
+}
+
+// Builder returns a TreeBuilder whose initial contents are the entries of n.
+func (n *Tree[K, V]) Builder() *TreeBuilder[K, V] {
+	ret := &TreeBuilder[K, V]{owner: &owner{}}
+	if !n.IsEmpty() {
+		ret.root = n
+	}
+	return ret
+}
+
+// Update sets the value for 'key' to 'value'.
+func (b *TreeBuilder[K, V]) Update(key K, value V) {
+	b.checkValid()
+	b.root = b.update(b.root, key, value)
+}
+
+// Delete removes the entry for 'key', if there is one.
+func (b *TreeBuilder[K, V]) Delete(key K) {
+	b.checkValid()
+	b.root, _ = b.delete(b.root, key)
+}
+
+// FindOpt returns the value associated with key. Returns true if found; otherwise false.
+func (b *TreeBuilder[K, V]) FindOpt(key K) (V, bool) {
+	b.checkValid()
+	return b.root.FindOpt(key)
+}
+
+// Find returns the value associated with key. Will return a zero value if no such item exists.
+func (b *TreeBuilder[K, V]) Find(key K) V {
+	value, _ := b.FindOpt(key)
+	return value
+}
+
+// Contains returns true if the builder contains the key.
+func (b *TreeBuilder[K, V]) Contains(key K) bool {
+	_, found := b.FindOpt(key)
+	return found
+}
+
+// Size returns the number of entries in the builder.
+func (b *TreeBuilder[K, V]) Size() int {
+	b.checkValid()
+	return b.root.Size()
+}
+
+// Build returns the contents of the builder as an immutable tree. The builder may not be used afterwards.
+func (b *TreeBuilder[K, V]) Build() *Tree[K, V] {
+	b.checkValid()
+	ret := b.root
+	b.root = nil
+	b.owner = nil
+	return ret
+}
+
+func (b *TreeBuilder[K, V]) checkValid() {
+	if b.owner == nil {
+		panic("builder used after Build")
+	}
+}
+
+// editable returns n if the builder owns it, or an owned copy of n otherwise.
+func (b *TreeBuilder[K, V]) editable(n *Tree[K, V]) *Tree[K, V] {
+	if n.owner == b.owner {
+		return n
+	}
+	ret := *n
+	ret.owner = b.owner
+	return &ret
+}
+
+func (b *TreeBuilder[K, V]) update(n *Tree[K, V], key K, value V) *Tree[K, V] {
+	if n.IsEmpty() {
+		ret := newNode(nil, nil, key, value)
+		ret.owner = b.owner
+		return ret
+	}
+
+	n = b.editable(n)
+	if n.key < key {
+		n.right = b.update(n.right, key, value)
+	} else if key < n.key {
+		n.left = b.update(n.left, key, value)
+	} else {
+		n.value = value
+		return n
+	}
+	return b.rebalance(n)
+}
+
+func (b *TreeBuilder[K, V]) delete(n *Tree[K, V], key K) (ret *Tree[K, V], removed bool) {
+	if n.IsEmpty() {
+		return n, false
+	}
+
+	if n.key < key {
+		right, removed := b.delete(n.right, key)
+		if !removed {
+			return n, false
+		}
+		n = b.editable(n)
+		n.right = right
+		return b.rebalance(n), true
+	}
+
+	if key < n.key {
+		left, removed := b.delete(n.left, key)
+		if !removed {
+			return n, false
+		}
+		n = b.editable(n)
+		n.left = left
+		return b.rebalance(n), true
+	}
+
+	if n.left.IsEmpty() {
+		return n.right, true
+	}
+	if n.right.IsEmpty() {
+		return n.left, true
+	}
+
+	n = b.editable(n)
+	var most *Tree[K, V]
+	n.left, most = b.removeMost(n.left)
+	n.key, n.value = most.key, most.value
+	return b.rebalance(n), true
+}
+
+// removeMost removes the node with the greatest key from n. It returns the new root along with the removed node.
+func (b *TreeBuilder[K, V]) removeMost(n *Tree[K, V]) (*Tree[K, V], *Tree[K, V]) {
+	if n.right.IsEmpty() {
+		return n.left, n
+	}
+	n = b.editable(n)
+	var most *Tree[K, V]
+	n.right, most = b.removeMost(n.right)
+	return b.rebalance(n), most
+}
+
+// refresh recomputes the cached size and height of an owned node.
+func (b *TreeBuilder[K, V]) refresh(n *Tree[K, V]) {
+	n.size = n.left.Size() + n.right.Size() + 1
+	n.height = max(n.left.Height(), n.right.Height()) + 1
+}
+
+// rebalance refreshes an owned node and restores the AVL invariant, rotating owned nodes in place.
+func (b *TreeBuilder[K, V]) rebalance(n *Tree[K, V]) *Tree[K, V] {
+	b.refresh(n)
+	balance := n.balanceFactor()
+
+	if balance > 1 {
+		if n.right.balanceFactor() < 0 {
+			n.right = b.rotateRight(b.editable(n.right))
+		}
+		return b.rotateLeft(n)
+	}
+
+	if balance < -1 {
+		if n.left.balanceFactor() > 0 {
+			n.left = b.rotateLeft(b.editable(n.left))
+		}
+		return b.rotateRight(n)
+	}
+
+	return n
+}
+
+func (b *TreeBuilder[K, V]) rotateLeft(n *Tree[K, V]) *Tree[K, V] {
+	r := b.editable(n.right)
+	n.right = r.left
+	b.refresh(n)
+	r.left = n
+	b.refresh(r)
+	return r
+}
+
+func (b *TreeBuilder[K, V]) rotateRight(n *Tree[K, V]) *Tree[K, V] {
+	l := b.editable(n.left)
+	n.left = l.right
+	b.refresh(n)
+	l.right = n
+	b.refresh(l)
+	return l
+}
+
+// TreeExBuilder applies a batch of updates to a TreeEx[K, V] without copying a root-to-leaf path for every update.
+//
+// A builder mutates the nodes it allocated itself in place and copies any node it shares with the tree it was created
+// from, so the original tree is never modified. Call Build to freeze the result into an immutable *TreeEx[K, V]. After
+// Build returns, the builder can no longer be used and all of its methods panic.
+//
+// A TreeExBuilder is not concurrency safe. Sharing a builder between go-routines requires explicit synchronization.
+type TreeExBuilder[K Ordered[K], V any] struct {
+	root  *TreeEx[K, V]
+	owner *owner
+}
+
+// Builder returns a TreeExBuilder whose initial contents are the entries of n.
+func (n *TreeEx[K, V]) Builder() *TreeExBuilder[K, V] {
+	ret := &TreeExBuilder[K, V]{owner: &owner{}}
+	if !n.IsEmpty() {
+		ret.root = n
+	}
+	return ret
+}
+
+// Update sets the value for 'key' to 'value'.
+func (b *TreeExBuilder[K, V]) Update(key K, value V) {
+	b.checkValid()
+	b.root = b.update(b.root, key, value)
+}
+
+// Delete removes the entry for 'key', if there is one.
+func (b *TreeExBuilder[K, V]) Delete(key K) {
+	b.checkValid()
+	b.root, _ = b.delete(b.root, key)
+}
+
+// FindOpt returns the value associated with key. Returns true if found; otherwise false.
+func (b *TreeExBuilder[K, V]) FindOpt(key K) (V, bool) {
+	b.checkValid()
+	return b.root.FindOpt(key)
+}
+
+// Find returns the value associated with key. Will return a zero value if no such item exists.
+func (b *TreeExBuilder[K, V]) Find(key K) V {
+	value, _ := b.FindOpt(key)
+	return value
+}
+
+// Contains returns true if the builder contains the key.
+func (b *TreeExBuilder[K, V]) Contains(key K) bool {
+	_, found := b.FindOpt(key)
+	return found
+}
+
+// Size returns the number of entries in the builder.
+func (b *TreeExBuilder[K, V]) Size() int {
+	b.checkValid()
+	return b.root.Size()
+}
+
+// Build returns the contents of the builder as an immutable tree. The builder may not be used afterwards.
+func (b *TreeExBuilder[K, V]) Build() *TreeEx[K, V] {
+	b.checkValid()
+	ret := b.root
+	b.root = nil
+	b.owner = nil
+	return ret
+}
+
+func (b *TreeExBuilder[K, V]) checkValid() {
+	if b.owner == nil {
+		panic("builder used after Build")
+	}
+}
+
+// editable returns n if the builder owns it, or an owned copy of n otherwise.
+func (b *TreeExBuilder[K, V]) editable(n *TreeEx[K, V]) *TreeEx[K, V] {
+	if n.owner == b.owner {
+		return n
+	}
+	ret := *n
+	ret.owner = b.owner
+	return &ret
+}
+
+func (b *TreeExBuilder[K, V]) update(n *TreeEx[K, V], key K, value V) *TreeEx[K, V] {
+	if n.IsEmpty() {
+		ret := newExNode(nil, nil, key, value)
+		ret.owner = b.owner
+		return ret
+	}
+
+	n = b.editable(n)
+	if n.key.Less(key) {
+		n.right = b.update(n.right, key, value)
+	} else if key.Less(n.key) {
+		n.left = b.update(n.left, key, value)
+	} else {
+		n.value = value
+		return n
+	}
+	return b.rebalance(n)
+}
+
+func (b *TreeExBuilder[K, V]) delete(n *TreeEx[K, V], key K) (ret *TreeEx[K, V], removed bool) {
+	if n.IsEmpty() {
+		return n, false
+	}
+
+	if n.key.Less(key) {
+		right, removed := b.delete(n.right, key)
+		if !removed {
+			return n, false
+		}
+		n = b.editable(n)
+		n.right = right
+		return b.rebalance(n), true
+	}
+
+	if key.Less(n.key) {
+		left, removed := b.delete(n.left, key)
+		if !removed {
+			return n, false
+		}
+		n = b.editable(n)
+		n.left = left
+		return b.rebalance(n), true
+	}
+
+	if n.left.IsEmpty() {
+		return n.right, true
+	}
+	if n.right.IsEmpty() {
+		return n.left, true
+	}
+
+	n = b.editable(n)
+	var most *TreeEx[K, V]
+	n.left, most = b.removeMost(n.left)
+	n.key, n.value = most.key, most.value
+	return b.rebalance(n), true
+}
+
+// removeMost removes the node with the greatest key from n. It returns the new root along with the removed node.
+func (b *TreeExBuilder[K, V]) removeMost(n *TreeEx[K, V]) (*TreeEx[K, V], *TreeEx[K, V]) {
+	if n.right.IsEmpty() {
+		return n.left, n
+	}
+	n = b.editable(n)
+	var most *TreeEx[K, V]
+	n.right, most = b.removeMost(n.right)
+	return b.rebalance(n), most
+}
+
+// refresh recomputes the cached size and height of an owned node.
+func (b *TreeExBuilder[K, V]) refresh(n *TreeEx[K, V]) {
+	n.size = n.left.Size() + n.right.Size() + 1
+	n.height = max(n.left.Height(), n.right.Height()) + 1
+}
+
+// rebalance refreshes an owned node and restores the AVL invariant, rotating owned nodes in place.
+func (b *TreeExBuilder[K, V]) rebalance(n *TreeEx[K, V]) *TreeEx[K, V] {
+	b.refresh(n)
+	balance := n.balanceFactor()
+
+	if balance > 1 {
+		if n.right.balanceFactor() < 0 {
+			n.right = b.rotateRight(b.editable(n.right))
+		}
+		return b.rotateLeft(n)
+	}
+
+	if balance < -1 {
+		if n.left.balanceFactor() > 0 {
+			n.left = b.rotateLeft(b.editable(n.left))
+		}
+		return b.rotateRight(n)
+	}
+
+	return n
+}
+
+func (b *TreeExBuilder[K, V]) rotateLeft(n *TreeEx[K, V]) *TreeEx[K, V] {
+	r := b.editable(n.right)
+	n.right = r.left
+	b.refresh(n)
+	r.left = n
+	b.refresh(r)
+	return r
+}
+
+func (b *TreeExBuilder[K, V]) rotateRight(n *TreeEx[K, V]) *TreeEx[K, V] {
+	l := b.editable(n.left)
+	n.left = l.right
+	b.refresh(n)
+	l.right = n
+	b.refresh(l)
+	return l
+}
+
+// SetBuilder applies a batch of updates to a Set[T]. See TreeBuilder for details. After Build returns, the builder can
+// no longer be used and all of its methods panic.
+type SetBuilder[T constraints.Ordered] struct {
+	base *Set[T]
+	tree *TreeBuilder[T, bool]
+}
+
+// Builder returns a SetBuilder whose initial contents are the elements of s.
+func (s *Set[T]) Builder() *SetBuilder[T] {
+	return &SetBuilder[T]{
+		base: s,
+		tree: s.root().Builder(),
+	}
+}
+
+// Add adds 'elem' to the builder.
+func (b *SetBuilder[T]) Add(elem T) {
+	if !b.tree.Contains(elem) {
+		b.tree.Update(elem, true)
+	}
+}
+
+// Remove removes 'elem' from the builder.
+func (b *SetBuilder[T]) Remove(elem T) {
+	b.tree.Delete(elem)
+}
+
+// Contains returns true if the builder contains 'elem'.
+func (b *SetBuilder[T]) Contains(elem T) bool {
+	return b.tree.Contains(elem)
+}
+
+// Size returns the number of elements in the builder.
+func (b *SetBuilder[T]) Size() int {
+	return b.tree.Size()
+}
+
+// Build returns the contents of the builder as an immutable set. The builder may not be used afterwards.
+func (b *SetBuilder[T]) Build() *Set[T] {
+	ret := b.base.withTree(b.tree.Build(), nil)
+	b.base = nil
+	return ret
+}
+
+// SetExBuilder applies a batch of updates to a SetEx[T]. See TreeExBuilder for details. After Build returns, the builder can
+// no longer be used and all of its methods panic.
+type SetExBuilder[T Ordered[T]] struct {
+	base *SetEx[T]
+	tree *TreeExBuilder[T, bool]
+}
+
+// Builder returns a SetExBuilder whose initial contents are the elements of s.
+func (s *SetEx[T]) Builder() *SetExBuilder[T] {
+	return &SetExBuilder[T]{
+		base: s,
+		tree: s.root().Builder(),
+	}
+}
+
+// Add adds 'elem' to the builder.
+func (b *SetExBuilder[T]) Add(elem T) {
+	if !b.tree.Contains(elem) {
+		b.tree.Update(elem, true)
+	}
+}
+
+// Remove removes 'elem' from the builder.
+func (b *SetExBuilder[T]) Remove(elem T) {
+	b.tree.Delete(elem)
+}
+
+// Contains returns true if the builder contains 'elem'.
+func (b *SetExBuilder[T]) Contains(elem T) bool {
+	return b.tree.Contains(elem)
+}
+
+// Size returns the number of elements in the builder.
+func (b *SetExBuilder[T]) Size() int {
+	return b.tree.Size()
+}
+
+// Build returns the contents of the builder as an immutable set. The builder may not be used afterwards.
+func (b *SetExBuilder[T]) Build() *SetEx[T] {
+	ret := b.base.withTree(b.tree.Build(), nil)
+	b.base = nil
+	return ret
+}
+
+// QueueBuilder applies a batch of updates to a Queue[T] without allocating a new queue for every operation. Call Build
+// to freeze the result into an immutable *Queue[T]. After Build returns, the builder can no longer be used and all of
+// its methods panic.
+//
+// A QueueBuilder is not concurrency safe. Sharing a builder between go-routines requires explicit synchronization.
+type QueueBuilder[T any] struct {
+	queue Queue[T]
+	built bool
+}
+
+// Builder returns a QueueBuilder whose initial contents are the elements of q.
+func (q *Queue[T]) Builder() *QueueBuilder[T] {
+	ret := &QueueBuilder[T]{}
+	if q != nil {
+		ret.queue = *q
+	}
+	return ret
+}
+
+// Enqueue adds 'value' to the end of the queue.
+func (b *QueueBuilder[T]) Enqueue(value T) {
+	b.checkValid()
+	b.queue.push(value)
+}
+
+// Dequeue removes the first element of the queue and returns it. If the queue is empty, ok will be false.
+func (b *QueueBuilder[T]) Dequeue() (value T, ok bool) {
+	b.checkValid()
+	if b.queue.IsEmpty() {
+		return value, false
+	}
+	return b.queue.pop(), true
+}
+
+// Top returns the first element of the queue without removing it. If the queue is empty, the zero value of T is
+// returned.
+func (b *QueueBuilder[T]) Top() T {
+	b.checkValid()
+	return b.queue.Top()
+}
+
+// Size returns the number of elements in the queue.
+func (b *QueueBuilder[T]) Size() int {
+	b.checkValid()
+	return b.queue.Size()
+}
+
+// Build returns the contents of the builder as an immutable queue. The builder may not be used afterwards.
+func (b *QueueBuilder[T]) Build() *Queue[T] {
+	b.checkValid()
+	b.built = true
+	if b.queue.IsEmpty() {
+		return nil
+	}
+	ret := b.queue
+	return &ret
+}
+
+func (b *QueueBuilder[T]) checkValid() {
+	if b.built {
+		panic("builder used after Build")
+	}
+}
@@ -0,0 +1,158 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	original := treeOfRange(0, 100, 1)
+	b := original.Builder()
+	expected := treeMap(original)
+	for i := 0; i < 1000; i++ {
+		key := (i * 37) % 150
+		if i%3 == 0 {
+			b.Delete(key)
+			delete(expected, key)
+		} else {
+			b.Update(key, i)
+			expected[key] = i
+		}
+	}
+	require.Equal(t, len(expected), b.Size())
+	v, found := b.FindOpt(13)
+	ev, efound := expected[13]
+	require.Equal(t, efound, found)
+	require.Equal(t, ev, v)
+
+	tree := b.Build()
+	requireValidTree(t, tree)
+	require.Equal(t, expected, treeMap(tree))
+
+	requireValidTree(t, original)
+	for i := 0; i < 100; i++ {
+		require.Equal(t, i, original.Find(i))
+	}
+}
+
+func TestTreeBuilderDeleteSequence(t *testing.T) {
+	b := treeOfRange(0, 128, 1).Builder()
+	for i := 0; i < 128; i++ {
+		b.Delete((i * 37) % 128)
+		requireValidTree(t, b.root)
+	}
+	require.True(t, b.Build().IsEmpty())
+}
+
+func TestTreeBuilderUpdatesInPlace(t *testing.T) {
+	b := treeOfRange(0, 100, 1).Builder()
+	b.Update(50, -1)
+	allocs := testing.AllocsPerRun(10, func() {
+		b.Update(50, -2)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestTreeBuilderFromEmpty(t *testing.T) {
+	b := (&Tree[int, int]{}).Builder()
+	require.Equal(t, 0, b.Size())
+	b.Delete(3)
+	require.True(t, b.Build().IsEmpty())
+
+	b = EmptyTree[int, int]().Builder()
+	for i := 0; i < 100; i++ {
+		b.Update(i, i)
+	}
+	tree := b.Build()
+	requireValidTree(t, tree)
+	require.Equal(t, 100, tree.Size())
+}
+
+func TestTreeBuilderFrozen(t *testing.T) {
+	b := treeOfRange(0, 10, 1).Builder()
+	b.Update(20, 20)
+	tree := b.Build()
+	require.Panics(t, func() { b.Update(1, 1) })
+	require.Panics(t, func() { b.Delete(1) })
+	require.Panics(t, func() { b.Contains(1) })
+	require.Panics(t, func() { b.Build() })
+
+	// Nodes created by the first builder must not be modified by a second one.
+	b2 := tree.Builder()
+	b2.Update(20, 21)
+	require.Equal(t, 20, tree.Find(20))
+	require.Equal(t, 21, b2.Build().Find(20))
+}
+
+func TestTreeExBuilder(t *testing.T) {
+	original := treeExOfRange(0, 100, 1)
+	b := original.Builder()
+	for i := 0; i < 100; i += 2 {
+		b.Delete(Int(i))
+	}
+	b.Update(1000, 1000)
+	tree := b.Build()
+	requireValidTreeEx(t, tree)
+	require.Equal(t, 51, tree.Size())
+	require.Equal(t, 100, original.Size())
+	require.Panics(t, func() { b.Size() })
+}
+
+func TestSetBuilder(t *testing.T) {
+	original := setOf(1, 2, 3)
+	b := original.Builder()
+	b.Add(4)
+	b.Add(2)
+	b.Remove(1)
+	require.True(t, b.Contains(4))
+	require.Equal(t, 3, b.Size())
+	require.Equal(t, []int{2, 3, 4}, setElems(b.Build()))
+	require.Equal(t, []int{1, 2, 3}, setElems(original))
+	require.Panics(t, func() { b.Add(5) })
+
+	unchanged := original.Builder()
+	unchanged.Add(1)
+	require.True(t, original == unchanged.Build())
+}
+
+func TestSetExBuilder(t *testing.T) {
+	b := EmptySetEx[Int]().Builder()
+	for i := Int(0); i < 10; i++ {
+		b.Add(i)
+	}
+	b.Remove(5)
+	require.Equal(t, []Int{0, 1, 2, 3, 4, 6, 7, 8, 9}, setExElems(b.Build()))
+}
+
+func TestQueueBuilder(t *testing.T) {
+	original := EmptyQueue[int]().Enqueue(1).Enqueue(2)
+	b := original.Builder()
+	for i := 3; i <= 10; i++ {
+		b.Enqueue(i)
+	}
+	v, ok := b.Dequeue()
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	require.Equal(t, 2, b.Top())
+	require.Equal(t, 9, b.Size())
+
+	q := b.Build()
+	require.Panics(t, func() { b.Enqueue(11) })
+	for expected := 2; expected <= 10; expected++ {
+		v, q = q.Dequeue()
+		require.Equal(t, expected, v)
+	}
+	require.True(t, q.IsEmpty())
+	require.Equal(t, 2, original.Size())
+	require.Equal(t, 1, original.Top())
+}
+
+func TestQueueBuilderEmpty(t *testing.T) {
+	b := EmptyQueue[int]().Builder()
+	_, ok := b.Dequeue()
+	require.False(t, ok)
+	b.Enqueue(1)
+	_, ok = b.Dequeue()
+	require.True(t, ok)
+	require.True(t, b.Build().IsEmpty())
+}
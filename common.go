@@ -1,5 +1,18 @@
 package persistent
 
+import "errors"
+
+var (
+	// ErrUnsorted is returned by constructors that require sorted input when the input is out of order.
+	ErrUnsorted = errors.New("persistent: input is not sorted")
+
+	// ErrDuplicateKey is returned by constructors that require unique keys when the input contains a duplicate.
+	ErrDuplicateKey = errors.New("persistent: duplicate key")
+
+	// ErrNoComparator is returned when decoding into a comparator-ordered collection that was created without one.
+	ErrNoComparator = errors.New("persistent: collection has no comparator")
+)
+
 // Pair defines a struct for a Key / Value pair.
 type Pair[K any, V any] struct {
 	// Key is the key associated with the pair.
@@ -33,3 +46,75 @@ type Iterator[T any] interface {
 type Ordered[T any] interface {
 	Less(rhs T) bool
 }
+
+// Comparable defines an optional interface for custom key types that can compare two keys in a single call. Compare
+// returns a negative number, zero or a positive number if the receiver is less than, equal to or greater than rhs. It
+// must agree with Less. TreeEx and SetEx use Compare when it is available, which halves the number of comparisons
+// needed by lookups and updates. If K is an interface type, Compare is used when the dynamic type of the search key
+// implements it. Lookups call Compare without allocating.
+type Comparable[T any] interface {
+	Compare(rhs T) int
+}
+
+// Option holds a value that may be absent.
+type Option[T any] struct {
+	// Value is the value held by the option. It is the zero value for T when Ok is false.
+	Value T
+
+	// Ok is true iif the option holds a value.
+	Ok bool
+}
+
+// Some returns an Option holding 'value'.
+func Some[T any](value T) Option[T] {
+	return Option[T]{Value: value, Ok: true}
+}
+
+// Joined holds the values associated with a single key by the two inputs of an outer join.
+type Joined[A any, B any] struct {
+	// Left is the value from the left input of the join, if any.
+	Left Option[A]
+
+	// Right is the value from the right input of the join, if any.
+	Right Option[B]
+}
+
+// Bounds controls whether the endpoints of a key range belong to the range. The zero value, Closed, includes both
+// endpoints.
+type Bounds int
+
+const (
+	// Closed includes both endpoints: [lo, hi].
+	Closed Bounds = 0
+
+	// OpenLow excludes the lower endpoint: (lo, hi].
+	OpenLow Bounds = 1
+
+	// OpenHigh excludes the upper endpoint: [lo, hi).
+	OpenHigh Bounds = 2
+
+	// Open excludes both endpoints: (lo, hi).
+	Open = OpenLow | OpenHigh
+)
+
+func (b Bounds) excludesLow() bool {
+	return b&OpenLow != 0
+}
+
+func (b Bounds) excludesHigh() bool {
+	return b&OpenHigh != 0
+}
+
+// Monoid describes how to summarize a sequence of values of type T with a value of type S. Measure summarizes a single
+// value, and Combine joins the summaries of two adjacent runs of values. Combine must be associative and Identity must
+// be its identity element. Combine need not be commutative: its first argument always summarizes the earlier values.
+type Monoid[T any, S any] struct {
+	// Identity is the summary of an empty sequence.
+	Identity S
+
+	// Measure returns the summary of a single value.
+	Measure func(value T) S
+
+	// Combine returns the summary of the values summarized by a followed by the values summarized by b.
+	Combine func(a, b S) S
+}
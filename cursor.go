@@ -0,0 +1,325 @@
+package persistent
+
+import "golang.org/x/exp/constraints"
+
+// TreeCursor is a bidirectional cursor over a Tree. Unlike an iterator, a cursor can move in both directions and can be
+// repositioned at any time. A cursor holds the path from the root of the tree to its current entry, so each move is
+// O(1) amortized and O(log(N)) in the worst case.
+//
+// A new cursor is positioned before the first entry. Moving past either end of the tree leaves the cursor before the
+// first entry or after the last one, where Valid returns false; Next from before the first entry moves to the first
+// entry, and Prev from after the last entry moves to the last entry.
+//
+// A cursor reads an immutable tree, so it is never invalidated by updates, which produce new trees. A cursor is not
+// concurrency safe, but Clone can be used to hand out independent copies.
+type TreeCursor[K constraints.Ordered, V any] struct {
+	root *Tree[K, V]
+	path []*Tree[K, V]
+
+	// after is true if the cursor has moved past the last entry. It is only meaningful when path is empty.
+	after bool
+}
+
+// Cursor returns a new cursor over n, positioned before the first entry.
+func (n *Tree[K, V]) Cursor() *TreeCursor[K, V] {
+	return &TreeCursor[K, V]{root: n}
+}
+
+// SeekFirst moves the cursor to the least entry of the tree. It returns false if the tree is empty.
+func (c *TreeCursor[K, V]) SeekFirst() bool {
+	c.path = c.path[:0]
+	c.after = false
+	c.pushLeft(c.root)
+	return c.Valid()
+}
+
+// SeekLast moves the cursor to the greatest entry of the tree. It returns false if the tree is empty.
+func (c *TreeCursor[K, V]) SeekLast() bool {
+	c.path = c.path[:0]
+	c.after = true
+	c.pushRight(c.root)
+	return c.Valid()
+}
+
+// Seek moves the cursor to the least entry with a key greater than or equal to 'key'. If there is no such entry, the
+// cursor moves past the last entry and Seek returns false.
+func (c *TreeCursor[K, V]) Seek(key K) bool {
+	c.path = c.path[:0]
+	c.after = true
+	match := 0
+	for n := c.root; !n.IsEmpty(); {
+		c.path = append(c.path, n)
+		if n.key < key {
+			n = n.right
+		} else if key < n.key {
+			match = len(c.path)
+			n = n.left
+		} else {
+			return true
+		}
+	}
+	c.path = c.path[:match]
+	return c.Valid()
+}
+
+// Next moves the cursor to the next entry in key order. It returns false if there is no such entry.
+func (c *TreeCursor[K, V]) Next() bool {
+	if len(c.path) == 0 {
+		if c.after {
+			return false
+		}
+		return c.SeekFirst()
+	}
+
+	current := c.path[len(c.path)-1]
+	if !current.right.IsEmpty() {
+		c.pushLeft(current.right)
+		return true
+	}
+
+	for {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			c.after = true
+			return false
+		}
+		if c.path[len(c.path)-1].left == child {
+			return true
+		}
+	}
+}
+
+// Prev moves the cursor to the previous entry in key order. It returns false if there is no such entry.
+func (c *TreeCursor[K, V]) Prev() bool {
+	if len(c.path) == 0 {
+		if !c.after {
+			return false
+		}
+		return c.SeekLast()
+	}
+
+	current := c.path[len(c.path)-1]
+	if !current.left.IsEmpty() {
+		c.pushRight(current.left)
+		return true
+	}
+
+	for {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			c.after = false
+			return false
+		}
+		if c.path[len(c.path)-1].right == child {
+			return true
+		}
+	}
+}
+
+// Valid returns true if the cursor is positioned at an entry.
+func (c *TreeCursor[K, V]) Valid() bool {
+	return len(c.path) != 0
+}
+
+// Key returns the key of the current entry. It panics if the cursor is not positioned at an entry.
+func (c *TreeCursor[K, V]) Key() K {
+	return c.current().key
+}
+
+// Value returns the value of the current entry. It panics if the cursor is not positioned at an entry.
+func (c *TreeCursor[K, V]) Value() V {
+	return c.current().value
+}
+
+// Clone returns an independent copy of the cursor, at the same position.
+func (c *TreeCursor[K, V]) Clone() *TreeCursor[K, V] {
+	ret := *c
+	ret.path = append([]*Tree[K, V](nil), c.path...)
+	return &ret
+}
+
+func (c *TreeCursor[K, V]) current() *Tree[K, V] {
+	if len(c.path) == 0 {
+		panic("invalid cursor position")
+	}
+	return c.path[len(c.path)-1]
+}
+
+// pushLeft pushes n and the left spine below it onto the path.
+func (c *TreeCursor[K, V]) pushLeft(n *Tree[K, V]) {
+	for !n.IsEmpty() {
+		c.path = append(c.path, n)
+		n = n.left
+	}
+}
+
+// pushRight pushes n and the right spine below it onto the path.
+func (c *TreeCursor[K, V]) pushRight(n *Tree[K, V]) {
+	for !n.IsEmpty() {
+		c.path = append(c.path, n)
+		n = n.right
+	}
+}
+
+// TreeExCursor is a bidirectional cursor over a TreeEx. Unlike an iterator, a cursor can move in both directions and can be
+// repositioned at any time. A cursor holds the path from the root of the tree to its current entry, so each move is
+// O(1) amortized and O(log(N)) in the worst case.
+//
+// A new cursor is positioned before the first entry. Moving past either end of the tree leaves the cursor before the
+// first entry or after the last one, where Valid returns false; Next from before the first entry moves to the first
+// entry, and Prev from after the last entry moves to the last entry.
+//
+// A cursor reads an immutable tree, so it is never invalidated by updates, which produce new trees. A cursor is not
+// concurrency safe, but Clone can be used to hand out independent copies.
+type TreeExCursor[K Ordered[K], V any] struct {
+	root *TreeEx[K, V]
+	path []*TreeEx[K, V]
+
+	// after is true if the cursor has moved past the last entry. It is only meaningful when path is empty.
+	after bool
+}
+
+// Cursor returns a new cursor over n, positioned before the first entry.
+func (n *TreeEx[K, V]) Cursor() *TreeExCursor[K, V] {
+	return &TreeExCursor[K, V]{root: n}
+}
+
+// SeekFirst moves the cursor to the least entry of the tree. It returns false if the tree is empty.
+func (c *TreeExCursor[K, V]) SeekFirst() bool {
+	c.path = c.path[:0]
+	c.after = false
+	c.pushLeft(c.root)
+	return c.Valid()
+}
+
+// SeekLast moves the cursor to the greatest entry of the tree. It returns false if the tree is empty.
+func (c *TreeExCursor[K, V]) SeekLast() bool {
+	c.path = c.path[:0]
+	c.after = true
+	c.pushRight(c.root)
+	return c.Valid()
+}
+
+// Seek moves the cursor to the least entry with a key greater than or equal to 'key'. If there is no such entry, the
+// cursor moves past the last entry and Seek returns false.
+func (c *TreeExCursor[K, V]) Seek(key K) bool {
+	c.path = c.path[:0]
+	c.after = true
+	match := 0
+	for n := c.root; !n.IsEmpty(); {
+		c.path = append(c.path, n)
+		if n.key.Less(key) {
+			n = n.right
+		} else if key.Less(n.key) {
+			match = len(c.path)
+			n = n.left
+		} else {
+			return true
+		}
+	}
+	c.path = c.path[:match]
+	return c.Valid()
+}
+
+// Next moves the cursor to the next entry in key order. It returns false if there is no such entry.
+func (c *TreeExCursor[K, V]) Next() bool {
+	if len(c.path) == 0 {
+		if c.after {
+			return false
+		}
+		return c.SeekFirst()
+	}
+
+	current := c.path[len(c.path)-1]
+	if !current.right.IsEmpty() {
+		c.pushLeft(current.right)
+		return true
+	}
+
+	for {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			c.after = true
+			return false
+		}
+		if c.path[len(c.path)-1].left == child {
+			return true
+		}
+	}
+}
+
+// Prev moves the cursor to the previous entry in key order. It returns false if there is no such entry.
+func (c *TreeExCursor[K, V]) Prev() bool {
+	if len(c.path) == 0 {
+		if !c.after {
+			return false
+		}
+		return c.SeekLast()
+	}
+
+	current := c.path[len(c.path)-1]
+	if !current.left.IsEmpty() {
+		c.pushRight(current.left)
+		return true
+	}
+
+	for {
+		child := c.path[len(c.path)-1]
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			c.after = false
+			return false
+		}
+		if c.path[len(c.path)-1].right == child {
+			return true
+		}
+	}
+}
+
+// Valid returns true if the cursor is positioned at an entry.
+func (c *TreeExCursor[K, V]) Valid() bool {
+	return len(c.path) != 0
+}
+
+// Key returns the key of the current entry. It panics if the cursor is not positioned at an entry.
+func (c *TreeExCursor[K, V]) Key() K {
+	return c.current().key
+}
+
+// Value returns the value of the current entry. It panics if the cursor is not positioned at an entry.
+func (c *TreeExCursor[K, V]) Value() V {
+	return c.current().value
+}
+
+// Clone returns an independent copy of the cursor, at the same position.
+func (c *TreeExCursor[K, V]) Clone() *TreeExCursor[K, V] {
+	ret := *c
+	ret.path = append([]*TreeEx[K, V](nil), c.path...)
+	return &ret
+}
+
+func (c *TreeExCursor[K, V]) current() *TreeEx[K, V] {
+	if len(c.path) == 0 {
+		panic("invalid cursor position")
+	}
+	return c.path[len(c.path)-1]
+}
+
+// pushLeft pushes n and the left spine below it onto the path.
+func (c *TreeExCursor[K, V]) pushLeft(n *TreeEx[K, V]) {
+	for !n.IsEmpty() {
+		c.path = append(c.path, n)
+		n = n.left
+	}
+}
+
+// pushRight pushes n and the right spine below it onto the path.
+func (c *TreeExCursor[K, V]) pushRight(n *TreeEx[K, V]) {
+	for !n.IsEmpty() {
+		c.path = append(c.path, n)
+		n = n.right
+	}
+}
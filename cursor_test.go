@@ -0,0 +1,103 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTreeCursorForwardBackward(t *testing.T) {
+	tree := treeOfRange(0, 100, 1)
+	c := tree.Cursor()
+	require.False(t, c.Valid())
+
+	var keys []int
+	for c.Next() {
+		keys = append(keys, c.Key())
+	}
+	require.Equal(t, treeKeys(tree), keys)
+	require.False(t, c.Valid())
+	require.False(t, c.Next())
+
+	keys = nil
+	for c.Prev() {
+		keys = append(keys, c.Key())
+	}
+	require.Len(t, keys, 100)
+	require.Equal(t, 99, keys[0])
+	require.Equal(t, 0, keys[99])
+	require.False(t, c.Prev())
+	require.True(t, c.Next())
+	require.Equal(t, 0, c.Key())
+}
+
+func TestTreeCursorSeek(t *testing.T) {
+	tree := treeOfRange(0, 100, 2)
+	c := tree.Cursor()
+
+	require.True(t, c.Seek(41))
+	require.Equal(t, 42, c.Key())
+	require.Equal(t, 42, c.Value())
+	require.True(t, c.Prev())
+	require.Equal(t, 40, c.Key())
+	require.True(t, c.Next())
+	require.True(t, c.Next())
+	require.Equal(t, 44, c.Key())
+
+	require.True(t, c.Seek(50))
+	require.Equal(t, 50, c.Key())
+	require.True(t, c.Seek(-10))
+	require.Equal(t, 0, c.Key())
+
+	require.False(t, c.Seek(99))
+	require.Panics(t, func() { c.Key() })
+	require.True(t, c.Prev())
+	require.Equal(t, 98, c.Key())
+
+	require.True(t, c.SeekFirst())
+	require.Equal(t, 0, c.Key())
+	require.False(t, c.Prev())
+	require.True(t, c.SeekLast())
+	require.Equal(t, 98, c.Key())
+	require.False(t, c.Next())
+}
+
+func TestTreeCursorClone(t *testing.T) {
+	tree := treeOfRange(0, 10, 1)
+	c := tree.Cursor()
+	c.Seek(5)
+	clone := c.Clone()
+	c.Next()
+	require.Equal(t, 6, c.Key())
+	require.Equal(t, 5, clone.Key())
+	clone.Prev()
+	require.Equal(t, 4, clone.Key())
+	require.Equal(t, 6, c.Key())
+}
+
+func TestTreeCursorEmpty(t *testing.T) {
+	var tree *Tree[int, int]
+	c := tree.Cursor()
+	require.False(t, c.Next())
+	require.False(t, c.Prev())
+	require.False(t, c.SeekFirst())
+	require.False(t, c.SeekLast())
+	require.False(t, c.Seek(1))
+
+	c = (&Tree[int, int]{}).Cursor()
+	require.False(t, c.Next())
+}
+
+func TestTreeExCursor(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+	c := tree.Cursor()
+	require.True(t, c.Seek(Int(7)))
+	require.Equal(t, Int(8), c.Key())
+	require.True(t, c.Prev())
+	require.Equal(t, Int(6), c.Key())
+
+	var keys []Int
+	for c.SeekLast(); c.Valid(); c.Prev() {
+		keys = append(keys, c.Key())
+	}
+	require.Equal(t, []Int{18, 16, 14, 12, 10, 8, 6, 4, 2, 0}, keys)
+}
@@ -0,0 +1,178 @@
+package persistent
+
+import (
+	"encoding/json"
+)
+
+// Deque implements a persistent double-ended queue.
+//
+// Persistent deques are immutable. Each mutating operation will return a pointer to a new deque with the update
+// applied. The implementation uses structural sharing to make immutability efficient. The implementation is
+// concurrency safe and non-blocking. A *Deque[T] instance may be accessed from multiple go-routines without
+// synchronization.
+//
+// A Deque[T] is a pair of stacks: one holding the front of the deque and one holding the back. Whenever a pop empties
+// one side, the other side is split in half, so that a run of pops from the same end can't make every pop O(n). Push
+// and peek operations are O(1), and pops are amortized O(1). Because the split is O(n), repeatedly popping the same
+// version of a deque across the split point can repeat that cost.
+type Deque[T any] struct {
+	front *Stack[T]
+	back  *Stack[T]
+}
+
+// IsEmpty returns true iif the deque is empty.
+func (d *Deque[T]) IsEmpty() bool {
+	return d.Size() == 0
+}
+
+// Size returns the number of elements in 'd'.
+func (d *Deque[T]) Size() int {
+	if d == nil {
+		return 0
+	}
+	return d.front.Size() + d.back.Size()
+}
+
+// PushFront returns a new deque with 'value' added to the front. This is O(1).
+func (d *Deque[T]) PushFront(value T) *Deque[T] {
+	front, back := d.stacks()
+	front, back = pushDeque(front, back, value)
+	return &Deque[T]{front: front, back: back}
+}
+
+// PushBack returns a new deque with 'value' added to the back. This is O(1).
+func (d *Deque[T]) PushBack(value T) *Deque[T] {
+	front, back := d.stacks()
+	back, front = pushDeque(back, front, value)
+	return &Deque[T]{front: front, back: back}
+}
+
+// PopFront removes the first element of the deque, and returns it along with a new deque with the value removed. If
+// the deque is empty, the returned 'value' will be the zero value of T.
+func (d *Deque[T]) PopFront() (value T, deque *Deque[T]) {
+	front, back := d.stacks()
+	value, front, back = popDeque(front, back)
+	return value, newDeque(front, back)
+}
+
+// PopBack removes the last element of the deque, and returns it along with a new deque with the value removed. If the
+// deque is empty, the returned 'value' will be the zero value of T.
+func (d *Deque[T]) PopBack() (value T, deque *Deque[T]) {
+	front, back := d.stacks()
+	value, back, front = popDeque(back, front)
+	return value, newDeque(front, back)
+}
+
+// PeekFront returns the first element of the deque without removing it. If the deque is empty, the returned value
+// will be the zero value of T. This is O(1).
+func (d *Deque[T]) PeekFront() T {
+	front, back := d.stacks()
+	return peekDeque(front, back)
+}
+
+// PeekBack returns the last element of the deque without removing it. If the deque is empty, the returned value will
+// be the zero value of T. This is O(1).
+func (d *Deque[T]) PeekBack() T {
+	front, back := d.stacks()
+	return peekDeque(back, front)
+}
+
+func (d *Deque[T]) stacks() (front *Stack[T], back *Stack[T]) {
+	if d == nil {
+		return nil, nil
+	}
+	return d.front, d.back
+}
+
+func newDeque[T any](front *Stack[T], back *Stack[T]) *Deque[T] {
+	if front.IsEmpty() && back.IsEmpty() {
+		return nil
+	}
+	return &Deque[T]{front: front, back: back}
+}
+
+// The helpers below are written in terms of the 'near' end being operated on and the 'far' end, so they serve both
+// ends of the deque. They maintain the invariant that a deque with two or more elements has elements on both sides,
+// so that peeking at either end is O(1).
+
+// pushDeque pushes 'value' onto the near side.
+func pushDeque[T any](near *Stack[T], far *Stack[T], value T) (*Stack[T], *Stack[T]) {
+	if far.IsEmpty() && near.Size() == 1 {
+		// Move the only other element to the far side, so that both sides are non-empty.
+		return EmptyStack[T]().Push(value), near
+	}
+	return near.Push(value), far
+}
+
+// popDeque pops an element from the near side, rebalancing the stacks if that empties the near side.
+func popDeque[T any](near *Stack[T], far *Stack[T]) (T, *Stack[T], *Stack[T]) {
+	if near.IsEmpty() {
+		// At most one element, which is on the far side.
+		return far.Peek(), nil, nil
+	}
+	value := near.Peek()
+	near = near.Pop()
+	if near.IsEmpty() && far.Size() > 1 {
+		near, far = splitStack(far)
+	}
+	return value, near, far
+}
+
+// peekDeque returns the element at the near end.
+func peekDeque[T any](near *Stack[T], far *Stack[T]) T {
+	if near.IsEmpty() {
+		return far.Peek()
+	}
+	return near.Peek()
+}
+
+// splitStack moves the bottom half of 'far' onto a new stack for the opposite end of the deque. It returns the new
+// near stack, holding the bottom half of 'far' in reverse order, along with the top half of 'far'. This is O(n).
+func splitStack[T any](far *Stack[T]) (*Stack[T], *Stack[T]) {
+	top := make([]T, far.Size()/2)
+	for i := range top {
+		top[i] = far.Peek()
+		far = far.Pop()
+	}
+
+	near := far.Reverse()
+	far = nil
+	for i := len(top) - 1; i >= 0; i-- {
+		far = far.Push(top[i])
+	}
+	return near, far
+}
+
+func (d *Deque[T]) MarshalJSON() ([]byte, error) {
+	front, back := d.stacks()
+	buf := make([]T, 0, d.Size())
+	for ; !front.IsEmpty(); front = front.Pop() {
+		buf = append(buf, front.Peek())
+	}
+	for back = back.Reverse(); !back.IsEmpty(); back = back.Pop() {
+		buf = append(buf, back.Peek())
+	}
+	return json.Marshal(buf)
+}
+
+func (d *Deque[T]) UnmarshalJSON(bytes []byte) error {
+	var tmp *Deque[T]
+	var buf []T
+	err := json.Unmarshal(bytes, &buf)
+	if err != nil {
+		return err
+	}
+	for _, b := range buf {
+		tmp = tmp.PushBack(b)
+	}
+	if tmp == nil {
+		tmp = &Deque[T]{}
+	}
+	*d = *tmp
+	return nil
+}
+
+// EmptyDeque returns a new empty deque.
+func EmptyDeque[T any]() *Deque[T] {
+	return nil
+}
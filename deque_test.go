@@ -0,0 +1,129 @@
+package persistent
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func dequeElems[T any](d *Deque[T]) []T {
+	var ret []T
+	var value T
+	for !d.IsEmpty() {
+		value, d = d.PopFront()
+		ret = append(ret, value)
+	}
+	return ret
+}
+
+func TestDequePushPop(t *testing.T) {
+	d := EmptyDeque[int]().PushBack(2).PushFront(1).PushBack(3)
+	require.Equal(t, 3, d.Size())
+	require.Equal(t, 1, d.PeekFront())
+	require.Equal(t, 3, d.PeekBack())
+
+	v, rest := d.PopBack()
+	require.Equal(t, 3, v)
+	require.Equal(t, []int{1, 2}, dequeElems(rest))
+
+	v, rest = d.PopFront()
+	require.Equal(t, 1, v)
+	require.Equal(t, []int{2, 3}, dequeElems(rest))
+	require.Equal(t, []int{1, 2, 3}, dequeElems(d))
+}
+
+func TestDequeEmpty(t *testing.T) {
+	d := EmptyDeque[int]()
+	require.True(t, d.IsEmpty())
+	require.Equal(t, 0, d.Size())
+	require.Equal(t, 0, d.PeekFront())
+	require.Equal(t, 0, d.PeekBack())
+
+	v, rest := d.PopFront()
+	require.Equal(t, 0, v)
+	require.Nil(t, rest)
+	v, rest = d.PopBack()
+	require.Equal(t, 0, v)
+	require.Nil(t, rest)
+
+	_, rest = d.PushFront(1).PopBack()
+	require.True(t, rest.IsEmpty())
+}
+
+func TestDequeSingleSide(t *testing.T) {
+	var d *Deque[int]
+	for i := 0; i < 100; i++ {
+		d = d.PushBack(i)
+	}
+	require.Equal(t, 0, d.PeekFront())
+	require.Equal(t, 99, d.PeekBack())
+
+	var v int
+	for i := 0; i < 100; i++ {
+		v, d = d.PopFront()
+		require.Equal(t, i, v)
+		if !d.IsEmpty() {
+			require.Equal(t, 99, d.PeekBack())
+		}
+	}
+	require.True(t, d.IsEmpty())
+}
+
+func TestDequeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(22))
+	deques := []*Deque[int]{nil}
+	models := [][]int{nil}
+	for i := 0; i < 5000; i++ {
+		j := r.Intn(len(deques))
+		d, model := deques[j], append([]int(nil), models[j]...)
+		switch r.Intn(4) {
+		case 0:
+			d = d.PushFront(i)
+			model = append([]int{i}, model...)
+		case 1:
+			d = d.PushBack(i)
+			model = append(model, i)
+		case 2:
+			var v int
+			v, d = d.PopFront()
+			if len(model) != 0 {
+				require.Equal(t, model[0], v)
+				model = model[1:]
+			}
+		case 3:
+			var v int
+			v, d = d.PopBack()
+			if len(model) != 0 {
+				require.Equal(t, model[len(model)-1], v)
+				model = model[:len(model)-1]
+			}
+		}
+
+		require.Equal(t, len(model), d.Size())
+		if len(model) != 0 {
+			require.Equal(t, model[0], d.PeekFront())
+			require.Equal(t, model[len(model)-1], d.PeekBack())
+			require.Equal(t, model, dequeElems(d))
+		}
+		deques = append(deques, d)
+		models = append(models, model)
+	}
+}
+
+func TestJsonDeque(t *testing.T) {
+	d := EmptyDeque[int]().PushBack(2).PushBack(3).PushFront(1)
+	bytes, err := json.Marshal(d)
+	require.NoError(t, err)
+	require.JSONEq(t, `[1, 2, 3]`, string(bytes))
+
+	var actual *Deque[int]
+	err = json.Unmarshal(bytes, &actual)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, dequeElems(actual))
+	require.Equal(t, 3, actual.PeekBack())
+
+	err = json.Unmarshal([]byte(`[]`), &actual)
+	require.NoError(t, err)
+	require.True(t, actual.IsEmpty())
+}
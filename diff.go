@@ -0,0 +1,275 @@
+package persistent
+
+import (
+	"golang.org/x/exp/constraints"
+	"reflect"
+)
+
+// ChangeKind identifies the kind of a Change.
+type ChangeKind int
+
+const (
+	// Added indicates a key found only in the newer version.
+	Added ChangeKind = iota
+
+	// Removed indicates a key found only in the older version.
+	Removed
+
+	// Changed indicates a key found in both versions with different values.
+	Changed
+)
+
+// Change describes a difference between two versions of a tree.
+type Change[K any, V any] struct {
+	// Kind is the kind of the change.
+	Kind ChangeKind
+
+	// Key is the key that changed.
+	Key K
+
+	// Old is the value in the older version. It is the zero value for V when Kind is Added.
+	Old V
+
+	// New is the value in the newer version. It is the zero value for V when Kind is Removed.
+	New V
+}
+
+// diffEntry is an entry on one of the explicit stacks used by the diff iterators. It stands either for a single node
+// or, if subtree is true, for the whole subtree rooted at that node.
+type diffEntry[N any] struct {
+	node    N
+	subtree bool
+}
+
+// deepEqual is the default value comparison for Diff, DiffEx, Merge3 and Merge3Ex.
+func deepEqual[V any](a, b V) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// TreeDiffIterator iterates over the changes between two versions of a Tree. See Diff.
+type TreeDiffIterator[K constraints.Ordered, V any] struct {
+	before  []diffEntry[*Tree[K, V]]
+	after   []diffEntry[*Tree[K, V]]
+	eq      func(a, b V) bool
+	current Change[K, V]
+	valid   bool
+}
+
+// Diff returns an iterator over the changes needed to turn 'before' into 'after', in increasing key order. Keys found
+// in both trees are reported as Changed unless eq(old, new) returns true. If eq is nil, values are compared with
+// reflect.DeepEqual.
+//
+// Subtrees shared by both versions are skipped without being visited, so when 'after' was derived from 'before' by a
+// series of updates, the cost of a full iteration grows with the number of updates rather than the size of the trees.
+func Diff[K constraints.Ordered, V any](
+	before *Tree[K, V],
+	after *Tree[K, V],
+	eq func(a, b V) bool,
+) Iterator[Change[K, V]] {
+	if eq == nil {
+		eq = deepEqual[V]
+	}
+	ret := &TreeDiffIterator[K, V]{eq: eq}
+	ret.before = pushDiffEntry(ret.before, before, true)
+	ret.after = pushDiffEntry(ret.after, after, true)
+	return ret
+}
+
+func (i *TreeDiffIterator[K, V]) Next() bool {
+	for {
+		if len(i.before) == 0 && len(i.after) == 0 {
+			i.valid = false
+			return false
+		}
+
+		var a, b diffEntry[*Tree[K, V]]
+		if len(i.before) != 0 {
+			a = i.before[len(i.before)-1]
+		}
+		if len(i.after) != 0 {
+			b = i.after[len(i.after)-1]
+		}
+
+		if a.subtree && b.subtree && a.node == b.node {
+			i.before = i.before[:len(i.before)-1]
+			i.after = i.after[:len(i.after)-1]
+			continue
+		}
+
+		if a.subtree && (!b.subtree || a.node.Height() >= b.node.Height()) {
+			i.before = expandDiffEntry(i.before)
+			continue
+		}
+
+		if b.subtree {
+			i.after = expandDiffEntry(i.after)
+			continue
+		}
+
+		if b.node == nil || (a.node != nil && a.node.key < b.node.key) {
+			i.before = i.before[:len(i.before)-1]
+			i.current = Change[K, V]{Kind: Removed, Key: a.node.key, Old: a.node.value}
+			i.valid = true
+			return true
+		}
+
+		if a.node == nil || b.node.key < a.node.key {
+			i.after = i.after[:len(i.after)-1]
+			i.current = Change[K, V]{Kind: Added, Key: b.node.key, New: b.node.value}
+			i.valid = true
+			return true
+		}
+
+		i.before = i.before[:len(i.before)-1]
+		i.after = i.after[:len(i.after)-1]
+		if a.node == b.node || i.eq(a.node.value, b.node.value) {
+			continue
+		}
+		i.current = Change[K, V]{Kind: Changed, Key: a.node.key, Old: a.node.value, New: b.node.value}
+		i.valid = true
+		return true
+	}
+}
+
+func (i *TreeDiffIterator[K, V]) Current() Change[K, V] {
+	if !i.valid {
+		panic("invalid iterator position")
+	}
+	return i.current
+}
+
+// pushDiffEntry pushes n onto the stack, unless n is an empty subtree.
+func pushDiffEntry[K constraints.Ordered, V any](
+	stack []diffEntry[*Tree[K, V]],
+	n *Tree[K, V],
+	subtree bool,
+) []diffEntry[*Tree[K, V]] {
+	if n.IsEmpty() {
+		return stack
+	}
+	return append(stack, diffEntry[*Tree[K, V]]{node: n, subtree: subtree})
+}
+
+// expandDiffEntry replaces the subtree on top of the stack with its right subtree, its root node and its left
+// subtree, so that the left subtree is visited first.
+func expandDiffEntry[K constraints.Ordered, V any](stack []diffEntry[*Tree[K, V]]) []diffEntry[*Tree[K, V]] {
+	n := stack[len(stack)-1].node
+	stack = stack[:len(stack)-1]
+	stack = pushDiffEntry(stack, n.right, true)
+	stack = pushDiffEntry(stack, n, false)
+	return pushDiffEntry(stack, n.left, true)
+}
+
+// TreeExDiffIterator iterates over the changes between two versions of a TreeEx. See DiffEx.
+type TreeExDiffIterator[K Ordered[K], V any] struct {
+	before  []diffEntry[*TreeEx[K, V]]
+	after   []diffEntry[*TreeEx[K, V]]
+	eq      func(a, b V) bool
+	current Change[K, V]
+	valid   bool
+}
+
+// DiffEx returns an iterator over the changes needed to turn 'before' into 'after', in increasing key order. Keys found
+// in both trees are reported as Changed unless eq(old, new) returns true. If eq is nil, values are compared with
+// reflect.DeepEqual.
+//
+// Subtrees shared by both versions are skipped without being visited, so when 'after' was derived from 'before' by a
+// series of updates, the cost of a full iteration grows with the number of updates rather than the size of the trees.
+func DiffEx[K Ordered[K], V any](
+	before *TreeEx[K, V],
+	after *TreeEx[K, V],
+	eq func(a, b V) bool,
+) Iterator[Change[K, V]] {
+	if eq == nil {
+		eq = deepEqual[V]
+	}
+	ret := &TreeExDiffIterator[K, V]{eq: eq}
+	ret.before = pushDiffEntryEx(ret.before, before, true)
+	ret.after = pushDiffEntryEx(ret.after, after, true)
+	return ret
+}
+
+func (i *TreeExDiffIterator[K, V]) Next() bool {
+	for {
+		if len(i.before) == 0 && len(i.after) == 0 {
+			i.valid = false
+			return false
+		}
+
+		var a, b diffEntry[*TreeEx[K, V]]
+		if len(i.before) != 0 {
+			a = i.before[len(i.before)-1]
+		}
+		if len(i.after) != 0 {
+			b = i.after[len(i.after)-1]
+		}
+
+		if a.subtree && b.subtree && a.node == b.node {
+			i.before = i.before[:len(i.before)-1]
+			i.after = i.after[:len(i.after)-1]
+			continue
+		}
+
+		if a.subtree && (!b.subtree || a.node.Height() >= b.node.Height()) {
+			i.before = expandDiffEntryEx(i.before)
+			continue
+		}
+
+		if b.subtree {
+			i.after = expandDiffEntryEx(i.after)
+			continue
+		}
+
+		if b.node == nil || (a.node != nil && a.node.key.Less(b.node.key)) {
+			i.before = i.before[:len(i.before)-1]
+			i.current = Change[K, V]{Kind: Removed, Key: a.node.key, Old: a.node.value}
+			i.valid = true
+			return true
+		}
+
+		if a.node == nil || b.node.key.Less(a.node.key) {
+			i.after = i.after[:len(i.after)-1]
+			i.current = Change[K, V]{Kind: Added, Key: b.node.key, New: b.node.value}
+			i.valid = true
+			return true
+		}
+
+		i.before = i.before[:len(i.before)-1]
+		i.after = i.after[:len(i.after)-1]
+		if a.node == b.node || i.eq(a.node.value, b.node.value) {
+			continue
+		}
+		i.current = Change[K, V]{Kind: Changed, Key: a.node.key, Old: a.node.value, New: b.node.value}
+		i.valid = true
+		return true
+	}
+}
+
+func (i *TreeExDiffIterator[K, V]) Current() Change[K, V] {
+	if !i.valid {
+		panic("invalid iterator position")
+	}
+	return i.current
+}
+
+// pushDiffEntryEx pushes n onto the stack, unless n is an empty subtree.
+func pushDiffEntryEx[K Ordered[K], V any](
+	stack []diffEntry[*TreeEx[K, V]],
+	n *TreeEx[K, V],
+	subtree bool,
+) []diffEntry[*TreeEx[K, V]] {
+	if n.IsEmpty() {
+		return stack
+	}
+	return append(stack, diffEntry[*TreeEx[K, V]]{node: n, subtree: subtree})
+}
+
+// expandDiffEntryEx replaces the subtree on top of the stack with its right subtree, its root node and its left
+// subtree, so that the left subtree is visited first.
+func expandDiffEntryEx[K Ordered[K], V any](stack []diffEntry[*TreeEx[K, V]]) []diffEntry[*TreeEx[K, V]] {
+	n := stack[len(stack)-1].node
+	stack = stack[:len(stack)-1]
+	stack = pushDiffEntryEx(stack, n.right, true)
+	stack = pushDiffEntryEx(stack, n, false)
+	return pushDiffEntryEx(stack, n.left, true)
+}
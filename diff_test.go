@@ -0,0 +1,115 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func collectChanges[K any, V any](iter Iterator[Change[K, V]]) []Change[K, V] {
+	var ret []Change[K, V]
+	for iter.Next() {
+		ret = append(ret, iter.Current())
+	}
+	return ret
+}
+
+func TestDiff(t *testing.T) {
+	before := treeOfRange(0, 100, 1)
+	after := before.Update(10, 110).Delete(20).Update(200, 200).Update(30, 30)
+
+	changes := collectChanges(Diff(before, after, func(a, b int) bool { return a == b }))
+	require.Equal(t, []Change[int, int]{
+		{Kind: Changed, Key: 10, Old: 10, New: 110},
+		{Kind: Removed, Key: 20, Old: 20},
+		{Kind: Added, Key: 200, New: 200},
+	}, changes)
+}
+
+func TestDiffNilEq(t *testing.T) {
+	before := treeOfRange(0, 100, 1)
+	after := before.Update(30, 31).Delete(40).Update(100, 100)
+
+	changes := collectChanges(Diff[int, int](before, after, nil))
+	require.Equal(t, []Change[int, int]{
+		{Kind: Changed, Key: 30, Old: 30, New: 31},
+		{Kind: Removed, Key: 40, Old: 40},
+		{Kind: Added, Key: 100, New: 100},
+	}, changes)
+
+	// Values that are equal but not identical are not reported.
+	lists := EmptyTree[int, []int]().Update(1, []int{1}).Update(2, []int{2})
+	listChanges := collectChanges(Diff[int, []int](lists, lists.Update(1, []int{1}).Update(2, []int{3}), nil))
+	require.Equal(t, []Change[int, []int]{
+		{Kind: Changed, Key: 2, Old: []int{2}, New: []int{3}},
+	}, listChanges)
+}
+
+func TestDiffEmpty(t *testing.T) {
+	tree := treeOfRange(0, 5, 1)
+
+	require.Empty(t, collectChanges(Diff[int, int](nil, &Tree[int, int]{}, nil)))
+	require.Empty(t, collectChanges(Diff[int, int](tree, tree, nil)))
+	require.Equal(t, []int{0, 1, 2, 3, 4}, changeKeys(collectChanges(Diff[int, int](nil, tree, nil)), Added))
+	require.Equal(t, []int{0, 1, 2, 3, 4}, changeKeys(collectChanges(Diff[int, int](tree, nil, nil)), Removed))
+}
+
+func TestDiffRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	before := treeOfRange(0, 1000, 2)
+	after := before
+	expected := map[int]Change[int, int]{}
+	for i := 0; i < 200; i++ {
+		key := r.Intn(1200)
+		if r.Intn(3) == 0 {
+			after = after.Delete(key)
+		} else {
+			after = after.Update(key, r.Intn(4))
+		}
+	}
+	for _, key := range treeKeys(before) {
+		v := before.Find(key)
+		if w, ok := after.FindOpt(key); !ok {
+			expected[key] = Change[int, int]{Kind: Removed, Key: key, Old: v}
+		} else if w != v {
+			expected[key] = Change[int, int]{Kind: Changed, Key: key, Old: v, New: w}
+		}
+	}
+	for _, key := range treeKeys(after) {
+		if !before.Contains(key) {
+			expected[key] = Change[int, int]{Kind: Added, Key: key, New: after.Find(key)}
+		}
+	}
+
+	changes := collectChanges(Diff(before, after, func(a, b int) bool { return a == b }))
+	require.Len(t, changes, len(expected))
+	for i, c := range changes {
+		if i > 0 {
+			require.Less(t, changes[i-1].Key, c.Key)
+		}
+		require.Equal(t, expected[c.Key], c)
+	}
+}
+
+func TestDiffEx(t *testing.T) {
+	before := treeExOfRange(0, 100, 1)
+	after := before.Update(Int(10), 110).Delete(Int(20)).Update(Int(200), 200)
+
+	changes := collectChanges(DiffEx(before, after, func(a, b int) bool { return a == b }))
+	require.Equal(t, []Change[Int, int]{
+		{Kind: Changed, Key: 10, Old: 10, New: 110},
+		{Kind: Removed, Key: 20, Old: 20},
+		{Kind: Added, Key: 200, New: 200},
+	}, changes)
+	require.Empty(t, collectChanges(DiffEx[Int, int](before, before, nil)))
+}
+
+func changeKeys[K any, V any](changes []Change[K, V], kind ChangeKind) []K {
+	var ret []K
+	for _, c := range changes {
+		if c.Kind == kind {
+			ret = append(ret, c.Key)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,194 @@
+package persistent
+
+// Interval defines a half-open interval [Start, End) over an ordered type.
+type Interval[P Ordered[P]] struct {
+	// Start is the first point in the interval.
+	Start P
+
+	// End is the first point after the interval.
+	End P
+}
+
+// IntervalTree implements a persistent map from intervals to values that supports efficient queries for the intervals
+// overlapping a given interval or containing a given point. Entries are ordered by start, then by end.
+//
+// Note: A nil *IntervalTree is a valid empty tree.
+//
+// Like TreeEx, an IntervalTree is immutable, uses structural sharing, and may be accessed from multiple go-routines
+// without synchronization. It is implemented as an AugmentedTreeEx whose nodes cache the greatest end of their subtree.
+type IntervalTree[P Ordered[P], V any] struct {
+	tree *AugmentedTreeEx[Interval[P], V, Option[P]]
+}
+
+// IntervalTreeIterator defines an iterator over the results of an IntervalTree query.
+type IntervalTreeIterator[P Ordered[P], V any] struct {
+	stack   []*augmentedNodeEx[Interval[P], V, Option[P]]
+	current *augmentedNodeEx[Interval[P], V, Option[P]]
+
+	// Only intervals that end after lo and start before hi are visited. If inclusive is true, intervals that start at
+	// hi are visited as well.
+	lo        P
+	hi        P
+	inclusive bool
+}
+
+// Less orders intervals by start, then by end.
+func (i Interval[P]) Less(rhs Interval[P]) bool {
+	if i.Start.Less(rhs.Start) {
+		return true
+	}
+	if rhs.Start.Less(i.Start) {
+		return false
+	}
+	return i.End.Less(rhs.End)
+}
+
+// Overlaps returns true if i and other have at least one point in common.
+func (i Interval[P]) Overlaps(other Interval[P]) bool {
+	return i.Start.Less(other.End) && other.Start.Less(i.End)
+}
+
+// Contains returns true if p lies within i.
+func (i Interval[P]) Contains(p P) bool {
+	return !p.Less(i.Start) && p.Less(i.End)
+}
+
+// Insert returns a new tree with the value for 'interval' set to 'value'. Insert panics if the interval is empty, that
+// is, if its end is not greater than its start.
+func (t *IntervalTree[P, V]) Insert(interval Interval[P], value V) *IntervalTree[P, V] {
+	if !interval.Start.Less(interval.End) {
+		panic("insert: interval end must be greater than its start")
+	}
+
+	tree := t.augmented()
+	if tree == nil {
+		tree = NewAugmentedTreeEx(Monoid[Pair[Interval[P], V], Option[P]]{
+			Measure: func(p Pair[Interval[P], V]) Option[P] {
+				return Some(p.Key.End)
+			},
+			Combine: func(a, b Option[P]) Option[P] {
+				if !a.Ok || (b.Ok && a.Value.Less(b.Value)) {
+					return b
+				}
+				return a
+			},
+		})
+	}
+	return &IntervalTree[P, V]{tree: tree.Update(interval, value)}
+}
+
+// Delete returns a new tree with the entry for 'interval' removed. If there is no such entry, t is returned unchanged.
+func (t *IntervalTree[P, V]) Delete(interval Interval[P]) *IntervalTree[P, V] {
+	tree := t.augmented().Delete(interval)
+	if tree == t.augmented() {
+		return t
+	}
+	if tree.IsEmpty() {
+		return nil
+	}
+	return &IntervalTree[P, V]{tree: tree}
+}
+
+// Find returns the value associated with 'interval'. Will return a zero value if no such item exists.
+func (t *IntervalTree[P, V]) Find(interval Interval[P]) V {
+	return t.augmented().Find(interval)
+}
+
+// FindOpt returns the value associated with 'interval'. Returns true if found; otherwise false.
+func (t *IntervalTree[P, V]) FindOpt(interval Interval[P]) (V, bool) {
+	return t.augmented().FindOpt(interval)
+}
+
+// Contains returns true if the tree contains an entry for 'interval'.
+func (t *IntervalTree[P, V]) Contains(interval Interval[P]) bool {
+	return t.augmented().Contains(interval)
+}
+
+// Size returns the number of entries in the tree.
+func (t *IntervalTree[P, V]) Size() int {
+	return t.augmented().Size()
+}
+
+// IsEmpty returns true iif the tree is empty.
+func (t *IntervalTree[P, V]) IsEmpty() bool {
+	return t.augmented().IsEmpty()
+}
+
+// Iter returns an iterator over every entry in the tree, ordered by start, then by end.
+func (t *IntervalTree[P, V]) Iter() Iterator[Pair[Interval[P], V]] {
+	return t.augmented().Iter()
+}
+
+// Overlapping returns an iterator over the entries whose intervals overlap 'interval', ordered by start, then by end.
+//
+// Subtrees in which no interval ends after the start of 'interval' are skipped, so Overlapping is O(log(N) + K),
+// where K is the number of matching entries, when the matches are adjacent in start order. In the worst case, when
+// every match is surrounded by entries that do not match, each match costs its own descent and Overlapping is
+// O(min(N, (K+1)*log(N))).
+func (t *IntervalTree[P, V]) Overlapping(interval Interval[P]) Iterator[Pair[Interval[P], V]] {
+	ret := &IntervalTreeIterator[P, V]{lo: interval.Start, hi: interval.End}
+	ret.pushLeft(t.root())
+	return ret
+}
+
+// Containing returns an iterator over the entries whose intervals contain 'point', ordered by start, then by end. Like
+// Overlapping, Containing is O(log(N) + K) when the matches are adjacent in start order and O(min(N, (K+1)*log(N)))
+// in the worst case.
+func (t *IntervalTree[P, V]) Containing(point P) Iterator[Pair[Interval[P], V]] {
+	ret := &IntervalTreeIterator[P, V]{lo: point, hi: point, inclusive: true}
+	ret.pushLeft(t.root())
+	return ret
+}
+
+func (t *IntervalTree[P, V]) augmented() *AugmentedTreeEx[Interval[P], V, Option[P]] {
+	if t == nil {
+		return nil
+	}
+	return t.tree
+}
+
+func (t *IntervalTree[P, V]) root() *augmentedNodeEx[Interval[P], V, Option[P]] {
+	return t.augmented().node()
+}
+
+func (i *IntervalTreeIterator[P, V]) Next() bool {
+	if i.current != nil {
+		i.pushLeft(i.current.right)
+		i.current = nil
+	}
+
+	for len(i.stack) != 0 {
+		n := i.stack[len(i.stack)-1]
+		i.stack = i.stack[:len(i.stack)-1]
+
+		// Entries are ordered by start, so no later entry can start before hi either.
+		if i.hi.Less(n.key.Start) || (!i.inclusive && !n.key.Start.Less(i.hi)) {
+			i.stack = nil
+			return false
+		}
+
+		if !i.lo.Less(n.key.End) {
+			i.pushLeft(n.right)
+			continue
+		}
+		i.current = n
+		return true
+	}
+	return false
+}
+
+func (i *IntervalTreeIterator[P, V]) Current() Pair[Interval[P], V] {
+	if i.current == nil {
+		panic("invalid iterator position")
+	}
+	return Pair[Interval[P], V]{Key: i.current.key, Value: i.current.value}
+}
+
+// pushLeft pushes n and the left spine below it onto the stack, skipping subtrees in which every interval ends at or
+// before lo.
+func (i *IntervalTreeIterator[P, V]) pushLeft(n *augmentedNodeEx[Interval[P], V, Option[P]]) {
+	for n != nil && i.lo.Less(n.summary.Value) {
+		i.stack = append(i.stack, n)
+		n = n.left
+	}
+}
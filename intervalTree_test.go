@@ -0,0 +1,168 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+func intervalOf(start, end int) Interval[Int] {
+	return Interval[Int]{Start: Int(start), End: Int(end)}
+}
+
+func collectIntervals[V any](iter Iterator[Pair[Interval[Int], V]]) []Interval[Int] {
+	var ret []Interval[Int]
+	for iter.Next() {
+		ret = append(ret, iter.Current().Key)
+	}
+	return ret
+}
+
+func TestIntervalTreeQueries(t *testing.T) {
+	var tree *IntervalTree[Int, string]
+	require.True(t, tree.IsEmpty())
+	require.Empty(t, collectIntervals(tree.Containing(1)))
+
+	tree = tree.Insert(intervalOf(0, 10), "a").
+		Insert(intervalOf(5, 7), "b").
+		Insert(intervalOf(8, 20), "c").
+		Insert(intervalOf(10, 12), "d").
+		Insert(intervalOf(30, 40), "e")
+	require.Equal(t, 5, tree.Size())
+	require.Equal(t, "c", tree.Find(intervalOf(8, 20)))
+
+	require.Equal(t, []Interval[Int]{intervalOf(0, 10), intervalOf(5, 7)}, collectIntervals(tree.Containing(5)))
+	require.Equal(t, []Interval[Int]{intervalOf(8, 20), intervalOf(10, 12)}, collectIntervals(tree.Containing(10)))
+	require.Empty(t, collectIntervals(tree.Containing(25)))
+	require.Empty(t, collectIntervals(tree.Containing(40)))
+
+	require.Equal(
+		t,
+		[]Interval[Int]{intervalOf(0, 10), intervalOf(8, 20), intervalOf(10, 12)},
+		collectIntervals(tree.Overlapping(intervalOf(9, 11))),
+	)
+	require.Equal(t, []Interval[Int]{intervalOf(8, 20)}, collectIntervals(tree.Overlapping(intervalOf(19, 30))))
+	require.Empty(t, collectIntervals(tree.Overlapping(intervalOf(20, 30))))
+
+	deleted := tree.Delete(intervalOf(0, 10))
+	require.Equal(t, []Interval[Int]{intervalOf(5, 7)}, collectIntervals(deleted.Containing(5)))
+	require.Equal(t, 5, tree.Size())
+	require.Same(t, deleted, deleted.Delete(intervalOf(0, 10)))
+
+	require.Panics(t, func() { tree.Insert(intervalOf(3, 3), "empty") })
+}
+
+func TestIntervalTreeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	var tree *IntervalTree[Int, int]
+	intervals := map[Interval[Int]]bool{}
+	for i := 0; i < 400; i++ {
+		start := r.Intn(1000)
+		interval := intervalOf(start, start+1+r.Intn(50))
+		if r.Intn(5) == 0 && len(intervals) != 0 {
+			for k := range intervals {
+				interval = k
+				break
+			}
+			tree = tree.Delete(interval)
+			delete(intervals, interval)
+		} else {
+			tree = tree.Insert(interval, i)
+			intervals[interval] = true
+		}
+	}
+	require.Equal(t, len(intervals), tree.Size())
+
+	for i := 0; i < 100; i++ {
+		start := r.Intn(1100) - 50
+		query := intervalOf(start, start+1+r.Intn(30))
+
+		var expected, expectedContaining []Interval[Int]
+		iter := tree.Iter()
+		for iter.Next() {
+			if iter.Current().Key.Overlaps(query) {
+				expected = append(expected, iter.Current().Key)
+			}
+			if iter.Current().Key.Contains(query.Start) {
+				expectedContaining = append(expectedContaining, iter.Current().Key)
+			}
+		}
+		require.Equal(t, expected, collectIntervals(tree.Overlapping(query)))
+		require.Equal(t, expectedContaining, collectIntervals(tree.Containing(query.Start)))
+	}
+}
+
+// countedPoint is an interval endpoint that counts its comparisons in 'calls'. Queries compare a bounded number of
+// endpoints at each node they visit, so the count measures the number of nodes visited.
+type countedPoint struct {
+	value int
+	calls *int
+}
+
+func (p countedPoint) Less(rhs countedPoint) bool {
+	*p.calls++
+	return p.value < rhs.value
+}
+
+// countedIntervalTree returns a tree of n short intervals [10*i, 10*i+1). If step is positive, every step-th interval
+// ends after 10*n instead, so that it contains every point from its start up to 10*n.
+func countedIntervalTree(n, step int, calls *int) *IntervalTree[countedPoint, int] {
+	point := func(v int) countedPoint { return countedPoint{value: v, calls: calls} }
+	var tree *IntervalTree[countedPoint, int]
+	for i := 0; i < n; i++ {
+		end := 10*i + 1
+		if step > 0 && i%step == 0 {
+			end = 10*n + 10
+		}
+		tree = tree.Insert(Interval[countedPoint]{Start: point(10 * i), End: point(end)}, i)
+	}
+	return tree
+}
+
+func countMatches[P Ordered[P], V any](iter Iterator[Pair[Interval[P], V]]) int {
+	ret := 0
+	for iter.Next() {
+		ret++
+	}
+	return ret
+}
+
+func TestIntervalTreeQueryCostAdjacentMatches(t *testing.T) {
+	const n = 1 << 12
+	calls := 0
+	tree := countedIntervalTree(n, 0, &calls)
+	logN := bits.Len(n)
+
+	for _, k := range []int{0, 1, 16, 256} {
+		lo := countedPoint{value: 10 * 1000, calls: &calls}
+		hi := countedPoint{value: 10*(1000+k) - 5, calls: &calls}
+		if k == 0 {
+			hi = countedPoint{value: lo.value + 5, calls: &calls}
+			lo.value += 2
+		}
+
+		calls = 0
+		require.Equal(t, k, countMatches(tree.Overlapping(Interval[countedPoint]{Start: lo, End: hi})))
+		require.LessOrEqual(t, calls, 5*(k+logN), "k = %d", k)
+	}
+}
+
+func TestIntervalTreeQueryCostScatteredMatches(t *testing.T) {
+	const n = 1 << 12
+	logN := bits.Len(n)
+
+	for _, k := range []int{1, 16, 256, n} {
+		calls := 0
+		tree := countedIntervalTree(n, n/k, &calls)
+		point := countedPoint{value: 10 * n, calls: &calls}
+
+		calls = 0
+		require.Equal(t, k, countMatches(tree.Containing(point)))
+		bound := (k + 1) * logN
+		if bound > n {
+			bound = n
+		}
+		require.LessOrEqual(t, calls, 5*bound, "k = %d", k)
+	}
+}
@@ -0,0 +1,173 @@
+package persistent
+
+import "golang.org/x/exp/constraints"
+
+// Conflict describes a key changed differently by both sides of a three-way merge. Each Option is empty if the key
+// is absent from the corresponding version.
+type Conflict[K any, V any] struct {
+	Key  K
+	Base Option[V]
+	A    Option[V]
+	B    Option[V]
+}
+
+// Resolver picks the merged entry for a key changed differently by both sides of a three-way merge. Each Option is
+// empty if the key is absent from the corresponding version. Returning an empty merged Option removes the key from
+// the result. Returning ok == false reports a conflict instead.
+type Resolver[K any, V any] func(key K, base, a, b Option[V]) (merged Option[V], ok bool)
+
+// before returns the value of the changed key in the older version.
+func (c Change[K, V]) before() Option[V] {
+	if c.Kind == Added {
+		return Option[V]{}
+	}
+	return Some(c.Old)
+}
+
+// after returns the value of the changed key in the newer version.
+func (c Change[K, V]) after() Option[V] {
+	if c.Kind == Removed {
+		return Option[V]{}
+	}
+	return Some(c.New)
+}
+
+// Merge3 merges the changes made by 'a' and 'b' to their common ancestor 'base'. Changes made by only one side are
+// applied as is, as are identical changes made by both sides. For keys changed differently by both sides, resolve is
+// called to pick the merged entry; if resolve is nil or reports a conflict, the key keeps its value from base and a
+// Conflict is returned for it. Conflicts are returned in increasing key order.
+//
+// Values are compared with eq. If eq is nil, values are compared with reflect.DeepEqual. Subtrees shared by base and
+// either side are skipped without being visited, so the cost of a merge grows with the number of changes rather than
+// the size of the trees.
+func Merge3[K constraints.Ordered, V any](
+	base *Tree[K, V],
+	a *Tree[K, V],
+	b *Tree[K, V],
+	eq func(x, y V) bool,
+	resolve Resolver[K, V],
+) (*Tree[K, V], []Conflict[K, V]) {
+	if eq == nil {
+		eq = deepEqual[V]
+	}
+	if a == b || b == base {
+		return a, nil
+	}
+	if a == base {
+		return b, nil
+	}
+
+	var conflicts []Conflict[K, V]
+	ret := a.Builder()
+	ai, bi := Diff(base, a, eq), Diff(base, b, eq)
+	aOk, bOk := ai.Next(), bi.Next()
+	for bOk {
+		if aOk && ai.Current().Key < bi.Current().Key {
+			aOk = ai.Next()
+			continue
+		}
+
+		bc := bi.Current()
+		if !aOk || bc.Key < ai.Current().Key {
+			applyChange(ret, bc.Key, bc.after())
+			bOk = bi.Next()
+			continue
+		}
+
+		ac := ai.Current()
+		aVal, bVal := ac.after(), bc.after()
+		if aVal.Ok != bVal.Ok || (aVal.Ok && !eq(aVal.Value, bVal.Value)) {
+			var merged Option[V]
+			ok := false
+			if resolve != nil {
+				merged, ok = resolve(ac.Key, ac.before(), aVal, bVal)
+			}
+			if !ok {
+				conflicts = append(conflicts, Conflict[K, V]{Key: ac.Key, Base: ac.before(), A: aVal, B: bVal})
+				merged = ac.before()
+			}
+			applyChange(ret, ac.Key, merged)
+		}
+		aOk, bOk = ai.Next(), bi.Next()
+	}
+	return ret.Build(), conflicts
+}
+
+// applyChange sets the value of key to value, or deletes the key if value is empty.
+func applyChange[K constraints.Ordered, V any](builder *TreeBuilder[K, V], key K, value Option[V]) {
+	if value.Ok {
+		builder.Update(key, value.Value)
+	} else {
+		builder.Delete(key)
+	}
+}
+
+// Merge3Ex merges the changes made by 'a' and 'b' to their common ancestor 'base'. Changes made by only one side are
+// applied as is, as are identical changes made by both sides. For keys changed differently by both sides, resolve is
+// called to pick the merged entry; if resolve is nil or reports a conflict, the key keeps its value from base and a
+// Conflict is returned for it. Conflicts are returned in increasing key order.
+//
+// Values are compared with eq. If eq is nil, values are compared with reflect.DeepEqual. Subtrees shared by base and
+// either side are skipped without being visited, so the cost of a merge grows with the number of changes rather than
+// the size of the trees.
+func Merge3Ex[K Ordered[K], V any](
+	base *TreeEx[K, V],
+	a *TreeEx[K, V],
+	b *TreeEx[K, V],
+	eq func(x, y V) bool,
+	resolve Resolver[K, V],
+) (*TreeEx[K, V], []Conflict[K, V]) {
+	if eq == nil {
+		eq = deepEqual[V]
+	}
+	if a == b || b == base {
+		return a, nil
+	}
+	if a == base {
+		return b, nil
+	}
+
+	var conflicts []Conflict[K, V]
+	ret := a.Builder()
+	ai, bi := DiffEx(base, a, eq), DiffEx(base, b, eq)
+	aOk, bOk := ai.Next(), bi.Next()
+	for bOk {
+		if aOk && ai.Current().Key.Less(bi.Current().Key) {
+			aOk = ai.Next()
+			continue
+		}
+
+		bc := bi.Current()
+		if !aOk || bc.Key.Less(ai.Current().Key) {
+			applyChangeEx(ret, bc.Key, bc.after())
+			bOk = bi.Next()
+			continue
+		}
+
+		ac := ai.Current()
+		aVal, bVal := ac.after(), bc.after()
+		if aVal.Ok != bVal.Ok || (aVal.Ok && !eq(aVal.Value, bVal.Value)) {
+			var merged Option[V]
+			ok := false
+			if resolve != nil {
+				merged, ok = resolve(ac.Key, ac.before(), aVal, bVal)
+			}
+			if !ok {
+				conflicts = append(conflicts, Conflict[K, V]{Key: ac.Key, Base: ac.before(), A: aVal, B: bVal})
+				merged = ac.before()
+			}
+			applyChangeEx(ret, ac.Key, merged)
+		}
+		aOk, bOk = ai.Next(), bi.Next()
+	}
+	return ret.Build(), conflicts
+}
+
+// applyChangeEx sets the value of key to value, or deletes the key if value is empty.
+func applyChangeEx[K Ordered[K], V any](builder *TreeExBuilder[K, V], key K, value Option[V]) {
+	if value.Ok {
+		builder.Update(key, value.Value)
+	} else {
+		builder.Delete(key)
+	}
+}
@@ -0,0 +1,113 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func intEq(a, b int) bool {
+	return a == b
+}
+
+func TestMerge3(t *testing.T) {
+	base := treeOfRange(0, 100, 1)
+	a := base.Update(10, 110).Delete(20).Update(200, 200).Update(50, 150)
+	b := base.Update(30, 130).Delete(40).Update(300, 300).Update(50, 150).Delete(20)
+
+	merged, conflicts := Merge3(base, a, b, intEq, nil)
+	require.Empty(t, conflicts)
+	requireValidTree(t, merged)
+
+	expected := base.Update(10, 110).Delete(20).Update(30, 130).Delete(40).Update(50, 150).Update(200, 200).
+		Update(300, 300)
+	require.Equal(t, treeMap(expected), treeMap(merged))
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := treeOfRange(0, 10, 1)
+	a := base.Update(1, 11).Delete(2).Update(20, 20)
+	b := base.Update(1, 21).Update(2, 22).Update(20, 30)
+
+	merged, conflicts := Merge3(base, a, b, intEq, nil)
+	require.Equal(t, []Conflict[int, int]{
+		{Key: 1, Base: Some(1), A: Some(11), B: Some(21)},
+		{Key: 2, Base: Some(2), B: Some(22)},
+		{Key: 20, A: Some(20), B: Some(30)},
+	}, conflicts)
+	require.Equal(t, treeMap(base), treeMap(merged))
+}
+
+func TestMerge3Resolver(t *testing.T) {
+	base := treeOfRange(0, 10, 1)
+	a := base.Update(1, 11).Delete(2).Update(3, 13)
+	b := base.Update(1, 21).Update(2, 22).Update(3, 23)
+
+	var calls []int
+	resolve := func(key int, base, a, b Option[int]) (Option[int], bool) {
+		calls = append(calls, key)
+		switch key {
+		case 1:
+			return Some(a.Value + b.Value), true
+		case 2:
+			return Option[int]{}, true
+		default:
+			return Option[int]{}, false
+		}
+	}
+
+	merged, conflicts := Merge3(base, a, b, intEq, resolve)
+	require.Equal(t, []int{1, 2, 3}, calls)
+	require.Equal(t, []Conflict[int, int]{{Key: 3, Base: Some(3), A: Some(13), B: Some(23)}}, conflicts)
+	require.Equal(t, treeMap(base.Update(1, 32).Delete(2)), treeMap(merged))
+}
+
+func TestMerge3Shortcuts(t *testing.T) {
+	base := treeOfRange(0, 10, 1)
+	a := base.Update(1, 11)
+
+	merged, conflicts := Merge3(base, a, base, intEq, nil)
+	require.Same(t, a, merged)
+	require.Empty(t, conflicts)
+
+	merged, _ = Merge3(base, base, a, intEq, nil)
+	require.Same(t, a, merged)
+
+	merged, _ = Merge3(nil, nil, a, intEq, nil)
+	require.Same(t, a, merged)
+
+}
+
+func TestMerge3NilEq(t *testing.T) {
+	base := treeOfRange(0, 10, 1)
+	a := base.Update(1, 11).Update(2, 12)
+	b := base.Update(2, 12).Update(3, 13).Delete(4)
+
+	merged, conflicts := Merge3(base, a, b, nil, nil)
+	require.Empty(t, conflicts)
+	requireValidTree(t, merged)
+	require.Equal(t, treeMap(base.Update(1, 11).Update(2, 12).Update(3, 13).Delete(4)), treeMap(merged))
+
+	merged, conflicts = Merge3(base, a, base.Update(1, 21), nil, nil)
+	require.Equal(t, []Conflict[int, int]{{Key: 1, Base: Some(1), A: Some(11), B: Some(21)}}, conflicts)
+	require.Equal(t, treeMap(base.Update(2, 12)), treeMap(merged))
+
+	ex := treeExOfRange(0, 10, 1)
+	mergedEx, conflicts2 := Merge3Ex(ex, ex.Update(Int(1), 11), ex.Update(Int(2), 12), nil, nil)
+	require.Empty(t, conflicts2)
+	requireValidTreeEx(t, mergedEx)
+	require.Equal(t, 11, mergedEx.Find(Int(1)))
+	require.Equal(t, 12, mergedEx.Find(Int(2)))
+}
+
+func TestMerge3Ex(t *testing.T) {
+	base := treeExOfRange(0, 100, 1)
+	a := base.Update(Int(10), 110).Delete(Int(20))
+	b := base.Update(Int(30), 130).Update(Int(10), 210)
+
+	merged, conflicts := Merge3Ex(base, a, b, intEq, nil)
+	require.Equal(t, []Conflict[Int, int]{{Key: 10, Base: Some(10), A: Some(110), B: Some(210)}}, conflicts)
+	requireValidTreeEx(t, merged)
+	require.Equal(t, treeExKeys(base.Delete(Int(20))), treeExKeys(merged))
+	require.Equal(t, 10, merged.Find(Int(10)))
+	require.Equal(t, 130, merged.Find(Int(30)))
+}
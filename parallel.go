@@ -0,0 +1,296 @@
+package persistent
+
+import (
+	"golang.org/x/exp/constraints"
+	"runtime"
+	"sync"
+)
+
+// DefaultGrain is the subtree size below which parallel operations stop forking and fall back to sequential code when
+// ParallelOptions.Grain is not set.
+const DefaultGrain = 4096
+
+// ParallelOptions controls how parallel operations split their work. The zero value uses runtime.GOMAXPROCS(0)
+// workers and DefaultGrain.
+type ParallelOptions struct {
+	// Workers is the maximum number of goroutines working at once, including the calling goroutine. A value of 1 runs
+	// everything on the calling goroutine.
+	Workers int
+
+	// Grain is the minimum number of entries a subtree must hold for its children to be processed concurrently.
+	// Smaller subtrees are processed sequentially.
+	Grain int
+}
+
+// scheduler hands out a bounded number of extra goroutines to the recursive parallel operations. A nil scheduler runs
+// everything sequentially.
+type scheduler struct {
+	tokens chan struct{}
+	grain  int
+}
+
+func newScheduler(opts ParallelOptions) *scheduler {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	grain := opts.Grain
+	if grain <= 0 {
+		grain = DefaultGrain
+	}
+	if workers == 1 {
+		return nil
+	}
+	return &scheduler{
+		tokens: make(chan struct{}, workers-1),
+		grain:  grain,
+	}
+}
+
+// shouldFork reports whether work over 'size' entries is large enough to be split across goroutines.
+func (s *scheduler) shouldFork(size int) bool {
+	return s != nil && size >= s.grain
+}
+
+// fork runs a and b, running a on a new goroutine if a worker is free. It returns once both have finished, even if one
+// of them panics. The first panic raised by either of them is then re-raised on the calling goroutine.
+func (s *scheduler) fork(a, b func()) {
+	select {
+	case s.tokens <- struct{}{}:
+	default:
+		a()
+		b()
+		return
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var recovered any
+	capture := func() {
+		if r := recover(); r != nil {
+			once.Do(func() {
+				recovered = r
+			})
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.tokens
+			wg.Done()
+		}()
+		defer capture()
+		a()
+	}()
+	func() {
+		defer capture()
+		b()
+	}()
+	wg.Wait()
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+// ParallelFold summarizes the entries of n with m, splitting the work across goroutines at subtree boundaries. The
+// result is the same as folding m over the entries in increasing key order. Measure and Combine may be called
+// concurrently, so they must be safe for concurrent use.
+func ParallelFold[K constraints.Ordered, V any, S any](n *Tree[K, V], m Monoid[Pair[K, V], S], opts ParallelOptions) S {
+	return parallelFold(n, m, newScheduler(opts))
+}
+
+func parallelFold[K constraints.Ordered, V any, S any](n *Tree[K, V], m Monoid[Pair[K, V], S], s *scheduler) S {
+	if !s.shouldFork(n.Size()) {
+		ret := m.Identity
+		for it := n.Iter(); it.Next(); {
+			ret = m.Combine(ret, m.Measure(it.Current()))
+		}
+		return ret
+	}
+
+	var left, right S
+	s.fork(func() {
+		left = parallelFold(n.left, m, s)
+	}, func() {
+		right = parallelFold(n.right, m, s)
+	})
+	return m.Combine(m.Combine(left, m.Measure(n.pair())), right)
+}
+
+// ParallelMapValues is like MapValues, but splits the work across goroutines at subtree boundaries. f may be called
+// concurrently and in any order, so it must be safe for concurrent use.
+func ParallelMapValues[K constraints.Ordered, V any, W any](
+	n *Tree[K, V],
+	f func(key K, value V) W,
+	opts ParallelOptions,
+) *Tree[K, W] {
+	return parallelMapValues(n, f, newScheduler(opts))
+}
+
+func parallelMapValues[K constraints.Ordered, V any, W any](
+	n *Tree[K, V],
+	f func(key K, value V) W,
+	s *scheduler,
+) *Tree[K, W] {
+	if !s.shouldFork(n.Size()) {
+		return MapValues(n, f)
+	}
+
+	var left, right *Tree[K, W]
+	s.fork(func() {
+		left = parallelMapValues(n.left, f, s)
+	}, func() {
+		right = parallelMapValues(n.right, f, s)
+	})
+
+	return &Tree[K, W]{
+		left:   left,
+		right:  right,
+		key:    n.key,
+		value:  f(n.key, n.value),
+		size:   n.size,
+		height: n.height,
+	}
+}
+
+// ParallelUnion is like Union, but splits the work across goroutines at subtree boundaries. merge may be called
+// concurrently, so it must be safe for concurrent use.
+func (n *Tree[K, V]) ParallelUnion(
+	other *Tree[K, V],
+	merge func(key K, a, b V) V,
+	opts ParallelOptions,
+) *Tree[K, V] {
+	return n.union(other, merge, newScheduler(opts))
+}
+
+// ParallelIntersection is like Intersection, but splits the work across goroutines at subtree boundaries. combine may
+// be called concurrently, so it must be safe for concurrent use.
+func (n *Tree[K, V]) ParallelIntersection(
+	other *Tree[K, V],
+	combine func(key K, a, b V) V,
+	opts ParallelOptions,
+) *Tree[K, V] {
+	return n.intersection(other, combine, newScheduler(opts))
+}
+
+// ParallelDifference is like Difference, but splits the work across goroutines at subtree boundaries.
+func (n *Tree[K, V]) ParallelDifference(other *Tree[K, V], opts ParallelOptions) *Tree[K, V] {
+	return n.difference(other, newScheduler(opts))
+}
+
+// ParallelFoldEx summarizes the entries of n with m, splitting the work across goroutines at subtree boundaries. The
+// result is the same as folding m over the entries in increasing key order. Measure and Combine may be called
+// concurrently, so they must be safe for concurrent use.
+func ParallelFoldEx[K Ordered[K], V any, S any](n *TreeEx[K, V], m Monoid[Pair[K, V], S], opts ParallelOptions) S {
+	return parallelFoldEx(n, m, newScheduler(opts))
+}
+
+func parallelFoldEx[K Ordered[K], V any, S any](n *TreeEx[K, V], m Monoid[Pair[K, V], S], s *scheduler) S {
+	if !s.shouldFork(n.Size()) {
+		ret := m.Identity
+		for it := n.Iter(); it.Next(); {
+			ret = m.Combine(ret, m.Measure(it.Current()))
+		}
+		return ret
+	}
+
+	var left, right S
+	s.fork(func() {
+		left = parallelFoldEx(n.left, m, s)
+	}, func() {
+		right = parallelFoldEx(n.right, m, s)
+	})
+	return m.Combine(m.Combine(left, m.Measure(n.pair())), right)
+}
+
+// ParallelMapValuesEx is like MapValuesEx, but splits the work across goroutines at subtree boundaries. f may be called
+// concurrently and in any order, so it must be safe for concurrent use.
+func ParallelMapValuesEx[K Ordered[K], V any, W any](
+	n *TreeEx[K, V],
+	f func(key K, value V) W,
+	opts ParallelOptions,
+) *TreeEx[K, W] {
+	return parallelMapValuesEx(n, f, newScheduler(opts))
+}
+
+func parallelMapValuesEx[K Ordered[K], V any, W any](
+	n *TreeEx[K, V],
+	f func(key K, value V) W,
+	s *scheduler,
+) *TreeEx[K, W] {
+	if !s.shouldFork(n.Size()) {
+		return MapValuesEx(n, f)
+	}
+
+	var left, right *TreeEx[K, W]
+	s.fork(func() {
+		left = parallelMapValuesEx(n.left, f, s)
+	}, func() {
+		right = parallelMapValuesEx(n.right, f, s)
+	})
+
+	return &TreeEx[K, W]{
+		left:   left,
+		right:  right,
+		key:    n.key,
+		value:  f(n.key, n.value),
+		size:   n.size,
+		height: n.height,
+	}
+}
+
+// ParallelUnion is like Union, but splits the work across goroutines at subtree boundaries. merge may be called
+// concurrently, so it must be safe for concurrent use.
+func (n *TreeEx[K, V]) ParallelUnion(
+	other *TreeEx[K, V],
+	merge func(key K, a, b V) V,
+	opts ParallelOptions,
+) *TreeEx[K, V] {
+	return n.union(other, merge, newScheduler(opts))
+}
+
+// ParallelIntersection is like Intersection, but splits the work across goroutines at subtree boundaries. combine may
+// be called concurrently, so it must be safe for concurrent use.
+func (n *TreeEx[K, V]) ParallelIntersection(
+	other *TreeEx[K, V],
+	combine func(key K, a, b V) V,
+	opts ParallelOptions,
+) *TreeEx[K, V] {
+	return n.intersection(other, combine, newScheduler(opts))
+}
+
+// ParallelDifference is like Difference, but splits the work across goroutines at subtree boundaries.
+func (n *TreeEx[K, V]) ParallelDifference(other *TreeEx[K, V], opts ParallelOptions) *TreeEx[K, V] {
+	return n.difference(other, newScheduler(opts))
+}
+
+// ParallelUnion is like Union, but splits the work across goroutines at subtree boundaries.
+func (s *Set[T]) ParallelUnion(other *Set[T], opts ParallelOptions) *Set[T] {
+	return s.withTree(s.root().ParallelUnion(other.root(), nil, opts), other)
+}
+
+// ParallelIntersection is like Intersection, but splits the work across goroutines at subtree boundaries.
+func (s *Set[T]) ParallelIntersection(other *Set[T], opts ParallelOptions) *Set[T] {
+	return s.withTree(s.root().ParallelIntersection(other.root(), nil, opts), other)
+}
+
+// ParallelDifference is like Difference, but splits the work across goroutines at subtree boundaries.
+func (s *Set[T]) ParallelDifference(other *Set[T], opts ParallelOptions) *Set[T] {
+	return s.withTree(s.root().ParallelDifference(other.root(), opts), other)
+}
+
+// ParallelUnion is like Union, but splits the work across goroutines at subtree boundaries.
+func (s *SetEx[T]) ParallelUnion(other *SetEx[T], opts ParallelOptions) *SetEx[T] {
+	return s.withTree(s.root().ParallelUnion(other.root(), nil, opts), other)
+}
+
+// ParallelIntersection is like Intersection, but splits the work across goroutines at subtree boundaries.
+func (s *SetEx[T]) ParallelIntersection(other *SetEx[T], opts ParallelOptions) *SetEx[T] {
+	return s.withTree(s.root().ParallelIntersection(other.root(), nil, opts), other)
+}
+
+// ParallelDifference is like Difference, but splits the work across goroutines at subtree boundaries.
+func (s *SetEx[T]) ParallelDifference(other *SetEx[T], opts ParallelOptions) *SetEx[T] {
+	return s.withTree(s.root().ParallelDifference(other.root(), opts), other)
+}
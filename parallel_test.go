@@ -0,0 +1,145 @@
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// parallelOptions covers sequential execution, more workers than cores and a grain small enough to fork at every
+// level of the test trees.
+var parallelOptions = []ParallelOptions{
+	{Workers: 1, Grain: 1},
+	{Workers: 2, Grain: 8},
+	{Workers: 16, Grain: 1},
+	{},
+}
+
+func TestParallelFold(t *testing.T) {
+	tree := treeOfRange(0, 1000, 1)
+	for _, opts := range parallelOptions {
+		require.Equal(t, 999*1000/2, ParallelFold(tree, sumMonoid[int](), opts))
+		require.Equal(t, treeKeys(tree), ParallelFold(tree, keysMonoid[int](), opts))
+		require.Equal(t, 0, ParallelFold(EmptyTree[int, int](), sumMonoid[int](), opts))
+	}
+}
+
+func TestParallelFoldEx(t *testing.T) {
+	tree := treeExOfRange(0, 1000, 1)
+	for _, opts := range parallelOptions {
+		require.Equal(t, 999*1000/2, ParallelFoldEx(tree, sumMonoid[Int](), opts))
+		require.Equal(t, treeExKeys(tree), ParallelFoldEx(tree, keysMonoid[Int](), opts))
+	}
+}
+
+func TestParallelMapValues(t *testing.T) {
+	tree := treeOfRange(0, 1000, 3)
+	f := func(key int, value int) string { return strconv.Itoa(key + value) }
+	for _, opts := range parallelOptions {
+		mapped := ParallelMapValues(tree, f, opts)
+		requireValidTree(t, mapped)
+		require.Equal(t, treeMap(MapValues(tree, f)), treeMap(mapped))
+	}
+	require.Nil(t, ParallelMapValues(EmptyTree[int, int](), f, ParallelOptions{}))
+}
+
+func TestParallelMapValuesEx(t *testing.T) {
+	tree := treeExOfRange(0, 1000, 3)
+	for _, opts := range parallelOptions {
+		mapped := ParallelMapValuesEx(tree, func(key Int, value int) int { return value * 2 }, opts)
+		requireValidTreeEx(t, mapped)
+		require.Equal(t, treeExKeys(tree), treeExKeys(mapped))
+		it := mapped.Iter()
+		for it.Next() {
+			require.Equal(t, int(it.Current().Key)*2, it.Current().Value)
+		}
+	}
+}
+
+func TestParallelSetAlgebra(t *testing.T) {
+	a := treeOfRange(0, 2000, 2)
+	b := treeOfRange(0, 3000, 3)
+	sum := func(key int, x, y int) int { return x + y }
+	for _, opts := range parallelOptions {
+		u := a.ParallelUnion(b, sum, opts)
+		requireValidTree(t, u)
+		require.Equal(t, treeMap(a.Union(b, sum)), treeMap(u))
+
+		i := a.ParallelIntersection(b, sum, opts)
+		requireValidTree(t, i)
+		require.Equal(t, treeMap(a.Intersection(b, sum)), treeMap(i))
+
+		d := a.ParallelDifference(b, opts)
+		requireValidTree(t, d)
+		require.Equal(t, treeMap(a.Difference(b)), treeMap(d))
+
+		require.True(t, a == a.ParallelUnion(a, nil, opts))
+	}
+}
+
+func TestParallelSetAlgebraEx(t *testing.T) {
+	a := treeExOfRange(0, 2000, 2)
+	b := treeExOfRange(0, 3000, 3)
+	for _, opts := range parallelOptions {
+		u := a.ParallelUnion(b, nil, opts)
+		requireValidTreeEx(t, u)
+		require.Equal(t, treeExKeys(a.Union(b, nil)), treeExKeys(u))
+
+		i := a.ParallelIntersection(b, nil, opts)
+		requireValidTreeEx(t, i)
+		require.Equal(t, treeExKeys(a.Intersection(b, nil)), treeExKeys(i))
+
+		d := a.ParallelDifference(b, opts)
+		requireValidTreeEx(t, d)
+		require.Equal(t, treeExKeys(a.Difference(b)), treeExKeys(d))
+	}
+}
+
+func TestParallelSets(t *testing.T) {
+	var a, b *Set[int]
+	for i := 0; i < 500; i++ {
+		a = a.Add(i * 2)
+		b = b.Add(i * 5)
+	}
+	opts := ParallelOptions{Workers: 4, Grain: 16}
+	require.Equal(t, setElems(a.Union(b)), setElems(a.ParallelUnion(b, opts)))
+	require.Equal(t, setElems(a.Intersection(b)), setElems(a.ParallelIntersection(b, opts)))
+	require.Equal(t, setElems(a.Difference(b)), setElems(a.ParallelDifference(b, opts)))
+	require.Nil(t, a.ParallelDifference(a, opts))
+}
+
+func TestParallelPanic(t *testing.T) {
+	tree := treeOfRange(0, 1000, 1)
+	require.PanicsWithValue(t, "boom", func() {
+		ParallelMapValues(tree, func(key int, value int) int {
+			if key == 10 {
+				panic("boom")
+			}
+			return value
+		}, ParallelOptions{Workers: 8, Grain: 1})
+	})
+}
+
+func TestParallelPanicOnCallingGoroutine(t *testing.T) {
+	a := treeOfRange(0, 1000, 1)
+	b := treeOfRange(0, 1000, 1)
+
+	// The root forks its left half onto a new goroutine and keeps the right half, so the merge of the greatest key
+	// panics on the calling goroutine while the merge of the least key is still running.
+	var leftDone int32
+	require.PanicsWithValue(t, "boom", func() {
+		a.ParallelUnion(b, func(key int, x, y int) int {
+			switch key {
+			case 0:
+				time.Sleep(20 * time.Millisecond)
+				atomic.StoreInt32(&leftDone, 1)
+			case 999:
+				panic("boom")
+			}
+			return x
+		}, ParallelOptions{Workers: 2, Grain: 1})
+	})
+	require.Equal(t, int32(1), atomic.LoadInt32(&leftDone))
+}
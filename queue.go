@@ -9,79 +9,149 @@ import (
 // Persistent queues are immutable. Each mutating operation will return a pointer to a new queue with the
 // update applied.The implementation uses structural sharing to make immutability efficient. The implementation is
 // concurrency safe and non-blocking. A *Queue[T] instance may be accessed from multiple go-routines without
-// synchronization. Each mutating Queue[T] operation has amortized O(1) cost.
+// synchronization. Each Queue[T] operation is worst-case O(1), even when the same version is dequeued repeatedly.
+//
+// The implementation is a Hood-Melville real-time queue. Elements are removed from a front stack and added to a rear
+// stack. Whenever the rear stack grows larger than the front, the rear is reversed and appended onto the front, a
+// couple of steps at a time, by the operations that follow.
 type Queue[T any] struct {
-	e       *Stack[T]
-	d       *Stack[T]
-	eBottom T // Tracks the first item added to e
+	front     *Stack[T]
+	frontSize int // Includes elements still being rotated onto front.
+	rear      *Stack[T]
+	rotation  queueRotation[T]
 }
 
-// IsEmpty returns true iif the queue is empty.
-func (q *Queue[T]) IsEmpty() bool {
-	return q == nil || (q.e.IsEmpty() && q.d.IsEmpty())
-}
+type rotationPhase int
 
-func (q *Queue[T]) enqueueStack() *Stack[T] {
-	if q == nil {
-		return nil
-	}
-	return q.e
+const (
+	rotationIdle rotationPhase = iota
+	rotationReversing
+	rotationAppending
+	rotationDone
+)
+
+// queueRotation tracks the incremental computation of front ++ reverse(rear). While reversing, front and rear are
+// both reversed, onto reversedFront and result respectively. While appending, reversedFront is pushed back onto result.
+// valid counts the elements of reversedFront that have not been dequeued since the rotation started; only those are
+// appended. Once the rotation is done, result is the new front stack.
+type queueRotation[T any] struct {
+	phase         rotationPhase
+	valid         int
+	front         *Stack[T]
+	reversedFront *Stack[T]
+	rear          *Stack[T]
+	result        *Stack[T]
 }
 
-func (q *Queue[T]) dequeueStack() *Stack[T] {
-	if q == nil {
-		return nil
-	}
-	return q.d
+// IsEmpty returns true iif the queue is empty.
+func (q *Queue[T]) IsEmpty() bool {
+	return q.Size() == 0
 }
 
 // Enqueue returns a new queue with 'value' added to the end. This is O(1).
 func (q *Queue[T]) Enqueue(value T) *Queue[T] {
-	ret := Queue[T]{
-		e: q.enqueueStack().Push(value),
-		d: q.dequeueStack(),
+	var ret Queue[T]
+	if q != nil {
+		ret = *q
 	}
-
-	if q.enqueueStack().IsEmpty() {
-		ret.eBottom = value
-	} else {
-		ret.eBottom = q.eBottom
-	}
-
+	ret.push(value)
 	return &ret
 }
 
 // Dequeue removes the top item from the queue, and returns the dequeued value along with a new queue with the value
-// removed. If the queue is empty, the returned 'value' will be the 0 value of T.
-//
-// Dequeue is amortized O(1). This means an individual Dequeue operation may be O(n), but with a series of n calls
-// the average runtime is O(1).
+// removed. If the queue is empty, the returned 'value' will be the 0 value of T. This is O(1).
 func (q *Queue[T]) Dequeue() (value T, queue *Queue[T]) {
 	if q.IsEmpty() {
 		var zv T
 		return zv, nil
 	}
-	if q.d.IsEmpty() {
-		reversed := q.e.Reverse()
-		return reversed.Peek(), &Queue[T]{
-			e: nil,
-			d: reversed.Pop(),
+	ret := *q
+	value = ret.pop()
+	if ret.IsEmpty() {
+		return value, nil
+	}
+	return value, &ret
+}
+
+// push adds 'value' to the end of q in place.
+func (q *Queue[T]) push(value T) {
+	q.rear = q.rear.Push(value)
+	q.balance()
+}
+
+// pop removes the top item from the non-empty queue q in place and returns it.
+func (q *Queue[T]) pop() T {
+	value := q.front.Peek()
+	q.front = q.front.Pop()
+	q.frontSize--
+	q.rotation.invalidate()
+	q.balance()
+	return value
+}
+
+// balance starts a new rotation if the rear stack has grown larger than the front, and then advances the current
+// rotation by two steps. Two steps per operation are enough to finish every rotation before the next one is needed.
+func (q *Queue[T]) balance() {
+	if q.rear.Size() > q.frontSize {
+		q.rotation = queueRotation[T]{
+			phase: rotationReversing,
+			front: q.front,
+			rear:  q.rear,
 		}
+		q.frontSize += q.rear.Size()
+		q.rear = nil
 	}
-	v := q.d.Peek()
-	d := q.d.Pop()
-	if d.IsEmpty() && q.e.IsEmpty() {
-		return v, nil
+
+	q.rotation.step()
+	q.rotation.step()
+	if q.rotation.phase == rotationDone {
+		q.front = q.rotation.result
+		q.rotation = queueRotation[T]{}
 	}
-	retQueue := &Queue[T]{
-		d: d,
-		e: q.e,
+}
+
+// step performs a single, constant time, step of the rotation.
+func (r *queueRotation[T]) step() {
+	switch r.phase {
+	case rotationReversing:
+		if !r.front.IsEmpty() {
+			r.reversedFront = r.reversedFront.Push(r.front.Peek())
+			r.front = r.front.Pop()
+			r.result = r.result.Push(r.rear.Peek())
+			r.rear = r.rear.Pop()
+			r.valid++
+		} else {
+			// rear starts out one element longer than front, so exactly one element is left.
+			r.result = r.result.Push(r.rear.Peek())
+			r.rear = nil
+			r.phase = rotationAppending
+		}
+	case rotationAppending:
+		if r.valid == 0 {
+			r.phase = rotationDone
+		} else {
+			r.result = r.result.Push(r.reversedFront.Peek())
+			r.reversedFront = r.reversedFront.Pop()
+			r.valid--
+		}
 	}
+}
 
-	if !retQueue.e.IsEmpty() {
-		retQueue.eBottom = q.eBottom
+// invalidate records that an element was dequeued from the front while the rotation was in progress, so it must not
+// be copied into the result.
+func (r *queueRotation[T]) invalidate() {
+	switch r.phase {
+	case rotationReversing:
+		r.valid--
+	case rotationAppending:
+		if r.valid == 0 {
+			// The dequeued element was already appended to result.
+			r.result = r.result.Pop()
+			r.phase = rotationDone
+		} else {
+			r.valid--
+		}
 	}
-	return v, retQueue
 }
 
 // Top returns the top element in the queue without removing it. This is O(1).
@@ -91,14 +161,7 @@ func (q *Queue[T]) Top() T {
 		var zv T
 		return zv
 	}
-
-	// If dequeue stack has elements, the top is there
-	if !q.d.IsEmpty() {
-		return q.d.Peek()
-	}
-
-	// Otherwise, the top is the bottom of enqueue stack
-	return q.eBottom
+	return q.front.Peek()
 }
 
 // Size returns the number of elements in 'q'.
@@ -106,18 +169,227 @@ func (q *Queue[T]) Size() int {
 	if q == nil {
 		return 0
 	}
-	return q.d.Size() + q.e.Size()
+	return q.frontSize + q.rear.Size()
 }
 
-func (q *Queue[T]) MarshalJSON() ([]byte, error) {
-	cur := q
-	var buf []T
-	var item T
-	for !cur.IsEmpty() {
-		item, cur = cur.Dequeue()
-		buf = append(buf, item)
+// At returns the element at index i, counting from the front of the queue. If i is out of range, ok will be false. At
+// does not allocate, but it is O(n).
+func (q *Queue[T]) At(i int) (value T, ok bool) {
+	if i < 0 {
+		return value, false
+	}
+	segments, count := q.segments()
+	for _, seg := range segments[:count] {
+		size := seg.size()
+		if i >= size {
+			i -= size
+			continue
+		}
+		if seg.reversed {
+			i = size - 1 - i
+		}
+		s := seg.stack
+		for k := 0; k < seg.skip+i; k++ {
+			s = s.Pop()
+		}
+		return s.Peek(), true
+	}
+	return value, false
+}
+
+// ToSlice returns the elements of the queue from front to back. It returns nil if the queue is empty.
+func (q *Queue[T]) ToSlice() []T {
+	if q.IsEmpty() {
+		return nil
+	}
+	ret := make([]T, 0, q.Size())
+	it := q.Iter()
+	for it.Next() {
+		ret = append(ret, it.Current())
+	}
+	return ret
+}
+
+// Concat returns a new queue containing the elements of q followed by the elements of 'other'. This is O(m), where m
+// is the size of 'other'.
+func (q *Queue[T]) Concat(other *Queue[T]) *Queue[T] {
+	if other.IsEmpty() {
+		return q
+	}
+	if q.IsEmpty() {
+		return other
+	}
+	b := q.Builder()
+	it := other.Iter()
+	for it.Next() {
+		b.Enqueue(it.Current())
 	}
-	return json.Marshal(buf)
+	return b.Build()
+}
+
+// Filter returns a new queue containing the elements of q for which pred returns true, in the same order. If pred
+// returns true for every element, q itself is returned. This is O(n).
+func (q *Queue[T]) Filter(pred func(value T) bool) *Queue[T] {
+	var b *QueueBuilder[T]
+	dropped := false
+	it := q.Iter()
+	for it.Next() {
+		if pred(it.Current()) {
+			if b == nil {
+				b = EmptyQueue[T]().Builder()
+			}
+			b.Enqueue(it.Current())
+		} else {
+			dropped = true
+		}
+	}
+	if !dropped {
+		return q
+	}
+	if b == nil {
+		return nil
+	}
+	return b.Build()
+}
+
+// Reverse returns a new queue containing the elements of q in reverse order. This is O(n).
+func (q *Queue[T]) Reverse() *Queue[T] {
+	if q.IsEmpty() {
+		return nil
+	}
+	// Pushing the elements from front to back leaves the last element on top, where it can be dequeued first.
+	var front *Stack[T]
+	it := q.Iter()
+	for it.Next() {
+		front = front.Push(it.Current())
+	}
+	return &Queue[T]{
+		front:     front,
+		frontSize: front.Size(),
+	}
+}
+
+// Equal returns true if q and 'other' contain the same elements in the same order, comparing elements with eq.
+func (q *Queue[T]) Equal(other *Queue[T], eq func(a, b T) bool) bool {
+	if q == other {
+		return true
+	}
+	if q.Size() != other.Size() {
+		return false
+	}
+	it, otherIt := q.Iter(), other.Iter()
+	for it.Next() && otherIt.Next() {
+		if !eq(it.Current(), otherIt.Current()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Iter returns an iterator over the elements of the queue from front to back. The iterator walks the underlying
+// stacks directly, so it does not create intermediate queues.
+func (q *Queue[T]) Iter() Iterator[T] {
+	ret := &QueueIterator[T]{}
+	ret.segments, ret.count = q.segments()
+	return ret
+}
+
+// QueueIterator defines an iterator over a Queue.
+type QueueIterator[T any] struct {
+	segments [4]queueSegment[T]
+	count    int
+	next     int // The index of the next segment to visit.
+
+	// The rest of the segment being visited is in either stack or reversed. Elements of reversed are visited from the
+	// end of the slice.
+	stack    *Stack[T]
+	reversed []T
+
+	current T
+	valid   bool
+}
+
+// queueSegment is a run of queue elements held in a stack. The elements are visited from the top of the stack down,
+// after skipping 'skip' elements that are not part of the queue, or from the bottom up if reversed is true.
+type queueSegment[T any] struct {
+	stack    *Stack[T]
+	skip     int
+	reversed bool
+}
+
+func (s queueSegment[T]) size() int {
+	return s.stack.Size() - s.skip
+}
+
+// segments returns the runs of elements making up q, from front to back. The front stack comes first, followed by the
+// reversed rear stack being rotated onto the front, if any, and finally by the rear stack.
+func (q *Queue[T]) segments() (ret [4]queueSegment[T], count int) {
+	if q.IsEmpty() {
+		return ret, 0
+	}
+	add := func(seg queueSegment[T]) {
+		if seg.size() != 0 {
+			ret[count] = seg
+			count++
+		}
+	}
+
+	add(queueSegment[T]{stack: q.front})
+	rotating := q.frontSize - q.front.Size()
+	switch r := &q.rotation; r.phase {
+	case rotationReversing:
+		add(queueSegment[T]{stack: r.rear, reversed: true})
+		add(queueSegment[T]{stack: r.result})
+	case rotationAppending:
+		// The top of result holds elements of the old front that are also in q.front.
+		add(queueSegment[T]{stack: r.result, skip: r.result.Size() - rotating})
+	}
+	add(queueSegment[T]{stack: q.rear, reversed: true})
+	return ret, count
+}
+
+func (i *QueueIterator[T]) Next() bool {
+	for {
+		if n := len(i.reversed); n != 0 {
+			i.current, i.reversed = i.reversed[n-1], i.reversed[:n-1]
+			i.valid = true
+			return true
+		}
+		if !i.stack.IsEmpty() {
+			i.current, i.stack = i.stack.Peek(), i.stack.Pop()
+			i.valid = true
+			return true
+		}
+		if i.next == i.count {
+			var zv T
+			i.current, i.valid = zv, false
+			return false
+		}
+
+		seg := i.segments[i.next]
+		i.next++
+		if seg.reversed {
+			for s := seg.stack; !s.IsEmpty(); s = s.Pop() {
+				i.reversed = append(i.reversed, s.Peek())
+			}
+		} else {
+			i.stack = seg.stack
+			for k := 0; k < seg.skip; k++ {
+				i.stack = i.stack.Pop()
+			}
+		}
+	}
+}
+
+func (i *QueueIterator[T]) Current() T {
+	if !i.valid {
+		panic("invalid iterator position")
+	}
+	return i.current
+}
+
+func (q *Queue[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToSlice())
 }
 
 func (q *Queue[T]) UnmarshalJSON(bytes []byte) error {
@@ -130,6 +402,9 @@ func (q *Queue[T]) UnmarshalJSON(bytes []byte) error {
 	for _, b := range buf {
 		tmp = tmp.Enqueue(b)
 	}
+	if tmp == nil {
+		tmp = &Queue[T]{}
+	}
 	*q = *tmp
 	return nil
 }
@@ -3,6 +3,7 @@ package persistent
 import (
 	"encoding/json"
 	"github.com/stretchr/testify/require"
+	"math/rand"
 	"testing"
 )
 
@@ -76,6 +77,21 @@ func TestJsonUnMarshalQueue(t *testing.T) {
 
 }
 
+func TestJsonEmptyQueue(t *testing.T) {
+	bytes, err := json.Marshal(EmptyQueue[int]())
+	require.NoError(t, err)
+	var q *Queue[int]
+	err = json.Unmarshal(bytes, &q)
+	require.NoError(t, err)
+	require.True(t, q.IsEmpty())
+
+	q = EmptyQueue[int]().Enqueue(1)
+	err = json.Unmarshal([]byte(`[]`), q)
+	require.NoError(t, err)
+	require.True(t, q.IsEmpty())
+	require.Equal(t, []int{2}, q.Enqueue(2).ToSlice())
+}
+
 func TestTopEmpty(t *testing.T) {
 	q := EmptyQueue[int]()
 	require.Equal(t, 0, q.Top())
@@ -143,3 +159,154 @@ func TestTopNonDestructive(t *testing.T) {
 
 	require.True(t, q.IsEmpty())
 }
+
+// requireValidQueue checks the invariants that make every operation on q O(1).
+func requireValidQueue[T any](t *testing.T, q *Queue[T]) {
+	t.Helper()
+	if q.IsEmpty() {
+		return
+	}
+	require.LessOrEqual(t, q.rear.Size(), q.frontSize)
+	require.False(t, q.front.IsEmpty())
+	require.NotEqual(t, rotationDone, q.rotation.phase)
+}
+
+func queueElems[T any](q *Queue[T]) []T {
+	var ret []T
+	var value T
+	for !q.IsEmpty() {
+		value, q = q.Dequeue()
+		ret = append(ret, value)
+	}
+	return ret
+}
+
+func TestQueuePersistence(t *testing.T) {
+	r := rand.New(rand.NewSource(21))
+	queues := []*Queue[int]{nil}
+	models := [][]int{nil}
+	for i := 0; i < 5000; i++ {
+		j := r.Intn(len(queues))
+		q, model := queues[j], models[j]
+		if r.Intn(3) == 0 && len(model) != 0 {
+			var value int
+			value, q = q.Dequeue()
+			require.Equal(t, model[0], value)
+			model = model[1:]
+		} else {
+			q = q.Enqueue(i)
+			model = append(model[:len(model):len(model)], i)
+		}
+		requireValidQueue(t, q)
+		require.Equal(t, len(model), q.Size())
+		if len(model) != 0 {
+			require.Equal(t, model[0], q.Top())
+		}
+		queues = append(queues, q)
+		models = append(models, model)
+	}
+
+	for i, q := range queues {
+		model := models[i]
+		if len(model) == 0 {
+			require.Empty(t, queueElems(q))
+			require.Nil(t, q.ToSlice())
+			continue
+		}
+		require.Equal(t, model, queueElems(q))
+		require.Equal(t, model, q.ToSlice())
+		for j, expected := range model {
+			v, ok := q.At(j)
+			require.True(t, ok)
+			require.Equal(t, expected, v)
+		}
+		_, ok := q.At(len(model))
+		require.False(t, ok)
+	}
+}
+
+func TestQueueRepeatedDequeue(t *testing.T) {
+	var q *Queue[int]
+	for i := 0; i < 1000; i++ {
+		q = q.Enqueue(i)
+	}
+	for i := 0; i < 1000; i++ {
+		value, rest := q.Dequeue()
+		require.Equal(t, 0, value)
+		require.Equal(t, 999, rest.Size())
+		requireValidQueue(t, rest)
+	}
+}
+
+func TestQueueIter(t *testing.T) {
+	q := EmptyQueue[int]().Enqueue(1).Enqueue(2).Enqueue(3)
+	_, q = q.Dequeue()
+	q = q.Enqueue(4)
+
+	it := q.Iter()
+	require.Panics(t, func() { it.Current() })
+	var actual []int
+	for it.Next() {
+		actual = append(actual, it.Current())
+	}
+	require.Equal(t, []int{2, 3, 4}, actual)
+	require.Panics(t, func() { it.Current() })
+	require.False(t, EmptyQueue[int]().Iter().Next())
+}
+
+func TestQueueAtOutOfRange(t *testing.T) {
+	q := EmptyQueue[int]().Enqueue(1)
+	_, ok := q.At(-1)
+	require.False(t, ok)
+	_, ok = q.At(1)
+	require.False(t, ok)
+	_, ok = EmptyQueue[int]().At(0)
+	require.False(t, ok)
+}
+
+func TestQueueConcat(t *testing.T) {
+	a := EmptyQueue[int]().Enqueue(1).Enqueue(2)
+	b := EmptyQueue[int]().Enqueue(3).Enqueue(4).Enqueue(5)
+	c := a.Concat(b)
+	requireValidQueue(t, c)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, c.ToSlice())
+	require.Equal(t, []int{1, 2}, a.ToSlice())
+	require.True(t, a == a.Concat(nil))
+	require.True(t, b == EmptyQueue[int]().Concat(b))
+}
+
+func TestQueueFilter(t *testing.T) {
+	var q *Queue[int]
+	for i := 0; i < 20; i++ {
+		q = q.Enqueue(i)
+	}
+	even := q.Filter(func(v int) bool { return v%2 == 0 })
+	requireValidQueue(t, even)
+	require.Equal(t, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}, even.ToSlice())
+	require.True(t, q == q.Filter(func(v int) bool { return true }))
+	require.Nil(t, q.Filter(func(v int) bool { return false }))
+}
+
+func TestQueueReverse(t *testing.T) {
+	q := EmptyQueue[int]().Enqueue(1).Enqueue(2).Enqueue(3)
+	_, q = q.Dequeue()
+	q = q.Enqueue(4)
+	r := q.Reverse()
+	requireValidQueue(t, r)
+	require.Equal(t, []int{4, 3, 2}, r.ToSlice())
+	require.Equal(t, 4, r.Top())
+	require.Equal(t, []int{4, 3, 2, 5}, r.Enqueue(5).ToSlice())
+	require.Nil(t, EmptyQueue[int]().Reverse())
+}
+
+func TestQueueEqual(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	a := EmptyQueue[int]().Enqueue(0).Enqueue(1).Enqueue(2).Enqueue(3)
+	_, a = a.Dequeue()
+	b := EmptyQueue[int]().Enqueue(1).Enqueue(2).Enqueue(3)
+	require.True(t, a.Equal(b, eq))
+	require.True(t, a.Equal(a, eq))
+	require.False(t, a.Equal(b.Enqueue(4), eq))
+	require.False(t, a.Equal(EmptyQueue[int]().Enqueue(1).Enqueue(2).Enqueue(4), eq))
+	require.True(t, EmptyQueue[int]().Equal(nil, eq))
+}
@@ -0,0 +1,286 @@
+//go:build go1.23
+
+package persistent
+
+import (
+	"golang.org/x/exp/constraints"
+	"iter"
+)
+
+// All returns a sequence over the entries of the tree in increasing key order, for use with range-over-func loops.
+func (n *Tree[K, V]) All() iter.Seq2[K, V] {
+	return pairSeq(n.Iter)
+}
+
+// Keys returns a sequence over the keys of the tree in increasing order.
+func (n *Tree[K, V]) Keys() iter.Seq[K] {
+	return keySeq(n.Iter)
+}
+
+// Values returns a sequence over the values of the tree in increasing key order.
+func (n *Tree[K, V]) Values() iter.Seq[V] {
+	return valueSeq(n.Iter)
+}
+
+// Backward returns a sequence over the entries of the tree in decreasing key order.
+func (n *Tree[K, V]) Backward() iter.Seq2[K, V] {
+	return pairSeq(n.IterDesc)
+}
+
+// All returns a sequence over the entries of the tree in increasing key order, for use with range-over-func loops.
+func (n *TreeEx[K, V]) All() iter.Seq2[K, V] {
+	return pairSeq(n.Iter)
+}
+
+// Keys returns a sequence over the keys of the tree in increasing order.
+func (n *TreeEx[K, V]) Keys() iter.Seq[K] {
+	return keySeq(n.Iter)
+}
+
+// Values returns a sequence over the values of the tree in increasing key order.
+func (n *TreeEx[K, V]) Values() iter.Seq[V] {
+	return valueSeq(n.Iter)
+}
+
+// Backward returns a sequence over the entries of the tree in decreasing key order.
+func (n *TreeEx[K, V]) Backward() iter.Seq2[K, V] {
+	return pairSeq(n.IterDesc)
+}
+
+// All returns a sequence over the entries of the tree in increasing key order, for use with range-over-func loops.
+func (t *TreeFunc[K, V]) All() iter.Seq2[K, V] {
+	return pairSeq(t.Iter)
+}
+
+// Keys returns a sequence over the keys of the tree in increasing order.
+func (t *TreeFunc[K, V]) Keys() iter.Seq[K] {
+	return keySeq(t.Iter)
+}
+
+// Values returns a sequence over the values of the tree in increasing key order.
+func (t *TreeFunc[K, V]) Values() iter.Seq[V] {
+	return valueSeq(t.Iter)
+}
+
+// Backward returns a sequence over the entries of the tree in decreasing key order.
+func (t *TreeFunc[K, V]) Backward() iter.Seq2[K, V] {
+	return pairSeq(t.IterDesc)
+}
+
+// All returns a sequence over the elements of s in increasing order, for use with range-over-func loops.
+func (s *Set[T]) All() iter.Seq[T] {
+	return elemSeq(s.Iter)
+}
+
+// Backward returns a sequence over the elements of s in decreasing order.
+func (s *Set[T]) Backward() iter.Seq[T] {
+	return elemSeq(s.IterDesc)
+}
+
+// All returns a sequence over the elements of s in increasing order, for use with range-over-func loops.
+func (s *SetEx[T]) All() iter.Seq[T] {
+	return elemSeq(s.Iter)
+}
+
+// Backward returns a sequence over the elements of s in decreasing order.
+func (s *SetEx[T]) Backward() iter.Seq[T] {
+	return elemSeq(s.IterDesc)
+}
+
+// All returns a sequence over the elements of s in increasing order, for use with range-over-func loops.
+func (s *SetFunc[T]) All() iter.Seq[T] {
+	return elemSeq(s.Iter)
+}
+
+// Backward returns a sequence over the elements of s in decreasing order.
+func (s *SetFunc[T]) Backward() iter.Seq[T] {
+	return elemSeq(s.IterDesc)
+}
+
+// All returns a sequence over the elements of the stack from top to bottom, for use with range-over-func loops.
+func (s *Stack[T]) All() iter.Seq[T] {
+	return elemSeq(s.Iter)
+}
+
+// Backward returns a sequence over the elements of the stack from bottom to top. It is O(n) to start.
+func (s *Stack[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.Reverse().All()(yield)
+	}
+}
+
+// All returns a sequence over the elements of the queue from front to back, for use with range-over-func loops.
+func (q *Queue[T]) All() iter.Seq[T] {
+	return elemSeq(q.Iter)
+}
+
+// Backward returns a sequence over the elements of the queue from back to front. It is O(n) to start.
+func (q *Queue[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		elems := q.ToSlice()
+		for i := len(elems) - 1; i >= 0; i-- {
+			if !yield(elems[i]) {
+				return
+			}
+		}
+	}
+}
+
+// All returns a sequence over the elements of the deque from front to back, for use with range-over-func loops.
+func (d *Deque[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		front, back := d.stacks()
+		for ; !front.IsEmpty(); front = front.Pop() {
+			if !yield(front.Peek()) {
+				return
+			}
+		}
+		back.Backward()(yield)
+	}
+}
+
+// Backward returns a sequence over the elements of the deque from back to front.
+func (d *Deque[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		front, back := d.stacks()
+		for ; !back.IsEmpty(); back = back.Pop() {
+			if !yield(back.Peek()) {
+				return
+			}
+		}
+		front.Backward()(yield)
+	}
+}
+
+// CollectTree returns a tree containing the entries of seq. If a key appears more than once, the last value wins.
+func CollectTree[K constraints.Ordered, V any](seq iter.Seq2[K, V]) *Tree[K, V] {
+	var tree *Tree[K, V]
+	b := tree.Builder()
+	for k, v := range seq {
+		b.Update(k, v)
+	}
+	return b.Build()
+}
+
+// CollectTreeEx returns a tree containing the entries of seq. If a key appears more than once, the last value wins.
+func CollectTreeEx[K Ordered[K], V any](seq iter.Seq2[K, V]) *TreeEx[K, V] {
+	var tree *TreeEx[K, V]
+	b := tree.Builder()
+	for k, v := range seq {
+		b.Update(k, v)
+	}
+	return b.Build()
+}
+
+// CollectTreeFunc returns a tree ordered by cmp containing the entries of seq. If a key appears more than once, the
+// last value wins.
+func CollectTreeFunc[K any, V any](cmp func(a, b K) int, seq iter.Seq2[K, V]) *TreeFunc[K, V] {
+	var pairs []Pair[K, V]
+	for k, v := range seq {
+		pairs = append(pairs, Pair[K, V]{Key: k, Value: v})
+	}
+	return &TreeFunc[K, V]{root: buildFuncNodeUnsorted(cmp, pairs), cmp: cmp}
+}
+
+// CollectSet returns a set containing the elements of seq.
+func CollectSet[T constraints.Ordered](seq iter.Seq[T]) *Set[T] {
+	var set *Set[T]
+	b := set.Builder()
+	for e := range seq {
+		b.Add(e)
+	}
+	return b.Build()
+}
+
+// CollectSetEx returns a set containing the elements of seq.
+func CollectSetEx[T Ordered[T]](seq iter.Seq[T]) *SetEx[T] {
+	var set *SetEx[T]
+	b := set.Builder()
+	for e := range seq {
+		b.Add(e)
+	}
+	return b.Build()
+}
+
+// CollectSetFunc returns a set ordered by cmp containing the elements of seq.
+func CollectSetFunc[T any](cmp func(a, b T) int, seq iter.Seq[T]) *SetFunc[T] {
+	var elems []T
+	for e := range seq {
+		elems = append(elems, e)
+	}
+	return &SetFunc[T]{root: buildSetFuncNodeUnsorted(cmp, elems), cmp: cmp}
+}
+
+// CollectStack returns a stack containing the elements of seq, with the first element on top. Ranging over the result
+// with All yields the elements in the order of seq.
+func CollectStack[T any](seq iter.Seq[T]) *Stack[T] {
+	var reversed *Stack[T]
+	for e := range seq {
+		reversed = reversed.Push(e)
+	}
+	return reversed.Reverse()
+}
+
+// CollectQueue returns a queue containing the elements of seq, with the first element at the front.
+func CollectQueue[T any](seq iter.Seq[T]) *Queue[T] {
+	var queue *Queue[T]
+	b := queue.Builder()
+	for e := range seq {
+		b.Enqueue(e)
+	}
+	return b.Build()
+}
+
+// CollectDeque returns a deque containing the elements of seq, with the first element at the front.
+func CollectDeque[T any](seq iter.Seq[T]) *Deque[T] {
+	var deque *Deque[T]
+	for e := range seq {
+		deque = deque.PushBack(e)
+	}
+	return deque
+}
+
+func pairSeq[K any, V any](newIter func() Iterator[Pair[K, V]]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		it := newIter()
+		for it.Next() {
+			p := it.Current()
+			if !yield(p.Key, p.Value) {
+				return
+			}
+		}
+	}
+}
+
+func keySeq[K any, V any](newIter func() Iterator[Pair[K, V]]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		it := newIter()
+		for it.Next() {
+			if !yield(it.Current().Key) {
+				return
+			}
+		}
+	}
+}
+
+func valueSeq[K any, V any](newIter func() Iterator[Pair[K, V]]) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		it := newIter()
+		for it.Next() {
+			if !yield(it.Current().Value) {
+				return
+			}
+		}
+	}
+}
+
+func elemSeq[T any](newIter func() Iterator[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		it := newIter()
+		for it.Next() {
+			if !yield(it.Current()) {
+				return
+			}
+		}
+	}
+}
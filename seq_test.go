@@ -0,0 +1,108 @@
+//go:build go1.23
+
+package persistent
+
+import (
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+)
+
+func TestTreeSeq(t *testing.T) {
+	tree := treeOfRange(0, 5, 1).Update(2, 20)
+
+	var keys, values []int
+	for k, v := range tree.All() {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	require.Equal(t, []int{0, 1, 2, 3, 4}, keys)
+	require.Equal(t, []int{0, 1, 20, 3, 4}, values)
+	require.Equal(t, keys, slices.Collect(tree.Keys()))
+	require.Equal(t, values, slices.Collect(tree.Values()))
+
+	var backward []int
+	for k := range tree.Backward() {
+		backward = append(backward, k)
+		if k == 2 {
+			break
+		}
+	}
+	require.Equal(t, []int{4, 3, 2}, backward)
+
+	collected := CollectTree(tree.All())
+	requireValidTree(t, collected)
+	require.Equal(t, treeMap(tree), treeMap(collected))
+	require.Nil(t, CollectTree(EmptyTree[int, int]().All()))
+}
+
+func TestTreeExSeq(t *testing.T) {
+	tree := treeExOfRange(0, 5, 1)
+	require.Equal(t, []Int{0, 1, 2, 3, 4}, slices.Collect(tree.Keys()))
+	require.Equal(t, []int{0, 1, 2, 3, 4}, slices.Collect(tree.Values()))
+
+	var backward []Int
+	for k := range tree.Backward() {
+		backward = append(backward, k)
+	}
+	require.Equal(t, []Int{4, 3, 2, 1, 0}, backward)
+	require.Equal(t, treeExKeys(tree), treeExKeys(CollectTreeEx(tree.All())))
+}
+
+func TestTreeFuncSeq(t *testing.T) {
+	tree := treeFuncOfRange(0, 5)
+	require.Equal(t, []int{4, 3, 2, 1, 0}, slices.Collect(tree.Keys()))
+	collected := CollectTreeFunc(descending, tree.Backward())
+	require.Equal(t, []int{4, 3, 2, 1, 0}, slices.Collect(collected.Keys()))
+}
+
+func TestSetSeq(t *testing.T) {
+	s := setOf(3, 1, 2)
+	require.Equal(t, []int{1, 2, 3}, slices.Collect(s.All()))
+	require.Equal(t, []int{3, 2, 1}, slices.Collect(s.Backward()))
+	require.True(t, s.Equal(CollectSet(slices.Values([]int{2, 3, 1, 2}))))
+
+	ex := CollectSetEx(slices.Values([]Int{2, 3, 1}))
+	require.Equal(t, []Int{1, 2, 3}, slices.Collect(ex.All()))
+	require.Equal(t, []Int{3, 2, 1}, slices.Collect(ex.Backward()))
+
+	f := CollectSetFunc(descending, slices.Values([]int{2, 3, 1}))
+	require.Equal(t, []int{3, 2, 1}, slices.Collect(f.All()))
+	require.Equal(t, []int{1, 2, 3}, slices.Collect(f.Backward()))
+}
+
+func TestStackSeq(t *testing.T) {
+	s := EmptyStack[int]().Push(1).Push(2).Push(3)
+	require.Equal(t, []int{3, 2, 1}, slices.Collect(s.All()))
+	require.Equal(t, []int{1, 2, 3}, slices.Collect(s.Backward()))
+
+	collected := CollectStack(s.All())
+	require.Equal(t, 3, collected.Peek())
+	require.Equal(t, []int{3, 2, 1}, slices.Collect(collected.All()))
+	require.Nil(t, CollectStack(EmptyStack[int]().All()))
+}
+
+func TestQueueSeq(t *testing.T) {
+	q := EmptyQueue[int]().Enqueue(1).Enqueue(2).Enqueue(3)
+	_, q = q.Dequeue()
+	q = q.Enqueue(4).Enqueue(5)
+
+	require.Equal(t, []int{2, 3, 4, 5}, slices.Collect(q.All()))
+	require.Equal(t, []int{5, 4, 3, 2}, slices.Collect(q.Backward()))
+
+	collected := CollectQueue(q.All())
+	require.Equal(t, 2, collected.Top())
+	require.Equal(t, []int{2, 3, 4, 5}, slices.Collect(collected.All()))
+	require.Empty(t, slices.Collect(EmptyQueue[int]().All()))
+}
+
+func TestDequeSeq(t *testing.T) {
+	d := EmptyDeque[int]().PushBack(3).PushBack(4).PushFront(2).PushFront(1)
+	require.Equal(t, []int{1, 2, 3, 4}, slices.Collect(d.All()))
+	require.Equal(t, []int{4, 3, 2, 1}, slices.Collect(d.Backward()))
+
+	collected := CollectDeque(d.Backward())
+	require.Equal(t, 4, collected.PeekFront())
+	require.Equal(t, []int{4, 3, 2, 1}, slices.Collect(collected.All()))
+	require.Empty(t, slices.Collect(EmptyDeque[int]().All()))
+}
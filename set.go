@@ -3,6 +3,7 @@ package persistent
 import (
 	"encoding/json"
 	"golang.org/x/exp/constraints"
+	"sort"
 )
 
 // Set defines a set interface implemented using a persistent AVL tree. It works for all types that support the <
@@ -27,6 +28,55 @@ func (s *Set[T]) GetKthElement(k int) (e T, ok bool) {
 	return p.Key, ok
 }
 
+// Rank returns the number of elements in s that are less than e. It is O(log(n)).
+func (s *Set[T]) Rank(e T) int {
+	return s.root().Rank(e)
+}
+
+// CountRange returns the number of elements x in s such that lo <= x <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(n)).
+func (s *Set[T]) CountRange(lo T, hi T, bounds Bounds) int {
+	return s.root().CountRange(lo, hi, bounds)
+}
+
+// Median returns the median element of s. If s has an even number of elements, the lower of the two middle elements
+// is returned. If s is empty, ok will be false.
+func (s *Set[T]) Median() (e T, ok bool) {
+	p, ok := s.root().Median()
+	return p.Key, ok
+}
+
+// Percentile returns the element at the p'th percentile of s, using the nearest-rank method. p must be between 0 and
+// 100. If s is empty or p is out of range, ok will be false.
+func (s *Set[T]) Percentile(p float64) (e T, ok bool) {
+	kv, ok := s.root().Percentile(p)
+	return kv.Key, ok
+}
+
+// DeleteAt returns a set with the i'th smallest element removed. If i is out of range, s is returned unchanged.
+// DeleteAt is O(log(n)).
+func (s *Set[T]) DeleteAt(i int) *Set[T] {
+	return s.withTree(s.root().DeleteAt(i), nil)
+}
+
+// SplitAt partitions s by position. It returns a set containing the i smallest elements and a set containing the
+// remaining ones. Values of i outside of [0, s.Size()] are clamped to that range. SplitAt is O(log(n)).
+func (s *Set[T]) SplitAt(i int) (left *Set[T], right *Set[T]) {
+	l, r := s.root().SplitAt(i)
+	return s.withTree(l, nil), s.withTree(r, nil)
+}
+
+// SliceByIndex returns a set containing the elements whose positions lie in [i, j). Indexes outside of
+// [0, s.Size()] are clamped to that range. SliceByIndex is O(log(n)).
+func (s *Set[T]) SliceByIndex(i int, j int) *Set[T] {
+	return s.withTree(s.root().SliceByIndex(i, j), nil)
+}
+
+// IterFromIndex returns an in-order traversal iterator that starts at the i'th smallest element of s.
+func (s *Set[T]) IterFromIndex(i int) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterFromIndex(i)}
+}
+
 // Contains return true if the set contains the given element.
 func (s *Set[T]) Contains(elem T) bool {
 	var currentRoot *Tree[T, bool]
@@ -101,6 +151,34 @@ func (s *Set[T]) GreatestLowerBound(value T) (T, bool) {
 	return kv.Key, true
 }
 
+// Successor returns the least element of s that is strictly greater than 'value'. If no such element exists, ok will
+// be false.
+func (s *Set[T]) Successor(value T) (e T, ok bool) {
+	p, ok := s.root().Successor(value)
+	return p.Key, ok
+}
+
+// Predecessor returns the greatest element of s that is strictly less than 'value'. If no such element exists, ok will
+// be false.
+func (s *Set[T]) Predecessor(value T) (e T, ok bool) {
+	p, ok := s.root().Predecessor(value)
+	return p.Key, ok
+}
+
+// PopLeast removes the least element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopLeast is O(log(n)).
+func (s *Set[T]) PopLeast() (e T, rest *Set[T], ok bool) {
+	p, tree, ok := s.root().PopLeast()
+	return p.Key, s.withTree(tree, nil), ok
+}
+
+// PopMost removes the greatest element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopMost is O(log(n)).
+func (s *Set[T]) PopMost() (e T, rest *Set[T], ok bool) {
+	p, tree, ok := s.root().PopMost()
+	return p.Key, s.withTree(tree, nil), ok
+}
+
 // Size returns the number of elements in the set.
 func (s *Set[T]) Size() int {
 	if s == nil {
@@ -127,11 +205,125 @@ func (s *Set[T]) IterGte(e T) Iterator[T] {
 	return &SetIterator[T]{wrapped: currentRoot.IterGte(e)}
 }
 
+// IterRange returns an in-order traversal iterator over all elements x in s such that lo <= x <= hi. Use 'bounds' to
+// exclude either endpoint from the range.
+func (s *Set[T]) IterRange(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterRange(lo, hi, bounds)}
+}
+
+// IterLt returns an in-order traversal iterator over all elements x in s that are < e
+func (s *Set[T]) IterLt(e T) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterLt(e)}
+}
+
+// IterLte returns an in-order traversal iterator over all elements x in s that are <= e
+func (s *Set[T]) IterLte(e T) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterLte(e)}
+}
+
+// IterDesc returns an iterator over the elements in the set, from greatest to least.
+func (s *Set[T]) IterDesc() Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterDesc()}
+}
+
+// IterDescFrom returns an iterator over all elements x in s that are <= e, from greatest to least.
+func (s *Set[T]) IterDescFrom(e T) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterDescFrom(e)}
+}
+
+// IterRangeDesc returns an iterator over all elements x in s such that lo <= x <= hi, from greatest to least. Use
+// 'bounds' to exclude either endpoint from the range.
+func (s *Set[T]) IterRangeDesc(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetIterator[T]{wrapped: s.root().IterRangeDesc(lo, hi, bounds)}
+}
+
 // IsEmpty returns true iif s is empty.
 func (s *Set[T]) IsEmpty() bool {
 	return s == nil || s.tree.IsEmpty()
 }
 
+// Union returns a set containing every element found in either s or 'other'.
+//
+// Union works directly on the underlying trees, so it is O(m*log(n/m + 1)) where m is the size of the smaller set
+// and n the size of the larger one.
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	return s.withTree(s.root().Union(other.root(), nil), other)
+}
+
+// Intersection returns a set containing the elements found in both s and 'other'. It is O(m*log(n/m + 1)).
+func (s *Set[T]) Intersection(other *Set[T]) *Set[T] {
+	return s.withTree(s.root().Intersection(other.root(), nil), other)
+}
+
+// Difference returns a set containing the elements of s that are not found in 'other'. It is O(m*log(n/m + 1)).
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	return s.withTree(s.root().Difference(other.root()), other)
+}
+
+// SymmetricDifference returns a set containing the elements found in exactly one of s and 'other'. It is
+// O(m*log(n/m + 1)).
+func (s *Set[T]) SymmetricDifference(other *Set[T]) *Set[T] {
+	return s.withTree(s.root().symmetricDifference(other.root()), other)
+}
+
+// IsSubsetOf returns true iif every element of s is also an element of 'other'.
+func (s *Set[T]) IsSubsetOf(other *Set[T]) bool {
+	return s.root().isSubsetOf(other.root())
+}
+
+// IsSupersetOf returns true iif every element of 'other' is also an element of s.
+func (s *Set[T]) IsSupersetOf(other *Set[T]) bool {
+	return other.root().isSubsetOf(s.root())
+}
+
+// IsDisjoint returns true iif s and 'other' have no elements in common.
+func (s *Set[T]) IsDisjoint(other *Set[T]) bool {
+	return s.root().isDisjoint(other.root())
+}
+
+// Equal returns true iif s and 'other' contain the same elements.
+func (s *Set[T]) Equal(other *Set[T]) bool {
+	return s.Size() == other.Size() && s.root().isSubsetOf(other.root())
+}
+
+// Filter returns a set containing the elements of s for which pred returns true. Elements are visited in order.
+// Filter is O(n).
+func (s *Set[T]) Filter(pred func(elem T) bool) *Set[T] {
+	return s.withTree(s.root().Filter(func(elem T, _ bool) bool {
+		return pred(elem)
+	}), nil)
+}
+
+// Partition splits s into a set containing the elements for which pred returns true and a set containing the
+// remaining elements, in a single O(n) pass. Elements are visited in order.
+func (s *Set[T]) Partition(pred func(elem T) bool) (matching *Set[T], rest *Set[T]) {
+	in, out := s.root().Partition(func(elem T, _ bool) bool {
+		return pred(elem)
+	})
+	return s.withTree(in, nil), s.withTree(out, nil)
+}
+
+func (s *Set[T]) root() *Tree[T, bool] {
+	if s == nil {
+		return nil
+	}
+	return s.tree
+}
+
+// withTree returns a set backed by 'tree', reusing s or 'other' if either one is already backed by it.
+func (s *Set[T]) withTree(tree *Tree[T, bool], other *Set[T]) *Set[T] {
+	if tree.IsEmpty() {
+		return nil
+	}
+	if tree == s.root() {
+		return s
+	}
+	if tree == other.root() {
+		return other
+	}
+	return &Set[T]{tree: tree}
+}
+
 // MarshalJSON marshals the set s as a json array.a
 func (s *Set[T]) MarshalJSON() ([]byte, error) {
 	var arr []T
@@ -149,11 +341,18 @@ func (s *Set[T]) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	ret := &Set[T]{}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i] < arr[j]
+	})
+
+	unique := arr[:0]
 	for _, e := range arr {
-		ret = ret.Add(e)
+		if len(unique) == 0 || unique[len(unique)-1] < e {
+			unique = append(unique, e)
+		}
 	}
-	*s = *ret
+
+	*s = Set[T]{tree: buildSetTree(unique)}
 	return nil
 }
 
@@ -172,3 +371,29 @@ func (s *SetIterator[T]) Current() T {
 func EmptySet[T constraints.Ordered]() *Set[T] {
 	return nil
 }
+
+// SetFromSorted returns a set containing 'elems', which must be sorted in strictly increasing order. If they are not,
+// the returned error wraps ErrUnsorted or ErrDuplicateKey. SetFromSorted builds a perfectly balanced tree in O(n)
+// time.
+func SetFromSorted[T constraints.Ordered](elems []T) (*Set[T], error) {
+	err := checkSorted(len(elems), func(i int) T {
+		return elems[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree := buildSetTree(elems)
+	if tree == nil {
+		return nil, nil
+	}
+	return &Set[T]{tree: tree}, nil
+}
+
+// buildSetTree returns a perfectly balanced tree containing 'elems', which must already be sorted and unique.
+func buildSetTree[T constraints.Ordered](elems []T) *Tree[T, bool] {
+	if len(elems) == 0 {
+		return nil
+	}
+	mid := len(elems) / 2
+	return newNode(buildSetTree(elems[:mid]), buildSetTree(elems[mid+1:]), elems[mid], true)
+}
@@ -2,6 +2,7 @@ package persistent
 
 import (
 	"encoding/json"
+	"sort"
 )
 
 // SetEx defines a set interface implemented using a persistent AVL tree. It works for custom types that implement
@@ -26,6 +27,55 @@ func (s *SetEx[T]) GetKthElement(k int) (e T, ok bool) {
 	return p.Key, ok
 }
 
+// Rank returns the number of elements in s that are less than e. It is O(log(n)).
+func (s *SetEx[T]) Rank(e T) int {
+	return s.root().Rank(e)
+}
+
+// CountRange returns the number of elements x in s such that lo <= x <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(n)).
+func (s *SetEx[T]) CountRange(lo T, hi T, bounds Bounds) int {
+	return s.root().CountRange(lo, hi, bounds)
+}
+
+// Median returns the median element of s. If s has an even number of elements, the lower of the two middle elements
+// is returned. If s is empty, ok will be false.
+func (s *SetEx[T]) Median() (e T, ok bool) {
+	p, ok := s.root().Median()
+	return p.Key, ok
+}
+
+// Percentile returns the element at the p'th percentile of s, using the nearest-rank method. p must be between 0 and
+// 100. If s is empty or p is out of range, ok will be false.
+func (s *SetEx[T]) Percentile(p float64) (e T, ok bool) {
+	kv, ok := s.root().Percentile(p)
+	return kv.Key, ok
+}
+
+// DeleteAt returns a set with the i'th smallest element removed. If i is out of range, s is returned unchanged.
+// DeleteAt is O(log(n)).
+func (s *SetEx[T]) DeleteAt(i int) *SetEx[T] {
+	return s.withTree(s.root().DeleteAt(i), nil)
+}
+
+// SplitAt partitions s by position. It returns a set containing the i smallest elements and a set containing the
+// remaining ones. Values of i outside of [0, s.Size()] are clamped to that range. SplitAt is O(log(n)).
+func (s *SetEx[T]) SplitAt(i int) (left *SetEx[T], right *SetEx[T]) {
+	l, r := s.root().SplitAt(i)
+	return s.withTree(l, nil), s.withTree(r, nil)
+}
+
+// SliceByIndex returns a set containing the elements whose positions lie in [i, j). Indexes outside of
+// [0, s.Size()] are clamped to that range. SliceByIndex is O(log(n)).
+func (s *SetEx[T]) SliceByIndex(i int, j int) *SetEx[T] {
+	return s.withTree(s.root().SliceByIndex(i, j), nil)
+}
+
+// IterFromIndex returns an in-order traversal iterator that starts at the i'th smallest element of s.
+func (s *SetEx[T]) IterFromIndex(i int) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterFromIndex(i)}
+}
+
 // Contains return true if the set contains the given element.
 func (s *SetEx[T]) Contains(elem T) bool {
 	var currentRoot *TreeEx[T, bool]
@@ -100,6 +150,34 @@ func (s *SetEx[T]) GreatestLowerBound(value T) (T, bool) {
 	return kv.Key, true
 }
 
+// Successor returns the least element of s that is strictly greater than 'value'. If no such element exists, ok will
+// be false.
+func (s *SetEx[T]) Successor(value T) (e T, ok bool) {
+	p, ok := s.root().Successor(value)
+	return p.Key, ok
+}
+
+// Predecessor returns the greatest element of s that is strictly less than 'value'. If no such element exists, ok will
+// be false.
+func (s *SetEx[T]) Predecessor(value T) (e T, ok bool) {
+	p, ok := s.root().Predecessor(value)
+	return p.Key, ok
+}
+
+// PopLeast removes the least element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopLeast is O(log(n)).
+func (s *SetEx[T]) PopLeast() (e T, rest *SetEx[T], ok bool) {
+	p, tree, ok := s.root().PopLeast()
+	return p.Key, s.withTree(tree, nil), ok
+}
+
+// PopMost removes the greatest element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopMost is O(log(n)).
+func (s *SetEx[T]) PopMost() (e T, rest *SetEx[T], ok bool) {
+	p, tree, ok := s.root().PopMost()
+	return p.Key, s.withTree(tree, nil), ok
+}
+
 // Size returns the number of elements in the set.
 func (s *SetEx[T]) Size() int {
 	if s == nil {
@@ -126,11 +204,125 @@ func (s *SetEx[T]) IterGte(e T) Iterator[T] {
 	return &SetExIterator[T]{wrapped: currentRoot.IterGte(e)}
 }
 
+// IterRange returns an in-order traversal iterator over all elements x in s such that lo <= x <= hi. Use 'bounds' to
+// exclude either endpoint from the range.
+func (s *SetEx[T]) IterRange(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterRange(lo, hi, bounds)}
+}
+
+// IterLt returns an in-order traversal iterator over all elements x in s that are < e
+func (s *SetEx[T]) IterLt(e T) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterLt(e)}
+}
+
+// IterLte returns an in-order traversal iterator over all elements x in s that are <= e
+func (s *SetEx[T]) IterLte(e T) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterLte(e)}
+}
+
+// IterDesc returns an iterator over the elements in the set, from greatest to least.
+func (s *SetEx[T]) IterDesc() Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterDesc()}
+}
+
+// IterDescFrom returns an iterator over all elements x in s that are <= e, from greatest to least.
+func (s *SetEx[T]) IterDescFrom(e T) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterDescFrom(e)}
+}
+
+// IterRangeDesc returns an iterator over all elements x in s such that lo <= x <= hi, from greatest to least. Use
+// 'bounds' to exclude either endpoint from the range.
+func (s *SetEx[T]) IterRangeDesc(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetExIterator[T]{wrapped: s.root().IterRangeDesc(lo, hi, bounds)}
+}
+
 // IsEmpty returns true iif s is empty.
 func (s *SetEx[T]) IsEmpty() bool {
 	return s == nil || s.tree.IsEmpty()
 }
 
+// Union returns a set containing every element found in either s or 'other'.
+//
+// Union works directly on the underlying trees, so it is O(m*log(n/m + 1)) where m is the size of the smaller set
+// and n the size of the larger one.
+func (s *SetEx[T]) Union(other *SetEx[T]) *SetEx[T] {
+	return s.withTree(s.root().Union(other.root(), nil), other)
+}
+
+// Intersection returns a set containing the elements found in both s and 'other'. It is O(m*log(n/m + 1)).
+func (s *SetEx[T]) Intersection(other *SetEx[T]) *SetEx[T] {
+	return s.withTree(s.root().Intersection(other.root(), nil), other)
+}
+
+// Difference returns a set containing the elements of s that are not found in 'other'. It is O(m*log(n/m + 1)).
+func (s *SetEx[T]) Difference(other *SetEx[T]) *SetEx[T] {
+	return s.withTree(s.root().Difference(other.root()), other)
+}
+
+// SymmetricDifference returns a set containing the elements found in exactly one of s and 'other'. It is
+// O(m*log(n/m + 1)).
+func (s *SetEx[T]) SymmetricDifference(other *SetEx[T]) *SetEx[T] {
+	return s.withTree(s.root().symmetricDifference(other.root()), other)
+}
+
+// IsSubsetOf returns true iif every element of s is also an element of 'other'.
+func (s *SetEx[T]) IsSubsetOf(other *SetEx[T]) bool {
+	return s.root().isSubsetOf(other.root())
+}
+
+// IsSupersetOf returns true iif every element of 'other' is also an element of s.
+func (s *SetEx[T]) IsSupersetOf(other *SetEx[T]) bool {
+	return other.root().isSubsetOf(s.root())
+}
+
+// IsDisjoint returns true iif s and 'other' have no elements in common.
+func (s *SetEx[T]) IsDisjoint(other *SetEx[T]) bool {
+	return s.root().isDisjoint(other.root())
+}
+
+// Equal returns true iif s and 'other' contain the same elements.
+func (s *SetEx[T]) Equal(other *SetEx[T]) bool {
+	return s.Size() == other.Size() && s.root().isSubsetOf(other.root())
+}
+
+// Filter returns a set containing the elements of s for which pred returns true. Elements are visited in order.
+// Filter is O(n).
+func (s *SetEx[T]) Filter(pred func(elem T) bool) *SetEx[T] {
+	return s.withTree(s.root().Filter(func(elem T, _ bool) bool {
+		return pred(elem)
+	}), nil)
+}
+
+// Partition splits s into a set containing the elements for which pred returns true and a set containing the
+// remaining elements, in a single O(n) pass. Elements are visited in order.
+func (s *SetEx[T]) Partition(pred func(elem T) bool) (matching *SetEx[T], rest *SetEx[T]) {
+	in, out := s.root().Partition(func(elem T, _ bool) bool {
+		return pred(elem)
+	})
+	return s.withTree(in, nil), s.withTree(out, nil)
+}
+
+func (s *SetEx[T]) root() *TreeEx[T, bool] {
+	if s == nil {
+		return nil
+	}
+	return s.tree
+}
+
+// withTree returns a set backed by 'tree', reusing s or 'other' if either one is already backed by it.
+func (s *SetEx[T]) withTree(tree *TreeEx[T, bool], other *SetEx[T]) *SetEx[T] {
+	if tree.IsEmpty() {
+		return nil
+	}
+	if tree == s.root() {
+		return s
+	}
+	if tree == other.root() {
+		return other
+	}
+	return &SetEx[T]{tree: tree}
+}
+
 // MarshalJSON marshals the set s as a json array.a
 func (s *SetEx[T]) MarshalJSON() ([]byte, error) {
 	var arr []T
@@ -148,11 +340,18 @@ func (s *SetEx[T]) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	ret := &SetEx[T]{}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i].Less(arr[j])
+	})
+
+	unique := arr[:0]
 	for _, e := range arr {
-		ret = ret.Add(e)
+		if len(unique) == 0 || unique[len(unique)-1].Less(e) {
+			unique = append(unique, e)
+		}
 	}
-	*s = *ret
+
+	*s = SetEx[T]{tree: buildSetTreeEx(unique)}
 	return nil
 }
 
@@ -171,3 +370,29 @@ func (s *SetExIterator[T]) Current() T {
 func EmptySetEx[T Ordered[T]]() *SetEx[T] {
 	return nil
 }
+
+// SetExFromSorted returns a set containing 'elems', which must be sorted in strictly increasing order. If they are not,
+// the returned error wraps ErrUnsorted or ErrDuplicateKey. SetExFromSorted builds a perfectly balanced tree in O(n)
+// time.
+func SetExFromSorted[T Ordered[T]](elems []T) (*SetEx[T], error) {
+	err := checkSortedEx(len(elems), func(i int) T {
+		return elems[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree := buildSetTreeEx(elems)
+	if tree == nil {
+		return nil, nil
+	}
+	return &SetEx[T]{tree: tree}, nil
+}
+
+// buildSetTreeEx returns a perfectly balanced tree containing 'elems', which must already be sorted and unique.
+func buildSetTreeEx[T Ordered[T]](elems []T) *TreeEx[T, bool] {
+	if len(elems) == 0 {
+		return nil
+	}
+	mid := len(elems) / 2
+	return newExNode(buildSetTreeEx(elems[:mid]), buildSetTreeEx(elems[mid+1:]), elems[mid], true)
+}
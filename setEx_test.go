@@ -237,3 +237,120 @@ func TestSetExGetKeyNil(t *testing.T) {
 	_, ok := s.GetKthElement(0)
 	require.False(t, ok)
 }
+
+func setExOf(elems ...Int) *SetEx[Int] {
+	var ret *SetEx[Int]
+	for _, e := range elems {
+		ret = ret.Add(e)
+	}
+	return ret
+}
+
+func setExElems[T Ordered[T]](s *SetEx[T]) []T {
+	var ret []T
+	iter := s.Iter()
+	for iter.Next() {
+		ret = append(ret, iter.Current())
+	}
+	return ret
+}
+
+func TestSetExAlgebra(t *testing.T) {
+	a := setExOf(1, 2, 3, 5)
+	b := setExOf(3, 4, 5, 6)
+	require.Equal(t, []Int{1, 2, 3, 4, 5, 6}, setExElems(a.Union(b)))
+	require.Equal(t, []Int{3, 5}, setExElems(a.Intersection(b)))
+	require.Equal(t, []Int{1, 2}, setExElems(a.Difference(b)))
+	require.Equal(t, []Int{1, 2, 4, 6}, setExElems(a.SymmetricDifference(b)))
+	require.True(t, a == a.Union(a))
+	require.True(t, a.Difference(a).IsEmpty())
+}
+
+func TestSetExPredicates(t *testing.T) {
+	a := setExOf(1, 2, 3, 5)
+	b := setExOf(1, 5)
+	c := setExOf(4, 6)
+
+	require.True(t, b.IsSubsetOf(a))
+	require.False(t, a.IsSubsetOf(b))
+	require.True(t, a.IsSupersetOf(b))
+	require.True(t, a.IsDisjoint(c))
+	require.False(t, a.IsDisjoint(b))
+	require.True(t, a.Equal(setExOf(5, 3, 2, 1)))
+	require.False(t, a.Equal(setExOf(1, 2, 3, 6)))
+}
+
+func TestSetExRangeIterators(t *testing.T) {
+	s := setExOf(1, 3, 5, 7, 9)
+	require.Equal(t, []Int{5, 7}, collectElems(s.IterRange(3, 7, OpenLow)))
+	require.Equal(t, []Int{1, 3}, collectElems(s.IterLt(5)))
+	require.Equal(t, []Int{1, 3, 5}, collectElems(s.IterLte(5)))
+	require.Equal(t, []Int{9, 7, 5, 3, 1}, collectElems(s.IterDesc()))
+	require.Equal(t, []Int{5, 3, 1}, collectElems(s.IterDescFrom(5)))
+	require.Equal(t, []Int{7, 5, 3}, collectElems(s.IterRangeDesc(3, 7, Closed)))
+	require.Equal(t, []Int{7, 5}, collectElems(s.IterRangeDesc(3, 7, OpenLow)))
+	require.Equal(t, []Int{5, 3}, collectElems(s.IterRangeDesc(3, 7, OpenHigh)))
+	require.Equal(t, []Int{5}, collectElems(s.IterRangeDesc(3, 7, Open)))
+}
+
+func TestSetExOrderStatistics(t *testing.T) {
+	s := setExOf(10, 20, 30, 40)
+	require.Equal(t, 2, s.Rank(25))
+	require.Equal(t, 1, s.CountRange(20, 30, OpenLow))
+	m, ok := s.Median()
+	require.True(t, ok)
+	require.Equal(t, Int(20), m)
+	p, ok := s.Percentile(100)
+	require.True(t, ok)
+	require.Equal(t, Int(40), p)
+	p, _ = setExOf(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25).Percentile(28)
+	require.Equal(t, Int(7), p)
+}
+
+func TestSetExPositional(t *testing.T) {
+	s := setExOf(1, 3, 5, 7, 9)
+	require.Equal(t, []Int{1, 5, 7, 9}, setExElems(s.DeleteAt(1)))
+	left, right := s.SplitAt(2)
+	require.Equal(t, []Int{1, 3}, setExElems(left))
+	require.Equal(t, []Int{5, 7, 9}, setExElems(right))
+	require.Equal(t, []Int{3, 5, 7}, setExElems(s.SliceByIndex(1, 4)))
+	require.Equal(t, []Int{7, 9}, collectElems(s.IterFromIndex(3)))
+}
+
+func TestSetExFromSorted(t *testing.T) {
+	s, err := SetExFromSorted([]Int{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, []Int{1, 2, 3}, setExElems(s))
+	_, err = SetExFromSorted([]Int{2, 1})
+	require.ErrorIs(t, err, ErrUnsorted)
+}
+
+func TestSetExNavigation(t *testing.T) {
+	s := setExOf(1, 3, 5)
+
+	e, ok := s.Successor(Int(3))
+	require.True(t, ok)
+	require.Equal(t, Int(5), e)
+	e, ok = s.Predecessor(Int(3))
+	require.True(t, ok)
+	require.Equal(t, Int(1), e)
+
+	e, rest, ok := s.PopLeast()
+	require.True(t, ok)
+	require.Equal(t, Int(1), e)
+	require.Equal(t, 2, rest.Size())
+	e, _, ok = rest.PopMost()
+	require.True(t, ok)
+	require.Equal(t, Int(5), e)
+}
+
+func TestSetExFilterPartition(t *testing.T) {
+	s := setExOf(1, 2, 3, 4, 5, 6)
+	require.Equal(t, 3, s.Filter(func(e Int) bool { return e%2 == 0 }).Size())
+
+	in, out := s.Partition(func(e Int) bool { return e > 4 })
+	require.Equal(t, 2, in.Size())
+	require.True(t, in.Contains(Int(5)))
+	require.Equal(t, 4, out.Size())
+	require.False(t, out.Contains(Int(5)))
+}
@@ -0,0 +1,377 @@
+package persistent
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// SetFunc defines a persistent set ordered by a comparator function captured at construction. It offers the same API
+// as SetEx[T]. See TreeFunc for the requirements on the comparator.
+//
+// Note: Sets must be created with NewSetFunc (or SetFuncFromSorted) so they carry a comparator. A nil *SetFunc is a
+// valid empty set for all read-only operations. Every operation on a set created by NewSetFunc returns a non-nil set
+// that carries the same comparator, even when the result is empty. Operations combining two sets require both of them
+// to use the same comparator.
+//
+// Like TreeFunc, a SetFunc stores its comparator once, so the nodes of the set hold only their elements.
+type SetFunc[T any] struct {
+	root *funcNode[T, bool]
+	cmp  func(a, b T) int
+}
+
+// SetFuncIterator defines an iterator over a SetFunc.
+type SetFuncIterator[T any] struct {
+	wrapped *TreeFuncIterator[T, bool]
+}
+
+// NewSetFunc returns an empty set ordered by cmp.
+func NewSetFunc[T any](cmp func(a, b T) int) *SetFunc[T] {
+	return &SetFunc[T]{cmp: cmp}
+}
+
+// SetFuncFromSorted returns a set ordered by cmp containing 'elems', which must be sorted in strictly increasing order.
+// If they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey. SetFuncFromSorted builds a perfectly
+// balanced tree in O(n) time.
+func SetFuncFromSorted[T any](cmp func(a, b T) int, elems []T) (*SetFunc[T], error) {
+	err := checkSortedFunc(cmp, len(elems), func(i int) T {
+		return elems[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SetFunc[T]{root: buildSetFuncNode(elems), cmp: cmp}, nil
+}
+
+// GetKthElement returns the k'th smallest element in a set.
+// If no such element exists, ok will be false.
+func (s *SetFunc[T]) GetKthElement(k int) (e T, ok bool) {
+	p, ok := s.node().getKth(k)
+	return p.Key, ok
+}
+
+// Rank returns the number of elements in s that are less than e. It is O(log(n)).
+func (s *SetFunc[T]) Rank(e T) int {
+	return s.node().rank(s.comparator(), e)
+}
+
+// CountRange returns the number of elements x in s such that lo <= x <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(n)).
+func (s *SetFunc[T]) CountRange(lo T, hi T, bounds Bounds) int {
+	return s.node().countRange(s.comparator(), lo, hi, bounds)
+}
+
+// Median returns the median element of s. If s has an even number of elements, the lower of the two middle elements
+// is returned. If s is empty, ok will be false.
+func (s *SetFunc[T]) Median() (e T, ok bool) {
+	p, ok := s.node().getKth((s.Size() - 1) / 2)
+	return p.Key, ok
+}
+
+// Percentile returns the element at the p'th percentile of s, using the nearest-rank method. p must be between 0 and
+// 100. If s is empty or p is out of range, ok will be false.
+func (s *SetFunc[T]) Percentile(p float64) (e T, ok bool) {
+	pair, ok := s.node().percentile(p)
+	return pair.Key, ok
+}
+
+// DeleteAt returns a set with the i'th smallest element removed. If i is out of range, s is returned unchanged.
+// DeleteAt is O(log(n)).
+func (s *SetFunc[T]) DeleteAt(i int) *SetFunc[T] {
+	if i < 0 || i >= s.Size() {
+		return s
+	}
+	return s.withRoot(s.node().deleteAt(i))
+}
+
+// SplitAt partitions s by position. It returns a set containing the i smallest elements and a set containing the
+// remaining ones. Values of i outside of [0, s.Size()] are clamped to that range. SplitAt is O(log(n)).
+func (s *SetFunc[T]) SplitAt(i int) (left *SetFunc[T], right *SetFunc[T]) {
+	l, r := s.node().splitAt(i)
+	return s.withRoot(l), s.withRoot(r)
+}
+
+// SliceByIndex returns a set containing the elements whose positions lie in [i, j). Indexes outside of
+// [0, s.Size()] are clamped to that range. SliceByIndex is O(log(n)).
+func (s *SetFunc[T]) SliceByIndex(i int, j int) *SetFunc[T] {
+	return s.withRoot(s.node().sliceByIndex(i, j))
+}
+
+// IterFromIndex returns an in-order traversal iterator that starts at the i'th smallest element of s.
+func (s *SetFunc[T]) IterFromIndex(i int) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterFromIndex(i)}
+}
+
+// Contains return true if the set contains the given element.
+func (s *SetFunc[T]) Contains(elem T) bool {
+	_, found := s.node().find(s.comparator(), elem)
+	return found
+}
+
+// Remove returns a set with the given element removed.
+func (s *SetFunc[T]) Remove(elem T) *SetFunc[T] {
+	return s.withRoot(s.node().delete(s.comparator(), elem))
+}
+
+// Add returns a set with the given element added.
+func (s *SetFunc[T]) Add(elem T) *SetFunc[T] {
+	if s == nil || s.cmp == nil {
+		panic("add: SetFunc must be created with NewSetFunc")
+	}
+	if s.Contains(elem) {
+		return s
+	}
+	return s.withRoot(s.root.update(s.cmp, elem, true))
+}
+
+// LeastUpperBound returns the smallest element e in s, such that value <= e. If no such element exists, ok will be
+// false.
+func (s *SetFunc[T]) LeastUpperBound(value T) (e T, ok bool) {
+	p, ok := s.node().leastUpperBound(s.comparator(), value)
+	return p.Key, ok
+}
+
+// GreatestLowerBound returns the largest element e in s, such that e <= value. If no such element exists, ok will be
+// false.
+func (s *SetFunc[T]) GreatestLowerBound(value T) (T, bool) {
+	p, ok := s.node().greatestLowerBound(s.comparator(), value)
+	return p.Key, ok
+}
+
+// Successor returns the least element of s that is strictly greater than 'value'. If no such element exists, ok will
+// be false.
+func (s *SetFunc[T]) Successor(value T) (e T, ok bool) {
+	p, ok := s.node().successor(s.comparator(), value)
+	return p.Key, ok
+}
+
+// Predecessor returns the greatest element of s that is strictly less than 'value'. If no such element exists, ok will
+// be false.
+func (s *SetFunc[T]) Predecessor(value T) (e T, ok bool) {
+	p, ok := s.node().predecessor(s.comparator(), value)
+	return p.Key, ok
+}
+
+// PopLeast removes the least element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopLeast is O(log(n)).
+func (s *SetFunc[T]) PopLeast() (e T, rest *SetFunc[T], ok bool) {
+	if s.IsEmpty() {
+		return e, s.withRoot(nil), false
+	}
+	root, p := s.root.splitLeast()
+	return p.Key, s.withRoot(root), true
+}
+
+// PopMost removes the greatest element of s in a single descent. It returns the removed element along with the new
+// set. If s is empty, ok will be false. PopMost is O(log(n)).
+func (s *SetFunc[T]) PopMost() (e T, rest *SetFunc[T], ok bool) {
+	if s.IsEmpty() {
+		return e, s.withRoot(nil), false
+	}
+	root, p := s.root.splitMost()
+	return p.Key, s.withRoot(root), true
+}
+
+// Size returns the number of elements in s.
+func (s *SetFunc[T]) Size() int {
+	return s.node().Size()
+}
+
+// Iter returns an in-order traversal iterator over the elements in s.
+func (s *SetFunc[T]) Iter() Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iter()}
+}
+
+// IterGte returns an in-order traversal iterator over the elements x in s such that x >= e.
+func (s *SetFunc[T]) IterGte(e T) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterGte(s.comparator(), e)}
+}
+
+// IterRange returns an in-order traversal iterator over the elements x in s such that lo <= x <= hi. Use 'bounds' to
+// exclude either endpoint from the range.
+func (s *SetFunc[T]) IterRange(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterRange(s.comparator(), lo, hi, bounds)}
+}
+
+// IterLt returns an in-order traversal iterator over the elements x in s such that x < e.
+func (s *SetFunc[T]) IterLt(e T) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterLess(s.comparator(), e, false)}
+}
+
+// IterLte returns an in-order traversal iterator over the elements x in s such that x <= e.
+func (s *SetFunc[T]) IterLte(e T) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterLess(s.comparator(), e, true)}
+}
+
+// IterDesc returns an iterator that visits every element of s from greatest to least.
+func (s *SetFunc[T]) IterDesc() Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterDesc()}
+}
+
+// IterDescFrom returns an iterator that visits the elements x in s such that x <= e, from greatest to least.
+func (s *SetFunc[T]) IterDescFrom(e T) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterDescFrom(s.comparator(), e)}
+}
+
+// IterRangeDesc returns an iterator that visits the elements x in s such that lo <= x <= hi, from greatest to least.
+// Use 'bounds' to exclude either endpoint from the range.
+func (s *SetFunc[T]) IterRangeDesc(lo T, hi T, bounds Bounds) Iterator[T] {
+	return &SetFuncIterator[T]{wrapped: s.node().iterRangeDesc(s.comparator(), lo, hi, bounds)}
+}
+
+// IsEmpty returns true iif s is empty.
+func (s *SetFunc[T]) IsEmpty() bool {
+	return s.node() == nil
+}
+
+// Union returns a set containing the elements of both s and other.
+func (s *SetFunc[T]) Union(other *SetFunc[T]) *SetFunc[T] {
+	ret := s.orOther(other)
+	return ret.withRoot(s.node().union(ret.comparator(), other.node(), nil))
+}
+
+// Intersection returns a set containing the elements present in both s and other.
+func (s *SetFunc[T]) Intersection(other *SetFunc[T]) *SetFunc[T] {
+	return s.withRoot(s.node().intersection(s.orOther(other).comparator(), other.node(), nil))
+}
+
+// Difference returns a set containing the elements of s that are not present in other.
+func (s *SetFunc[T]) Difference(other *SetFunc[T]) *SetFunc[T] {
+	return s.withRoot(s.node().difference(s.orOther(other).comparator(), other.node()))
+}
+
+// SymmetricDifference returns a set containing the elements present in exactly one of s and other.
+func (s *SetFunc[T]) SymmetricDifference(other *SetFunc[T]) *SetFunc[T] {
+	ret := s.orOther(other)
+	return ret.withRoot(s.node().symmetricDifference(ret.comparator(), other.node()))
+}
+
+// IsSubsetOf returns true if every element of s is also an element of other.
+func (s *SetFunc[T]) IsSubsetOf(other *SetFunc[T]) bool {
+	return s.node().isSubsetOf(s.orOther(other).comparator(), other.node())
+}
+
+// IsSupersetOf returns true if every element of other is also an element of s.
+func (s *SetFunc[T]) IsSupersetOf(other *SetFunc[T]) bool {
+	return other.IsSubsetOf(s)
+}
+
+// IsDisjoint returns true if s and other have no elements in common.
+func (s *SetFunc[T]) IsDisjoint(other *SetFunc[T]) bool {
+	return s.node().isDisjoint(s.orOther(other).comparator(), other.node())
+}
+
+// Equal returns true if s and other contain the same elements.
+func (s *SetFunc[T]) Equal(other *SetFunc[T]) bool {
+	return s.Size() == other.Size() && s.IsSubsetOf(other)
+}
+
+// Filter returns a set containing the elements of s for which pred returns true. Filter is O(n).
+func (s *SetFunc[T]) Filter(pred func(elem T) bool) *SetFunc[T] {
+	return s.withRoot(s.node().filter(func(elem T, _ bool) bool {
+		return pred(elem)
+	}))
+}
+
+// Partition splits s into a set containing the elements for which pred returns true and a set containing the
+// remaining elements, in a single O(n) pass.
+func (s *SetFunc[T]) Partition(pred func(elem T) bool) (matching *SetFunc[T], rest *SetFunc[T]) {
+	in, out := s.node().partition(func(elem T, _ bool) bool {
+		return pred(elem)
+	})
+	return s.withRoot(in), s.withRoot(out)
+}
+
+// MarshalJSON marshals the set s as a json array.
+func (s *SetFunc[T]) MarshalJSON() ([]byte, error) {
+	var arr []T
+	iter := s.Iter()
+	for iter.Next() {
+		arr = append(arr, iter.Current())
+	}
+	return json.Marshal(arr)
+}
+
+// UnmarshalJSON unmarshals a json array into s, replacing its contents. If s was not created by NewSetFunc,
+// UnmarshalJSON returns ErrNoComparator.
+func (s *SetFunc[T]) UnmarshalJSON(data []byte) error {
+	if s.cmp == nil {
+		return ErrNoComparator
+	}
+	var arr []T
+	err := json.Unmarshal(data, &arr)
+	if err != nil {
+		return err
+	}
+	s.root = buildSetFuncNodeUnsorted(s.cmp, arr)
+	return nil
+}
+
+func (s *SetFunc[T]) node() *funcNode[T, bool] {
+	if s == nil {
+		return nil
+	}
+	return s.root
+}
+
+// comparator returns the comparator of s, or nil if s has none. Sets without a comparator are empty, so operations on
+// them never call it.
+func (s *SetFunc[T]) comparator() func(a, b T) int {
+	if s == nil {
+		return nil
+	}
+	return s.cmp
+}
+
+// withRoot returns a set rooted at 'root' that shares the comparator of s, reusing s if it already has that root.
+func (s *SetFunc[T]) withRoot(root *funcNode[T, bool]) *SetFunc[T] {
+	if s == nil {
+		if root == nil {
+			return nil
+		}
+		panic("SetFunc must be created with NewSetFunc")
+	}
+	if root == s.root {
+		return s
+	}
+	return &SetFunc[T]{root: root, cmp: s.cmp}
+}
+
+// orOther returns s if it carries a comparator, and 'other' otherwise.
+func (s *SetFunc[T]) orOther(other *SetFunc[T]) *SetFunc[T] {
+	if s == nil || s.cmp == nil {
+		return other
+	}
+	return s
+}
+
+// buildSetFuncNode returns a perfectly balanced tree containing 'elems', which must already be sorted and unique.
+func buildSetFuncNode[T any](elems []T) *funcNode[T, bool] {
+	if len(elems) == 0 {
+		return nil
+	}
+	mid := len(elems) / 2
+	return newFuncNode(buildSetFuncNode(elems[:mid]), buildSetFuncNode(elems[mid+1:]), elems[mid], true)
+}
+
+// buildSetFuncNodeUnsorted returns a perfectly balanced tree containing 'elems', which may be in any order. If an
+// element appears more than once, the first copy wins. 'elems' is sorted in place.
+func buildSetFuncNodeUnsorted[T any](cmp func(a, b T) int, elems []T) *funcNode[T, bool] {
+	sort.SliceStable(elems, func(i, j int) bool {
+		return cmp(elems[i], elems[j]) < 0
+	})
+	unique := elems[:0]
+	for _, e := range elems {
+		if len(unique) == 0 || cmp(unique[len(unique)-1], e) < 0 {
+			unique = append(unique, e)
+		}
+	}
+	return buildSetFuncNode(unique)
+}
+
+func (s *SetFuncIterator[T]) Next() bool {
+	return s.wrapped.Next()
+}
+
+func (s *SetFuncIterator[T]) Current() T {
+	return s.wrapped.Current().Key
+}
@@ -0,0 +1,83 @@
+package persistent
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func setFuncOf(elems ...int) *SetFunc[int] {
+	s := NewSetFunc(descending)
+	for _, e := range elems {
+		s = s.Add(e)
+	}
+	return s
+}
+
+func setFuncElems(s *SetFunc[int]) []int {
+	var ret []int
+	iter := s.Iter()
+	for iter.Next() {
+		ret = append(ret, iter.Current())
+	}
+	return ret
+}
+
+func TestSetFunc(t *testing.T) {
+	s := setFuncOf(1, 5, 3, 4, 2)
+	require.Equal(t, []int{5, 4, 3, 2, 1}, setFuncElems(s))
+	require.True(t, s.Contains(3))
+	require.Same(t, s, s.Add(3))
+
+	e, ok := s.GetKthElement(1)
+	require.True(t, ok)
+	require.Equal(t, 4, e)
+	e, _ = s.Median()
+	require.Equal(t, 3, e)
+
+	// s is ordered by a descending comparator, so lo and hi are in that order too.
+	require.Equal(t, []int{2, 3, 4}, collectElems(s.IterRangeDesc(4, 2, Closed)))
+	require.Equal(t, []int{3}, collectElems(s.IterRangeDesc(4, 2, Open)))
+
+	removed := s.Remove(3)
+	requireValidFuncNode(t, removed.cmp, removed.root)
+	require.Equal(t, []int{5, 4, 2, 1}, setFuncElems(removed))
+	require.True(t, s.Contains(3))
+
+	empty := s.Remove(1).Remove(2).Remove(3).Remove(4).Remove(5)
+	require.NotNil(t, empty)
+	require.True(t, empty.IsEmpty())
+	require.Equal(t, []int{7}, setFuncElems(empty.Add(7)))
+}
+
+func TestSetFuncAlgebra(t *testing.T) {
+	a := setFuncOf(1, 2, 3, 4)
+	b := setFuncOf(3, 4, 5)
+
+	require.Equal(t, []int{5, 4, 3, 2, 1}, setFuncElems(a.Union(b)))
+	requireValidFuncNode(t, descending, a.SymmetricDifference(b).root)
+	require.Equal(t, []int{4, 3}, setFuncElems(a.Intersection(b)))
+	require.Equal(t, []int{2, 1}, setFuncElems(a.Difference(b)))
+	require.Equal(t, []int{5, 2, 1}, setFuncElems(a.SymmetricDifference(b)))
+	require.True(t, setFuncOf(3, 4).IsSubsetOf(a))
+	require.True(t, a.IsSupersetOf(setFuncOf(1)))
+	require.True(t, a.IsDisjoint(setFuncOf(9)))
+	require.True(t, a.Equal(setFuncOf(4, 3, 2, 1)))
+
+	var nilSet *SetFunc[int]
+	require.Equal(t, []int{5, 4, 3}, setFuncElems(nilSet.Union(b)))
+	require.Panics(t, func() { nilSet.Add(1) })
+}
+
+func TestSetFuncJSON(t *testing.T) {
+	data, err := json.Marshal(setFuncOf(1, 2, 3))
+	require.NoError(t, err)
+	require.Equal(t, `[3,2,1]`, string(data))
+
+	decoded := NewSetFunc(descending)
+	require.NoError(t, json.Unmarshal([]byte(`[1, 3, 2, 3]`), decoded))
+	require.Equal(t, []int{3, 2, 1}, setFuncElems(decoded))
+
+	var noComparator SetFunc[int]
+	require.ErrorIs(t, json.Unmarshal(data, &noComparator), ErrNoComparator)
+}
@@ -3,6 +3,7 @@ package persistent
 import (
 	"encoding/json"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/constraints"
 	"testing"
 )
 
@@ -237,3 +238,191 @@ func TestSetGetKthNil(t *testing.T) {
 	_, ok := s.GetKthElement(0)
 	require.False(t, ok)
 }
+
+func setOf(elems ...int) *Set[int] {
+	var ret *Set[int]
+	for _, e := range elems {
+		ret = ret.Add(e)
+	}
+	return ret
+}
+
+func setElems[T constraints.Ordered](s *Set[T]) []T {
+	var ret []T
+	iter := s.Iter()
+	for iter.Next() {
+		ret = append(ret, iter.Current())
+	}
+	return ret
+}
+
+func TestSetUnion(t *testing.T) {
+	a := setOf(1, 2, 3, 5)
+	b := setOf(3, 4, 5, 6)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, setElems(a.Union(b)))
+	require.True(t, a == a.Union(nil))
+	require.True(t, b == EmptySet[int]().Union(b))
+	require.True(t, a == a.Union(a))
+}
+
+func TestSetIntersection(t *testing.T) {
+	a := setOf(1, 2, 3, 5)
+	b := setOf(3, 4, 5, 6)
+	require.Equal(t, []int{3, 5}, setElems(a.Intersection(b)))
+	require.True(t, a.Intersection(setOf(7, 8)).IsEmpty())
+	require.True(t, a.Intersection(nil).IsEmpty())
+}
+
+func TestSetDifference(t *testing.T) {
+	a := setOf(1, 2, 3, 5)
+	b := setOf(3, 4, 5, 6)
+	require.Equal(t, []int{1, 2}, setElems(a.Difference(b)))
+	require.Equal(t, []int{4, 6}, setElems(b.Difference(a)))
+	require.True(t, a == a.Difference(setOf(7, 8)))
+	require.True(t, a.Difference(a).IsEmpty())
+}
+
+func TestSetSymmetricDifference(t *testing.T) {
+	a := setOf(1, 2, 3, 5)
+	b := setOf(3, 4, 5, 6)
+	require.Equal(t, []int{1, 2, 4, 6}, setElems(a.SymmetricDifference(b)))
+	require.True(t, a.SymmetricDifference(a).IsEmpty())
+	require.True(t, a == a.SymmetricDifference(nil))
+}
+
+func TestSetPredicates(t *testing.T) {
+	a := setOf(1, 2, 3, 5)
+	b := setOf(1, 5)
+	c := setOf(4, 6)
+
+	require.True(t, b.IsSubsetOf(a))
+	require.False(t, a.IsSubsetOf(b))
+	require.True(t, a.IsSupersetOf(b))
+	require.False(t, b.IsSupersetOf(a))
+	require.True(t, EmptySet[int]().IsSubsetOf(a))
+	require.True(t, a.IsSubsetOf(a))
+
+	require.True(t, a.IsDisjoint(c))
+	require.False(t, a.IsDisjoint(b))
+	require.True(t, a.IsDisjoint(nil))
+
+	require.True(t, a.Equal(setOf(5, 3, 2, 1)))
+	require.False(t, a.Equal(b))
+	require.False(t, a.Equal(setOf(1, 2, 3, 6)))
+	require.True(t, EmptySet[int]().Equal(&Set[int]{}))
+}
+
+func collectElems[T any](iter Iterator[T]) []T {
+	var ret []T
+	for iter.Next() {
+		ret = append(ret, iter.Current())
+	}
+	return ret
+}
+
+func TestSetRangeIterators(t *testing.T) {
+	s := setOf(1, 3, 5, 7, 9)
+	require.Equal(t, []int{3, 5}, collectElems(s.IterRange(3, 7, OpenHigh)))
+	require.Equal(t, []int{1, 3}, collectElems(s.IterLt(5)))
+	require.Equal(t, []int{1, 3, 5}, collectElems(s.IterLte(5)))
+	require.Equal(t, []int{9, 7, 5, 3, 1}, collectElems(s.IterDesc()))
+	require.Equal(t, []int{5, 3, 1}, collectElems(s.IterDescFrom(6)))
+	require.Equal(t, []int{7, 5, 3}, collectElems(s.IterRangeDesc(3, 7, Closed)))
+	require.Equal(t, []int{7, 5}, collectElems(s.IterRangeDesc(3, 7, OpenLow)))
+	require.Equal(t, []int{5, 3}, collectElems(s.IterRangeDesc(3, 7, OpenHigh)))
+	require.Equal(t, []int{5}, collectElems(s.IterRangeDesc(3, 7, Open)))
+	require.Empty(t, collectElems(EmptySet[int]().IterDesc()))
+}
+
+func TestSetOrderStatistics(t *testing.T) {
+	s := setOf(10, 20, 30, 40)
+	require.Equal(t, 2, s.Rank(25))
+	require.Equal(t, 2, s.CountRange(20, 30, Closed))
+	m, ok := s.Median()
+	require.True(t, ok)
+	require.Equal(t, 20, m)
+	p, ok := s.Percentile(75)
+	require.True(t, ok)
+	require.Equal(t, 30, p)
+	p, _ = setOf(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25).Percentile(28)
+	require.Equal(t, 7, p)
+
+	_, ok = EmptySet[int]().Median()
+	require.False(t, ok)
+	require.Equal(t, 0, EmptySet[int]().CountRange(0, 1, Closed))
+}
+
+func TestSetPositional(t *testing.T) {
+	s := setOf(1, 3, 5, 7, 9)
+	require.Equal(t, []int{1, 5, 7, 9}, setElems(s.DeleteAt(1)))
+	require.True(t, s == s.DeleteAt(5))
+
+	left, right := s.SplitAt(2)
+	require.Equal(t, []int{1, 3}, setElems(left))
+	require.Equal(t, []int{5, 7, 9}, setElems(right))
+	left, right = s.SplitAt(0)
+	require.True(t, left.IsEmpty())
+	require.True(t, s == right)
+
+	require.Equal(t, []int{3, 5, 7}, setElems(s.SliceByIndex(1, 4)))
+	require.Equal(t, []int{7, 9}, collectElems(s.IterFromIndex(3)))
+}
+
+func TestSetFromSorted(t *testing.T) {
+	s, err := SetFromSorted([]int{1, 2, 3, 5, 8})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 5, 8}, setElems(s))
+
+	s, err = SetFromSorted([]int{})
+	require.NoError(t, err)
+	require.True(t, s.IsEmpty())
+
+	_, err = SetFromSorted([]int{1, 3, 2})
+	require.ErrorIs(t, err, ErrUnsorted)
+	_, err = SetFromSorted([]int{1, 1})
+	require.ErrorIs(t, err, ErrDuplicateKey)
+}
+
+func TestSetUnmarshalJsonDuplicates(t *testing.T) {
+	var s *Set[int]
+	require.NoError(t, json.Unmarshal([]byte("[3, 1, 2, 3, 1]"), &s))
+	require.Equal(t, []int{1, 2, 3}, setElems(s))
+	requireValidTree(t, s.tree)
+}
+
+func TestSetNavigation(t *testing.T) {
+	s := setOf(1, 3, 5)
+
+	e, ok := s.Successor(3)
+	require.True(t, ok)
+	require.Equal(t, 5, e)
+	e, ok = s.Predecessor(3)
+	require.True(t, ok)
+	require.Equal(t, 1, e)
+	_, ok = s.Predecessor(1)
+	require.False(t, ok)
+
+	e, rest, ok := s.PopLeast()
+	require.True(t, ok)
+	require.Equal(t, 1, e)
+	require.Equal(t, []int{3, 5}, setElems(rest))
+	e, rest, ok = rest.PopMost()
+	require.True(t, ok)
+	require.Equal(t, 5, e)
+	_, rest, ok = rest.PopMost()
+	require.True(t, ok)
+	require.Nil(t, rest)
+	_, _, ok = rest.PopLeast()
+	require.False(t, ok)
+}
+
+func TestSetFilterPartition(t *testing.T) {
+	s := setOf(1, 2, 3, 4, 5, 6)
+	require.Equal(t, []int{2, 4, 6}, setElems(s.Filter(func(e int) bool { return e%2 == 0 })))
+	require.Same(t, s, s.Filter(func(int) bool { return true }))
+	require.Nil(t, s.Filter(func(int) bool { return false }))
+
+	in, out := s.Partition(func(e int) bool { return e > 4 })
+	require.Equal(t, []int{5, 6}, setElems(in))
+	require.Equal(t, []int{1, 2, 3, 4}, setElems(out))
+}
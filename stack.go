@@ -1,11 +1,15 @@
 package persistent
 
+import (
+	"encoding/json"
+)
+
 // Stack implements a persistent stack.
 //
 // Persistent stacks are immutable. Each mutating operation will return a pointer to a new stack with the
 // update applied.The implementation uses structural sharing to make immutability efficient. The implementation is
 // concurrency safe and non-blocking. A *Stack[T] instance may be accessed from multiple go-routines without
-// synchronization. Each mutating Stack[T] operation is O(1).
+// synchronization. Push and Pop are O(1); operations that rebuild part of the stack document their cost.
 type Stack[T any] struct {
 	next  *Stack[T]
 	value T
@@ -51,7 +55,7 @@ func (s *Stack[T]) Size() int {
 	return s.size
 }
 
-// Reverse returns the stack s in reverse order. This is mostly used in the implementation of Queue[T].
+// Reverse returns the stack s in reverse order. This is O(n).
 func (s *Stack[T]) Reverse() *Stack[T] {
 	var ret *Stack[T]
 	for ; !s.IsEmpty(); s = s.Pop() {
@@ -60,6 +64,186 @@ func (s *Stack[T]) Reverse() *Stack[T] {
 	return ret
 }
 
+// Nth returns the element i positions below the top of the stack. If i is out of range, ok will be false. This is O(i).
+func (s *Stack[T]) Nth(i int) (value T, ok bool) {
+	if i < 0 || i >= s.Size() {
+		return value, false
+	}
+	return s.Drop(i).Peek(), true
+}
+
+// Drop returns the stack with the top n elements removed. The result shares all of its elements with s. This is O(n).
+func (s *Stack[T]) Drop(n int) *Stack[T] {
+	for ; n > 0 && !s.IsEmpty(); n-- {
+		s = s.Pop()
+	}
+	if s.IsEmpty() {
+		return nil
+	}
+	return s
+}
+
+// Take returns a new stack containing the top n elements of s, in the same order. If n >= s.Size(), s itself is
+// returned. This is O(n).
+func (s *Stack[T]) Take(n int) *Stack[T] {
+	if n >= s.Size() {
+		return s
+	}
+	if n <= 0 {
+		return nil
+	}
+	top := make([]T, 0, n)
+	for cur := s; len(top) < n; cur = cur.Pop() {
+		top = append(top, cur.Peek())
+	}
+	return StackFromSlice(top)
+}
+
+// Append returns a new stack containing the elements of s followed by the elements of 'other', so that the top of s
+// is on top of the result. The result shares all of its elements below the top s.Size() with 'other'. This is O(n),
+// where n is the size of s.
+func (s *Stack[T]) Append(other *Stack[T]) *Stack[T] {
+	if s.IsEmpty() {
+		return other
+	}
+	if other.IsEmpty() {
+		return s
+	}
+	ret := other
+	elems := s.ToSlice()
+	for i := len(elems) - 1; i >= 0; i-- {
+		ret = ret.Push(elems[i])
+	}
+	return ret
+}
+
+// Filter returns a stack containing the elements of s for which pred returns true, in the same order. The elements
+// below the last one removed are shared with s, and if pred returns true for every element, s itself is returned.
+// Elements are visited from the top down. This is O(n).
+func (s *Stack[T]) Filter(pred func(value T) bool) *Stack[T] {
+	var kept []T
+	var keptBelow int // The number of elements of kept since the last element removed.
+	tail := s
+	for cur := s; !cur.IsEmpty(); cur = cur.Pop() {
+		if pred(cur.Peek()) {
+			kept = append(kept, cur.Peek())
+			keptBelow++
+		} else {
+			tail = cur.Pop()
+			keptBelow = 0
+		}
+	}
+
+	ret := tail
+	for i := len(kept) - keptBelow - 1; i >= 0; i-- {
+		ret = ret.Push(kept[i])
+	}
+	if ret.IsEmpty() {
+		return nil
+	}
+	return ret
+}
+
+// Equal returns true if s and 'other' contain the same elements in the same order, comparing elements with eq.
+// Shared tails are not compared.
+func (s *Stack[T]) Equal(other *Stack[T], eq func(a, b T) bool) bool {
+	if s.Size() != other.Size() {
+		return false
+	}
+	for ; !s.IsEmpty() && s != other; s, other = s.Pop(), other.Pop() {
+		if !eq(s.Peek(), other.Peek()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ToSlice returns the elements of the stack from top to bottom. It returns nil if the stack is empty.
+func (s *Stack[T]) ToSlice() []T {
+	if s.IsEmpty() {
+		return nil
+	}
+	ret := make([]T, 0, s.Size())
+	for ; !s.IsEmpty(); s = s.Pop() {
+		ret = append(ret, s.Peek())
+	}
+	return ret
+}
+
+// Iter returns an iterator over the elements of the stack from top to bottom.
+func (s *Stack[T]) Iter() Iterator[T] {
+	return &StackIterator[T]{next: s}
+}
+
+// StackIterator defines an iterator over a Stack.
+type StackIterator[T any] struct {
+	next    *Stack[T]
+	current *Stack[T]
+}
+
+func (i *StackIterator[T]) Next() bool {
+	i.current = i.next
+	i.next = i.next.Pop()
+	return !i.current.IsEmpty()
+}
+
+func (i *StackIterator[T]) Current() T {
+	if i.current.IsEmpty() {
+		panic("invalid iterator position")
+	}
+	return i.current.value
+}
+
+func (s *Stack[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToSlice())
+}
+
+func (s *Stack[T]) UnmarshalJSON(bytes []byte) error {
+	var buf []T
+	err := json.Unmarshal(bytes, &buf)
+	if err != nil {
+		return err
+	}
+	tmp := StackFromSlice(buf)
+	if tmp == nil {
+		tmp = &Stack[T]{}
+	}
+	*s = *tmp
+	return nil
+}
+
+// StackFromSlice returns a stack containing 'items', with items[0] on top. This is O(n).
+func StackFromSlice[T any](items []T) *Stack[T] {
+	var ret *Stack[T]
+	for i := len(items) - 1; i >= 0; i-- {
+		ret = ret.Push(items[i])
+	}
+	return ret
+}
+
+// MapStack returns a new stack containing f(value) for every element of s, in the same order. f is called on the
+// elements from the top down. This is O(n).
+func MapStack[T any, U any](s *Stack[T], f func(value T) U) *Stack[U] {
+	if s.IsEmpty() {
+		return nil
+	}
+	mapped := make([]U, 0, s.Size())
+	for ; !s.IsEmpty(); s = s.Pop() {
+		mapped = append(mapped, f(s.Peek()))
+	}
+	return StackFromSlice(mapped)
+}
+
+// FoldStack calls f on each element of s from the top down, threading an accumulator through the calls, and returns
+// the final accumulator. The first call receives 'initial'.
+func FoldStack[T any, A any](s *Stack[T], initial A, f func(acc A, value T) A) A {
+	acc := initial
+	for ; !s.IsEmpty(); s = s.Pop() {
+		acc = f(acc, s.Peek())
+	}
+	return acc
+}
+
 // EmptyStack returns a new empty Stack[T].
 func EmptyStack[T any]() *Stack[T] {
 	return nil
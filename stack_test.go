@@ -1,7 +1,9 @@
 package persistent
 
 import (
+	"encoding/json"
 	"github.com/stretchr/testify/require"
+	"strconv"
 	"testing"
 )
 
@@ -35,3 +37,112 @@ func TestReverse(t *testing.T) {
 	require.Equal(t, 2, reversed.Pop().Peek())
 	require.True(t, reversed.Pop().Pop().IsEmpty())
 }
+
+func TestStackFromSlice(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3})
+	require.Equal(t, 1, s.Peek())
+	require.Equal(t, 3, s.Size())
+	require.Equal(t, []int{1, 2, 3}, s.ToSlice())
+	require.Nil(t, StackFromSlice[int](nil))
+	require.Nil(t, EmptyStack[int]().ToSlice())
+}
+
+func TestStackIter(t *testing.T) {
+	it := StackFromSlice([]int{1, 2, 3}).Iter()
+	require.Panics(t, func() { it.Current() })
+	var actual []int
+	for it.Next() {
+		actual = append(actual, it.Current())
+	}
+	require.Equal(t, []int{1, 2, 3}, actual)
+	require.False(t, it.Next())
+	require.Panics(t, func() { it.Current() })
+	require.False(t, EmptyStack[int]().Iter().Next())
+}
+
+func TestStackNth(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3})
+	for i := 0; i < 3; i++ {
+		v, ok := s.Nth(i)
+		require.True(t, ok)
+		require.Equal(t, i+1, v)
+	}
+	_, ok := s.Nth(3)
+	require.False(t, ok)
+	_, ok = s.Nth(-1)
+	require.False(t, ok)
+}
+
+func TestStackDropTake(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3, 4})
+	require.Equal(t, []int{3, 4}, s.Drop(2).ToSlice())
+	require.True(t, s.Pop().Pop() == s.Drop(2))
+	require.True(t, s == s.Drop(0))
+	require.Nil(t, s.Drop(4))
+	require.Nil(t, s.Drop(10))
+
+	require.Equal(t, []int{1, 2}, s.Take(2).ToSlice())
+	require.True(t, s == s.Take(4))
+	require.Nil(t, s.Take(0))
+}
+
+func TestStackAppend(t *testing.T) {
+	a := StackFromSlice([]int{1, 2})
+	b := StackFromSlice([]int{3, 4})
+	ab := a.Append(b)
+	require.Equal(t, []int{1, 2, 3, 4}, ab.ToSlice())
+	require.True(t, b == ab.Drop(2))
+	require.True(t, a == a.Append(nil))
+	require.True(t, b == EmptyStack[int]().Append(b))
+}
+
+func TestMapStack(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3})
+	require.Equal(t, []string{"1", "2", "3"}, MapStack(s, strconv.Itoa).ToSlice())
+	require.Nil(t, MapStack(EmptyStack[int](), strconv.Itoa))
+}
+
+func TestStackFilter(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3, 4, 5, 6})
+	odd := s.Filter(func(v int) bool { return v%2 == 1 })
+	require.Equal(t, []int{1, 3, 5}, odd.ToSlice())
+
+	small := s.Filter(func(v int) bool { return v != 2 })
+	require.Equal(t, []int{1, 3, 4, 5, 6}, small.ToSlice())
+	require.True(t, s.Drop(2) == small.Drop(1))
+
+	require.True(t, s == s.Filter(func(v int) bool { return true }))
+	require.Nil(t, s.Filter(func(v int) bool { return false }))
+}
+
+func TestFoldStack(t *testing.T) {
+	s := StackFromSlice([]int{1, 2, 3})
+	require.Equal(t, "123", FoldStack(s, "", func(acc string, v int) string { return acc + strconv.Itoa(v) }))
+	require.Equal(t, 7, FoldStack(EmptyStack[int](), 7, func(acc int, v int) int { return acc + v }))
+}
+
+func TestStackEqual(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	a := StackFromSlice([]int{1, 2, 3})
+	require.True(t, a.Equal(StackFromSlice([]int{1, 2, 3}), eq))
+	require.True(t, a.Equal(a.Pop().Push(1), eq))
+	require.False(t, a.Equal(StackFromSlice([]int{1, 2, 4}), eq))
+	require.False(t, a.Equal(a.Pop(), eq))
+	require.True(t, EmptyStack[int]().Equal(nil, eq))
+}
+
+func TestJsonStack(t *testing.T) {
+	bytes, err := json.Marshal(StackFromSlice([]int{1, 2, 3}))
+	require.NoError(t, err)
+	require.JSONEq(t, `[1, 2, 3]`, string(bytes))
+
+	var s *Stack[int]
+	err = json.Unmarshal(bytes, &s)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, s.ToSlice())
+	require.Equal(t, 1, s.Peek())
+
+	err = json.Unmarshal([]byte(`[]`), &s)
+	require.NoError(t, err)
+	require.True(t, s.IsEmpty())
+}
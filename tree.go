@@ -2,7 +2,10 @@ package persistent
 
 import (
 	"encoding/json"
+	"fmt"
 	"golang.org/x/exp/constraints"
+	"math"
+	"sort"
 )
 
 // Tree implements a persistent AVL tree for keys types that support the < operator. For custom key types see
@@ -31,12 +34,24 @@ type Tree[K constraints.Ordered, V any] struct {
 	value  V
 	size   int
 	height int
+
+	// owner is the builder allowed to modify this node in place, if any. See TreeBuilder.
+	owner *owner
 }
 
 // TreeIterator defines an iterator over a Tree
 type TreeIterator[K constraints.Ordered, V any] struct {
 	stack   []*Tree[K, V]
 	current *Tree[K, V]
+
+	// descending is true for iterators that visit keys from greatest to least.
+	descending bool
+
+	// bounded is true for iterators that stop at 'limit'. The limit is an upper bound for ascending iterators and a
+	// lower bound for descending ones. It is visited only if 'inclusive' is true.
+	bounded   bool
+	limit     K
+	inclusive bool
 }
 
 // GetKthElement returns the k'th smallest element in a Tree.
@@ -60,6 +75,146 @@ func (n *Tree[K, V]) GetKthElement(k int) (p Pair[K, V], ok bool) {
 	return n.Left().GetKthElement(k)
 }
 
+// Rank returns the number of keys in the tree that are less than 'key'. It is the inverse of GetKthElement: if the
+// tree contains 'key', then n.GetKthElement(n.Rank(key)) returns its entry. Rank is O(log(N)).
+func (n *Tree[K, V]) Rank(key K) int {
+	rank := 0
+	for !n.IsEmpty() {
+		if n.key < key {
+			rank += n.left.Size() + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return rank
+}
+
+// rankLte returns the number of keys in the tree that are less than or equal to 'key'.
+func (n *Tree[K, V]) rankLte(key K) int {
+	rank := 0
+	for !n.IsEmpty() {
+		if key < n.key {
+			n = n.left
+		} else {
+			rank += n.left.Size() + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// CountRange returns the number of keys k in the tree such that lo <= k <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(N)).
+func (n *Tree[K, V]) CountRange(lo K, hi K, bounds Bounds) int {
+	var lower, upper int
+	if bounds.excludesLow() {
+		lower = n.rankLte(lo)
+	} else {
+		lower = n.Rank(lo)
+	}
+	if bounds.excludesHigh() {
+		upper = n.Rank(hi)
+	} else {
+		upper = n.rankLte(hi)
+	}
+
+	if upper < lower {
+		return 0
+	}
+	return upper - lower
+}
+
+// Median returns the median element of the tree. If the tree has an even number of elements, the lower of the two
+// middle elements is returned. If the tree is empty, ok will be false.
+func (n *Tree[K, V]) Median() (p Pair[K, V], ok bool) {
+	return n.GetKthElement((n.Size() - 1) / 2)
+}
+
+// Percentile returns the element at the p'th percentile of the tree, using the nearest-rank method: the result is the
+// smallest element such that at least p percent of the elements are less than or equal to it. p must be between 0 and
+// 100. If the tree is empty or p is out of range, ok will be false.
+func (n *Tree[K, V]) Percentile(p float64) (e Pair[K, V], ok bool) {
+	if n.IsEmpty() || !(p >= 0 && p <= 100) {
+		return e, false
+	}
+	return n.GetKthElement(percentileIndex(p, n.Size()))
+}
+
+// percentileIndex returns the index of the nearest-rank p'th percentile in a sorted sequence of 'size' elements. It
+// multiplies before dividing, so that whole percentiles give exact ranks: dividing p by 100 first can round the
+// product up past a whole number, and Ceil then picks the next rank.
+func percentileIndex(p float64, size int) int {
+	k := int(math.Ceil(p*float64(size)/100)) - 1
+	if k < 0 {
+		return 0
+	}
+	return k
+}
+
+// DeleteAt returns the root of a new tree with the i'th smallest entry removed. If i is out of range, n is returned
+// unchanged. DeleteAt is O(log(N)).
+func (n *Tree[K, V]) DeleteAt(i int) *Tree[K, V] {
+	if i < 0 || i >= n.Size() {
+		return n
+	}
+	return n.deleteAt(i)
+}
+
+func (n *Tree[K, V]) deleteAt(i int) *Tree[K, V] {
+	leftSize := n.left.Size()
+
+	if i < leftSize {
+		return newNode(n.left.deleteAt(i), n.right, n.key, n.value).rebalance()
+	}
+
+	if i > leftSize {
+		return newNode(n.left, n.right.deleteAt(i-leftSize-1), n.key, n.value).rebalance()
+	}
+
+	return n.deleteCurrent().rebalance()
+}
+
+// SplitAt partitions the tree by position. It returns a tree containing the i smallest entries and a tree containing
+// the remaining ones. Values of i outside of [0, n.Size()] are clamped to that range. SplitAt is O(log(N)), and the
+// returned trees share all untouched subtrees with n.
+func (n *Tree[K, V]) SplitAt(i int) (left *Tree[K, V], right *Tree[K, V]) {
+	if i <= 0 {
+		return nil, n
+	}
+	if i >= n.Size() {
+		return n, nil
+	}
+
+	leftSize := n.left.Size()
+	if i <= leftSize {
+		left, right = n.left.SplitAt(i)
+		return left, right.join(n.key, n.value, n.right)
+	}
+
+	left, right = n.right.SplitAt(i - leftSize - 1)
+	return n.left.join(n.key, n.value, left), right
+}
+
+// SliceByIndex returns the root of a new tree containing the entries whose positions lie in [i, j). Indexes outside of
+// [0, n.Size()] are clamped to that range. SliceByIndex is O(log(N)).
+func (n *Tree[K, V]) SliceByIndex(i int, j int) *Tree[K, V] {
+	if i >= j {
+		return nil
+	}
+	prefix, _ := n.SplitAt(j)
+	_, ret := prefix.SplitAt(i)
+	return ret
+}
+
+// IterFromIndex returns an in-order iterator that starts at the i'th smallest entry of the tree. Positioning the
+// iterator is O(log(N)). If i is negative, iteration starts at the least entry.
+func (n *Tree[K, V]) IterFromIndex(i int) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{}
+	ret.seekIndex(n, i)
+	return ret
+}
+
 // Key returns the key associated with the node n. If n is empty, the zero value for K is returned.
 func (n *Tree[K, V]) Key() K {
 	if n.IsEmpty() {
@@ -142,6 +297,48 @@ func (n *Tree[K, V]) Update(key K, value V) *Tree[K, V] {
 	return newNode(n.left, n.right, key, value)
 }
 
+// Alter inserts, updates or deletes the entry for 'key' in a single descent. f receives the current value for 'key'
+// (found is false if there is no entry) and returns the new value, along with whether the entry should be kept. If
+// keep is false, the entry is removed.
+//
+// If nothing changes, Alter returns n itself, so callers can detect changes by comparing pointers. Nothing changes
+// when the key is absent and f does not keep it, or when eq is not nil and eq(old, new) reports the values are equal.
+func (n *Tree[K, V]) Alter(key K, f func(old V, found bool) (value V, keep bool), eq func(a, b V) bool) *Tree[K, V] {
+	if n.IsEmpty() {
+		var zv V
+		value, keep := f(zv, false)
+		if !keep {
+			return n
+		}
+		return newNode(nil, nil, key, value)
+	}
+
+	if n.key < key {
+		right := n.right.Alter(key, f, eq)
+		if right == n.right {
+			return n
+		}
+		return newNode(n.left, right, n.key, n.value).rebalance()
+	}
+
+	if key < n.key {
+		left := n.left.Alter(key, f, eq)
+		if left == n.left {
+			return n
+		}
+		return newNode(left, n.right, n.key, n.value).rebalance()
+	}
+
+	value, keep := f(n.value, true)
+	if !keep {
+		return n.deleteCurrent().rebalance()
+	}
+	if eq != nil && eq(n.value, value) {
+		return n
+	}
+	return newNode(n.left, n.right, n.key, value)
+}
+
 // Height returns the height of the tree rooted at node n. Will return 0 if n is empty.
 func (n *Tree[K, V]) Height() int {
 	if n.IsEmpty() {
@@ -166,14 +363,14 @@ func (n *Tree[K, V]) rebalance() *Tree[K, V] {
 	if balance > 0 {
 		rightBalance := n.right.balanceFactor()
 
-		if rightBalance > 0 {
+		if rightBalance >= 0 {
 			return n.rotateLeft()
 		}
 
 		return n.rotateRightLeft()
 	} else {
 		leftBalance := n.left.balanceFactor()
-		if leftBalance < 0 {
+		if leftBalance <= 0 {
 			return n.rotateRight()
 		}
 
@@ -311,6 +508,393 @@ func (n *Tree[K, V]) Size() int {
 	return n.size
 }
 
+func (n *Tree[K, V]) leftMost() *Tree[K, V] {
+	if n.IsEmpty() {
+		return nil
+	}
+	current := n
+
+	for !current.left.IsEmpty() {
+		current = current.left
+	}
+	return current
+}
+
+// Split partitions the tree around 'key'. It returns a tree containing every entry with a key less than 'key', the
+// entry for 'key' itself (found is false if there is no such entry), and a tree containing every entry with a key
+// greater than 'key'. Split is O(log(N)), and the returned trees share all untouched subtrees with n.
+func (n *Tree[K, V]) Split(key K) (less *Tree[K, V], p Pair[K, V], found bool, greater *Tree[K, V]) {
+	if n.IsEmpty() {
+		return nil, p, false, nil
+	}
+
+	if n.key < key {
+		less, p, found, greater = n.right.Split(key)
+		return n.left.join(n.key, n.value, less), p, found, greater
+	}
+
+	if key < n.key {
+		less, p, found, greater = n.left.Split(key)
+		return less, p, found, greater.join(n.key, n.value, n.right)
+	}
+
+	return n.left, n.pair(), true, n.right
+}
+
+// Join returns the root of a new tree containing every entry of n, the entry 'pivot', and every entry of 'right'.
+// Every key in n must be less than pivot.Key, and every key in 'right' must be greater than pivot.Key. Join panics
+// if the key ranges overlap. Join is O(log(N)) and reuses the nodes of both n and 'right'.
+func (n *Tree[K, V]) Join(pivot Pair[K, V], right *Tree[K, V]) *Tree[K, V] {
+	if !n.IsEmpty() && !(n.rightMost().key < pivot.Key) {
+		panic("join: keys in the left tree must be less than the pivot")
+	}
+	if !right.IsEmpty() && !(pivot.Key < right.leftMost().key) {
+		panic("join: keys in the right tree must be greater than the pivot")
+	}
+	return n.join(pivot.Key, pivot.Value, right)
+}
+
+// Join2 returns the root of a new tree containing every entry of n followed by every entry of 'right'. Every key in
+// n must be less than every key in 'right'. Join2 panics if the key ranges overlap. Join2 is O(log(N)) and reuses the
+// nodes of both n and 'right'.
+func (n *Tree[K, V]) Join2(right *Tree[K, V]) *Tree[K, V] {
+	if !n.IsEmpty() && !right.IsEmpty() && !(n.rightMost().key < right.leftMost().key) {
+		panic("join: keys in the left tree must be less than keys in the right tree")
+	}
+	return n.join2(right)
+}
+
+// join concatenates n, the entry (key, value) and right without checking that the key ranges are ordered. The taller
+// tree is descended along its inner spine until the heights are within one of each other, so only
+// O(|n.Height() - right.Height()|) nodes are replaced.
+func (n *Tree[K, V]) join(key K, value V, right *Tree[K, V]) *Tree[K, V] {
+	lh, rh := n.Height(), right.Height()
+
+	if lh > rh+1 {
+		return newNode(n.left, n.right.join(key, value, right), n.key, n.value).rebalance()
+	}
+
+	if rh > lh+1 {
+		return newNode(n.join(key, value, right.left), right.right, right.key, right.value).rebalance()
+	}
+
+	return newNode(n, right, key, value)
+}
+
+// join2 concatenates n and right without checking that the key ranges are ordered.
+func (n *Tree[K, V]) join2(right *Tree[K, V]) *Tree[K, V] {
+	if n.IsEmpty() {
+		return right
+	}
+	if right.IsEmpty() {
+		return n
+	}
+	rest, last := n.splitMost()
+	return rest.join(last.Key, last.Value, right)
+}
+
+// splitMost returns n with its greatest entry removed, along with that entry. n must not be empty.
+func (n *Tree[K, V]) splitMost() (*Tree[K, V], Pair[K, V]) {
+	if n.right.IsEmpty() {
+		return n.left, n.pair()
+	}
+	rest, last := n.right.splitMost()
+	return n.left.join(n.key, n.value, rest), last
+}
+
+// splitLeast returns n with its least entry removed, along with that entry. n must not be empty.
+func (n *Tree[K, V]) splitLeast() (*Tree[K, V], Pair[K, V]) {
+	if n.left.IsEmpty() {
+		return n.right, n.pair()
+	}
+	rest, first := n.left.splitLeast()
+	return rest.join(n.key, n.value, n.right), first
+}
+
+// Union returns the root of a new tree containing every key found in either n or 'other'. For keys found in both
+// trees the new value is merge(key, a, b), where a is the value from n and b is the value from 'other'. If merge is nil
+// the value from 'other' is used. Subtrees shared by both inputs are reused as-is, without calling merge.
+//
+// Union uses split and join rather than repeated updates, so it is O(m*log(n/m + 1)) where m is the size of the
+// smaller input and n the size of the larger one.
+func (n *Tree[K, V]) Union(other *Tree[K, V], merge func(key K, a, b V) V) *Tree[K, V] {
+	return n.union(other, merge, nil)
+}
+
+func (n *Tree[K, V]) union(other *Tree[K, V], merge func(key K, a, b V) V, s *scheduler) *Tree[K, V] {
+	if n == other || other.IsEmpty() {
+		return n
+	}
+	if n.IsEmpty() {
+		return other
+	}
+
+	less, p, found, greater := other.Split(n.key)
+	var left, right *Tree[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.union(less, merge, s)
+		}, func() {
+			right = n.right.union(greater, merge, s)
+		})
+	} else {
+		left = n.left.union(less, merge, s)
+		right = n.right.union(greater, merge, s)
+	}
+
+	value := n.value
+	if found {
+		if merge != nil {
+			value = merge(n.key, n.value, p.Value)
+		} else {
+			value = p.Value
+		}
+	} else if left == n.left && right == n.right {
+		return n
+	}
+
+	return left.join(n.key, value, right)
+}
+
+// Intersection returns the root of a new tree containing only the keys found in both n and 'other'. The new value for
+// each key is combine(key, a, b), where a is the value from n and b is the value from 'other'. If combine is nil the
+// value from n is used. Subtrees shared by both inputs are reused as-is, without calling combine.
+//
+// Like Union, Intersection is O(m*log(n/m + 1)).
+func (n *Tree[K, V]) Intersection(other *Tree[K, V], combine func(key K, a, b V) V) *Tree[K, V] {
+	return n.intersection(other, combine, nil)
+}
+
+func (n *Tree[K, V]) intersection(other *Tree[K, V], combine func(key K, a, b V) V, s *scheduler) *Tree[K, V] {
+	if n == other {
+		return n
+	}
+	if n.IsEmpty() || other.IsEmpty() {
+		return nil
+	}
+
+	less, p, found, greater := other.Split(n.key)
+	var left, right *Tree[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.intersection(less, combine, s)
+		}, func() {
+			right = n.right.intersection(greater, combine, s)
+		})
+	} else {
+		left = n.left.intersection(less, combine, s)
+		right = n.right.intersection(greater, combine, s)
+	}
+
+	if !found {
+		return left.join2(right)
+	}
+
+	value := n.value
+	if combine != nil {
+		value = combine(n.key, n.value, p.Value)
+	} else if left == n.left && right == n.right {
+		return n
+	}
+
+	return left.join(n.key, value, right)
+}
+
+// Difference returns the root of a new tree containing the entries of n whose keys are not found in 'other'.
+//
+// Like Union, Difference is O(m*log(n/m + 1)).
+func (n *Tree[K, V]) Difference(other *Tree[K, V]) *Tree[K, V] {
+	return n.difference(other, nil)
+}
+
+func (n *Tree[K, V]) difference(other *Tree[K, V], s *scheduler) *Tree[K, V] {
+	if n == other || n.IsEmpty() {
+		return nil
+	}
+	if other.IsEmpty() {
+		return n
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	var left, right *Tree[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.difference(less, s)
+		}, func() {
+			right = n.right.difference(greater, s)
+		})
+	} else {
+		left = n.left.difference(less, s)
+		right = n.right.difference(greater, s)
+	}
+
+	if found {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// symmetricDifference returns the root of a new tree containing the entries of n and 'other' whose keys are found in
+// exactly one of the two trees.
+func (n *Tree[K, V]) symmetricDifference(other *Tree[K, V]) *Tree[K, V] {
+	if n == other {
+		return nil
+	}
+	if n.IsEmpty() {
+		return other
+	}
+	if other.IsEmpty() {
+		return n
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	left := n.left.symmetricDifference(less)
+	right := n.right.symmetricDifference(greater)
+
+	if found {
+		return left.join2(right)
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// isSubsetOf returns true iif every key in n is also found in 'other'.
+func (n *Tree[K, V]) isSubsetOf(other *Tree[K, V]) bool {
+	if n == other || n.IsEmpty() {
+		return true
+	}
+	if n.Size() > other.Size() {
+		return false
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	return found && n.left.isSubsetOf(less) && n.right.isSubsetOf(greater)
+}
+
+// isDisjoint returns true iif n and 'other' have no keys in common.
+func (n *Tree[K, V]) isDisjoint(other *Tree[K, V]) bool {
+	if n.IsEmpty() || other.IsEmpty() {
+		return true
+	}
+	if n == other {
+		return false
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	return !found && n.left.isDisjoint(less) && n.right.isDisjoint(greater)
+}
+
+// LeftOuterJoin returns a tree with an entry for every key in 'left'. Each entry holds the value from 'left' and, if
+// the key is also found in 'right', the value from 'right'.
+func LeftOuterJoin[K constraints.Ordered, A any, B any](left *Tree[K, A], right *Tree[K, B]) *Tree[K, Joined[A, B]] {
+	if left.IsEmpty() {
+		return nil
+	}
+	if right.IsEmpty() {
+		return MapValues(left, func(_ K, a A) Joined[A, B] {
+			return Joined[A, B]{Left: Some(a)}
+		})
+	}
+
+	less, p, found, greater := right.Split(left.key)
+	value := Joined[A, B]{Left: Some(left.value)}
+	if found {
+		value.Right = Some(p.Value)
+	}
+
+	return LeftOuterJoin(left.left, less).join(left.key, value, LeftOuterJoin(left.right, greater))
+}
+
+// FullOuterJoin returns a tree with an entry for every key found in either 'left' or 'right'. Each entry holds the
+// values from whichever of the inputs contain the key.
+func FullOuterJoin[K constraints.Ordered, A any, B any](left *Tree[K, A], right *Tree[K, B]) *Tree[K, Joined[A, B]] {
+	if left.IsEmpty() {
+		return MapValues(right, func(_ K, b B) Joined[A, B] {
+			return Joined[A, B]{Right: Some(b)}
+		})
+	}
+	if right.IsEmpty() {
+		return MapValues(left, func(_ K, a A) Joined[A, B] {
+			return Joined[A, B]{Left: Some(a)}
+		})
+	}
+
+	less, p, found, greater := right.Split(left.key)
+	value := Joined[A, B]{Left: Some(left.value)}
+	if found {
+		value.Right = Some(p.Value)
+	}
+
+	return FullOuterJoin(left.left, less).join(left.key, value, FullOuterJoin(left.right, greater))
+}
+
+// MapValues returns a tree with the same shape as n, with each value replaced by f(key, value). Entries are visited
+// in order. MapValues is O(n): the new tree shares no nodes with n, but it needs no comparisons or rebalancing.
+func MapValues[K constraints.Ordered, V any, W any](n *Tree[K, V], f func(key K, value V) W) *Tree[K, W] {
+	if n.IsEmpty() {
+		return nil
+	}
+
+	left := MapValues(n.left, f)
+	value := f(n.key, n.value)
+	right := MapValues(n.right, f)
+
+	return &Tree[K, W]{
+		left:   left,
+		right:  right,
+		key:    n.key,
+		value:  value,
+		size:   n.size,
+		height: n.height,
+	}
+}
+
+// Filter returns the root of a new tree containing the entries of n for which pred(key, value) returns true. Entries
+// are visited in order. Filter rebuilds the tree with joins, so it is O(N), and it reuses every subtree whose entries
+// are all kept.
+func (n *Tree[K, V]) Filter(pred func(key K, value V) bool) *Tree[K, V] {
+	if n.IsEmpty() {
+		return nil
+	}
+
+	left := n.left.Filter(pred)
+	keep := pred(n.key, n.value)
+	right := n.right.Filter(pred)
+
+	if !keep {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// Partition splits n into a tree containing the entries for which pred(key, value) returns true and a tree containing
+// the remaining entries, in a single O(N) pass. Entries are visited in order.
+func (n *Tree[K, V]) Partition(pred func(key K, value V) bool) (matching *Tree[K, V], rest *Tree[K, V]) {
+	if n.IsEmpty() {
+		return nil, nil
+	}
+
+	leftIn, leftOut := n.left.Partition(pred)
+	keep := pred(n.key, n.value)
+	rightIn, rightOut := n.right.Partition(pred)
+
+	if !keep {
+		if leftOut == n.left && rightOut == n.right {
+			return leftIn.join2(rightIn), n
+		}
+		return leftIn.join2(rightIn), leftOut.join(n.key, n.value, rightOut)
+	}
+	if leftIn == n.left && rightIn == n.right {
+		return n, leftOut.join2(rightOut)
+	}
+	return leftIn.join(n.key, n.value, rightIn), leftOut.join2(rightOut)
+}
+
 //LeastUpperBound returns the key-value-pair for the smallest node n such that n.Key() >= key. If there is no such
 //node then boolean is false.
 func (n *Tree[K, V]) LeastUpperBound(key K) (Pair[K, V], bool) {
@@ -358,38 +942,77 @@ func (n *Tree[K, V]) GreatestLowerBound(key K) (Pair[K, V], bool) {
 
 //Iter returns an in-order iterator for the tree.
 func (n *Tree[K, V]) Iter() Iterator[Pair[K, V]] {
-	ret := TreeIterator[K, V]{
-		stack:   nil,
-		current: n,
-	}
-
-	for !ret.current.IsEmpty() {
-		ret.stack = append(ret.stack, ret.current)
-		ret.current = ret.current.left
-	}
-	return &ret
+	ret := &TreeIterator[K, V]{}
+	ret.pushLeft(n)
+	return ret
 }
 
 //IterGte returns an in-order iterator for the tree for all nodes n such that n.Key() >= glb.
 func (n *Tree[K, V]) IterGte(glb K) Iterator[Pair[K, V]] {
-	ret := TreeIterator[K, V]{
-		stack:   nil,
-		current: n,
+	ret := &TreeIterator[K, V]{}
+	ret.seekAscending(n, glb, false)
+	return ret
+}
+
+// IterRange returns an in-order iterator for all nodes n such that lo <= n.Key() <= hi. Use 'bounds' to exclude
+// either endpoint from the range.
+func (n *Tree[K, V]) IterRange(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{
+		bounded:   true,
+		limit:     hi,
+		inclusive: !bounds.excludesHigh(),
 	}
+	ret.seekAscending(n, lo, bounds.excludesLow())
+	return ret
+}
 
-	for !ret.current.IsEmpty() {
-		if ret.current.key < glb {
-			ret.current = ret.current.right
-		} else if glb < ret.current.key {
-			ret.stack = append(ret.stack, ret.current)
-			ret.current = ret.current.left
-		} else {
-			ret.stack = append(ret.stack, ret.current)
-			ret.current = nil
-		}
+// IterLt returns an in-order iterator for all nodes n such that n.Key() < lub.
+func (n *Tree[K, V]) IterLt(lub K) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{
+		bounded: true,
+		limit:   lub,
 	}
+	ret.pushLeft(n)
+	return ret
+}
 
-	return &ret
+// IterLte returns an in-order iterator for all nodes n such that n.Key() <= lub.
+func (n *Tree[K, V]) IterLte(lub K) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{
+		bounded:   true,
+		limit:     lub,
+		inclusive: true,
+	}
+	ret.pushLeft(n)
+	return ret
+}
+
+// IterDesc returns an iterator that visits every node in the tree from the greatest key to the least.
+func (n *Tree[K, V]) IterDesc() Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{descending: true}
+	ret.pushRight(n)
+	return ret
+}
+
+// IterDescFrom returns an iterator that visits all nodes n such that n.Key() <= key, from the greatest key to the
+// least.
+func (n *Tree[K, V]) IterDescFrom(key K) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{descending: true}
+	ret.seekDescending(n, key, false)
+	return ret
+}
+
+// IterRangeDesc returns an iterator for all nodes n such that lo <= n.Key() <= hi, from the greatest key to the least.
+// Use 'bounds' to exclude either endpoint from the range.
+func (n *Tree[K, V]) IterRangeDesc(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	ret := &TreeIterator[K, V]{
+		descending: true,
+		bounded:    true,
+		limit:      lo,
+		inclusive:  !bounds.excludesLow(),
+	}
+	ret.seekDescending(n, hi, bounds.excludesHigh())
+	return ret
 }
 
 //Least returns the key-value-pair for the lowest element in the tree. If the tree is empty then boolean
@@ -420,7 +1043,61 @@ func (n *Tree[K, V]) Most() (Pair[K, V], bool) {
 		ret = ret.right
 	}
 
-	return ret.pair(), false
+	return ret.pair(), true
+}
+
+// Successor returns the entry with the least key strictly greater than 'key'. If there is no such entry, ok is false.
+func (n *Tree[K, V]) Successor(key K) (p Pair[K, V], ok bool) {
+	var ret *Tree[K, V]
+	for !n.IsEmpty() {
+		if key < n.key {
+			ret = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+// Predecessor returns the entry with the greatest key strictly less than 'key'. If there is no such entry, ok is false.
+func (n *Tree[K, V]) Predecessor(key K) (p Pair[K, V], ok bool) {
+	var ret *Tree[K, V]
+	for !n.IsEmpty() {
+		if n.key < key {
+			ret = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+// PopLeast removes the entry with the least key from the tree in a single descent. It returns the removed entry along
+// with the root of the new tree. If the tree is empty, ok is false. PopLeast is O(log(N)).
+func (n *Tree[K, V]) PopLeast() (p Pair[K, V], rest *Tree[K, V], ok bool) {
+	if n.IsEmpty() {
+		return p, nil, false
+	}
+	rest, p = n.splitLeast()
+	return p, rest, true
+}
+
+// PopMost removes the entry with the greatest key from the tree in a single descent. It returns the removed entry
+// along with the root of the new tree. If the tree is empty, ok is false. PopMost is O(log(N)).
+func (n *Tree[K, V]) PopMost() (p Pair[K, V], rest *Tree[K, V], ok bool) {
+	if n.IsEmpty() {
+		return p, nil, false
+	}
+	rest, p = n.splitMost()
+	return p, rest, true
 }
 
 func (n *Tree[K, V]) MarshalJSON() ([]byte, error) {
@@ -438,32 +1115,121 @@ func (n *Tree[K, V]) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	tree := &Tree[K, V]{}
+	pairs := make([]Pair[K, V], 0, len(m))
 	for k, v := range m {
-		tree = tree.Update(k, v)
+		pairs = append(pairs, Pair[K, V]{Key: k, Value: v})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+
+	*n = Tree[K, V]{}
+	if tree := buildTree(pairs); tree != nil {
+		*n = *tree
 	}
-	*n = *tree
 	return nil
 }
 
 func (i *TreeIterator[K, V]) Next() bool {
 	if !i.current.IsEmpty() {
-		i.current = i.current.right
-		for !i.current.IsEmpty() {
-			i.stack = append(i.stack, i.current)
-			i.current = i.current.left
+		if i.descending {
+			i.pushRight(i.current.left)
+		} else {
+			i.pushLeft(i.current.right)
 		}
+		i.current = nil
 	}
 
 	if len(i.stack) != 0 {
 		i.current = i.stack[len(i.stack)-1]
 		i.stack = i.stack[:len(i.stack)-1]
+		if i.bounded && i.pastLimit(i.current.key) {
+			i.stack = nil
+			i.current = nil
+			return false
+		}
 		return true
 	}
 
 	return false
 }
 
+func (i *TreeIterator[K, V]) pastLimit(key K) bool {
+	if i.descending {
+		return key < i.limit || (!i.inclusive && !(i.limit < key))
+	}
+	return i.limit < key || (!i.inclusive && !(key < i.limit))
+}
+
+// pushLeft pushes n and the left spine below it onto the stack.
+func (i *TreeIterator[K, V]) pushLeft(n *Tree[K, V]) {
+	for !n.IsEmpty() {
+		i.stack = append(i.stack, n)
+		n = n.left
+	}
+}
+
+// pushRight pushes n and the right spine below it onto the stack.
+func (i *TreeIterator[K, V]) pushRight(n *Tree[K, V]) {
+	for !n.IsEmpty() {
+		i.stack = append(i.stack, n)
+		n = n.right
+	}
+}
+
+// seekAscending positions an ascending iterator so that the first call to Next visits the least key k in n such that
+// k >= key, or k > key if strict is true.
+func (i *TreeIterator[K, V]) seekAscending(n *Tree[K, V], key K, strict bool) {
+	for !n.IsEmpty() {
+		if n.key < key {
+			n = n.right
+		} else if key < n.key {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if strict {
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekDescending positions a descending iterator so that the first call to Next visits the greatest key k in n such
+// that k <= key, or k < key if strict is true.
+func (i *TreeIterator[K, V]) seekDescending(n *Tree[K, V], key K, strict bool) {
+	for !n.IsEmpty() {
+		if key < n.key {
+			n = n.left
+		} else if n.key < key {
+			i.stack = append(i.stack, n)
+			n = n.right
+		} else if strict {
+			n = n.left
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekIndex positions an ascending iterator so that the first call to Next visits the k'th smallest key in n.
+func (i *TreeIterator[K, V]) seekIndex(n *Tree[K, V], k int) {
+	for !n.IsEmpty() {
+		leftSize := n.left.Size()
+		if k < leftSize {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if k > leftSize {
+			k -= leftSize + 1
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
 func (i *TreeIterator[K, V]) Current() Pair[K, V] {
 	if i.current.IsEmpty() {
 		panic("invalid iterator position")
@@ -475,6 +1241,55 @@ func EmptyTree[K constraints.Ordered, V any]() *Tree[K, V] {
 	return nil
 }
 
+// TreeFromSorted returns a tree containing 'pairs', which must be sorted by key in strictly increasing order. If
+// they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey.
+//
+// TreeFromSorted builds a perfectly balanced tree in O(n) time, allocating exactly one node per pair. Prefer it over
+// repeated calls to Update when loading large data sets.
+func TreeFromSorted[K constraints.Ordered, V any](pairs []Pair[K, V]) (*Tree[K, V], error) {
+	err := checkSorted(len(pairs), func(i int) K {
+		return pairs[i].Key
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildTree(pairs), nil
+}
+
+// TreeFromSortedIterator returns a tree containing the pairs produced by 'iter', which must be sorted by key in
+// strictly increasing order. If they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey.
+// TreeFromSortedIterator is O(n).
+func TreeFromSortedIterator[K constraints.Ordered, V any](iter Iterator[Pair[K, V]]) (*Tree[K, V], error) {
+	var pairs []Pair[K, V]
+	for iter.Next() {
+		pairs = append(pairs, iter.Current())
+	}
+	return TreeFromSorted(pairs)
+}
+
+// checkSorted verifies that the n keys returned by key(0) ... key(n-1) are in strictly increasing order.
+func checkSorted[K constraints.Ordered](n int, key func(i int) K) error {
+	for i := 1; i < n; i++ {
+		prev, cur := key(i-1), key(i)
+		if cur < prev {
+			return fmt.Errorf("%w: the key at index %v is less than the key before it", ErrUnsorted, i)
+		}
+		if !(prev < cur) {
+			return fmt.Errorf("%w at index %v", ErrDuplicateKey, i)
+		}
+	}
+	return nil
+}
+
+// buildTree returns a perfectly balanced tree containing 'pairs', which must already be sorted and unique.
+func buildTree[K constraints.Ordered, V any](pairs []Pair[K, V]) *Tree[K, V] {
+	if len(pairs) == 0 {
+		return nil
+	}
+	mid := len(pairs) / 2
+	return newNode(buildTree(pairs[:mid]), buildTree(pairs[mid+1:]), pairs[mid].Key, pairs[mid].Value)
+}
+
 func (n *Tree[K, V]) pair() Pair[K, V] {
 	return Pair[K, V]{Key: n.Key(), Value: n.Value()}
 }
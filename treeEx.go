@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 )
 
 // TreeEx implements a persistent AVL tree for keys implementing the Ordered[K] interface. For built-in
@@ -24,12 +25,24 @@ type TreeEx[K Ordered[K], V any] struct {
 	value  V
 	size   int
 	height int
+
+	// owner is the builder allowed to modify this node in place, if any. See TreeExBuilder.
+	owner *owner
 }
 
 // TreeExIterator defines an iterator over a TreeEx
 type TreeExIterator[K Ordered[K], V any] struct {
 	stack   []*TreeEx[K, V]
 	current *TreeEx[K, V]
+
+	// descending is true for iterators that visit keys from greatest to least.
+	descending bool
+
+	// bounded is true for iterators that stop at 'limit'. The limit is an upper bound for ascending iterators and a
+	// lower bound for descending ones. It is visited only if 'inclusive' is true.
+	bounded   bool
+	limit     K
+	inclusive bool
 }
 
 // GetKthElement returns the k'th smallest element in a Tree.
@@ -53,6 +66,135 @@ func (n *TreeEx[K, V]) GetKthElement(k int) (p Pair[K, V], ok bool) {
 	return n.Left().GetKthElement(k)
 }
 
+// Rank returns the number of keys in the tree that are less than 'key'. It is the inverse of GetKthElement: if the
+// tree contains 'key', then n.GetKthElement(n.Rank(key)) returns its entry. Rank is O(log(N)).
+func (n *TreeEx[K, V]) Rank(key K) int {
+	rank := 0
+	for !n.IsEmpty() {
+		if n.key.Less(key) {
+			rank += n.left.Size() + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return rank
+}
+
+// rankLte returns the number of keys in the tree that are less than or equal to 'key'.
+func (n *TreeEx[K, V]) rankLte(key K) int {
+	rank := 0
+	for !n.IsEmpty() {
+		if key.Less(n.key) {
+			n = n.left
+		} else {
+			rank += n.left.Size() + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// CountRange returns the number of keys k in the tree such that lo <= k <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(N)).
+func (n *TreeEx[K, V]) CountRange(lo K, hi K, bounds Bounds) int {
+	var lower, upper int
+	if bounds.excludesLow() {
+		lower = n.rankLte(lo)
+	} else {
+		lower = n.Rank(lo)
+	}
+	if bounds.excludesHigh() {
+		upper = n.Rank(hi)
+	} else {
+		upper = n.rankLte(hi)
+	}
+
+	if upper < lower {
+		return 0
+	}
+	return upper - lower
+}
+
+// Median returns the median element of the tree. If the tree has an even number of elements, the lower of the two
+// middle elements is returned. If the tree is empty, ok will be false.
+func (n *TreeEx[K, V]) Median() (p Pair[K, V], ok bool) {
+	return n.GetKthElement((n.Size() - 1) / 2)
+}
+
+// Percentile returns the element at the p'th percentile of the tree, using the nearest-rank method: the result is the
+// smallest element such that at least p percent of the elements are less than or equal to it. p must be between 0 and
+// 100. If the tree is empty or p is out of range, ok will be false.
+func (n *TreeEx[K, V]) Percentile(p float64) (e Pair[K, V], ok bool) {
+	if n.IsEmpty() || !(p >= 0 && p <= 100) {
+		return e, false
+	}
+	return n.GetKthElement(percentileIndex(p, n.Size()))
+}
+
+// DeleteAt returns the root of a new tree with the i'th smallest entry removed. If i is out of range, n is returned
+// unchanged. DeleteAt is O(log(N)).
+func (n *TreeEx[K, V]) DeleteAt(i int) *TreeEx[K, V] {
+	if i < 0 || i >= n.Size() {
+		return n
+	}
+	return n.deleteAt(i)
+}
+
+func (n *TreeEx[K, V]) deleteAt(i int) *TreeEx[K, V] {
+	leftSize := n.left.Size()
+
+	if i < leftSize {
+		return newExNode(n.left.deleteAt(i), n.right, n.key, n.value).rebalance()
+	}
+
+	if i > leftSize {
+		return newExNode(n.left, n.right.deleteAt(i-leftSize-1), n.key, n.value).rebalance()
+	}
+
+	return n.deleteCurrent().rebalance()
+}
+
+// SplitAt partitions the tree by position. It returns a tree containing the i smallest entries and a tree containing
+// the remaining ones. Values of i outside of [0, n.Size()] are clamped to that range. SplitAt is O(log(N)), and the
+// returned trees share all untouched subtrees with n.
+func (n *TreeEx[K, V]) SplitAt(i int) (left *TreeEx[K, V], right *TreeEx[K, V]) {
+	if i <= 0 {
+		return nil, n
+	}
+	if i >= n.Size() {
+		return n, nil
+	}
+
+	leftSize := n.left.Size()
+	if i <= leftSize {
+		left, right = n.left.SplitAt(i)
+		return left, right.join(n.key, n.value, n.right)
+	}
+
+	left, right = n.right.SplitAt(i - leftSize - 1)
+	return n.left.join(n.key, n.value, left), right
+}
+
+// SliceByIndex returns the root of a new tree containing the entries whose positions lie in [i, j). Indexes outside of
+// [0, n.Size()] are clamped to that range. SliceByIndex is O(log(N)).
+func (n *TreeEx[K, V]) SliceByIndex(i int, j int) *TreeEx[K, V] {
+	if i >= j {
+		return nil
+	}
+	prefix, _ := n.SplitAt(j)
+	_, ret := prefix.SplitAt(i)
+	return ret
+}
+
+// IterFromIndex returns an in-order iterator that starts at the i'th smallest entry of the tree. Positioning the
+// iterator is O(log(N)). If i is negative, iteration starts at the least entry.
+func (n *TreeEx[K, V]) IterFromIndex(i int) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{}
+	ret.seekIndex(n, i)
+	return ret
+}
+
 // Key returns the key associated with the node n. If n is empty, the zero value for K is returned.
 func (n *TreeEx[K, V]) Key() K {
 	if n.IsEmpty() {
@@ -91,20 +233,43 @@ func (n *TreeEx[K, V]) Find(key K) V {
 // FindOpt returns the pair associated with key in the tree. Return true if found; otherwise false.
 // Zero value is returned when found is false.
 func (n *TreeEx[K, V]) FindOpt(key K) (V, bool) {
-	if n.IsEmpty() {
-		var ret V
-		return ret, false
-	}
-
-	if n.key.Less(key) {
-		return n.right.FindOpt(key)
-	}
-
-	if key.Less(n.key) {
-		return n.left.FindOpt(key)
+	c := compareTo(key)
+	if c.mode == compareNode {
+		// Compare is called on the node's key, so the signs are the reverse of c.compare.
+		for !n.IsEmpty() {
+			cmp := any(&n.key).(Comparable[K]).Compare(key)
+			if cmp < 0 {
+				n = n.right
+			} else if cmp > 0 {
+				n = n.left
+			} else {
+				return n.value, true
+			}
+		}
+	} else if c.mode != compareLess {
+		for !n.IsEmpty() {
+			cmp := c.compare(&n.key)
+			if cmp > 0 {
+				n = n.right
+			} else if cmp < 0 {
+				n = n.left
+			} else {
+				return n.value, true
+			}
+		}
+	} else {
+		for !n.IsEmpty() {
+			if n.key.Less(key) {
+				n = n.right
+			} else if key.Less(n.key) {
+				n = n.left
+			} else {
+				return n.value, true
+			}
+		}
 	}
-
-	return n.value, true
+	var ret V
+	return ret, false
 }
 
 func newExNode[K Ordered[K], V any](left *TreeEx[K, V], right *TreeEx[K, V], key K, value V) *TreeEx[K, V] {
@@ -125,6 +290,72 @@ func max(i, j int) int {
 	return j
 }
 
+// keyComparer compares a search key against the keys of a tree. If K implements Comparable[K], each level of a search
+// needs a single call to Compare instead of up to two calls to Less.
+type keyComparer[K Ordered[K]] struct {
+	key  K
+	mode compareMode
+
+	// comparable holds the search key when mode is compareKey.
+	comparable Comparable[K]
+}
+
+type compareMode int
+
+const (
+	// compareLess compares keys with up to two calls to Less.
+	compareLess compareMode = iota
+
+	// compareKey calls Compare on the search key. It is used when K is an interface type, so converting the search
+	// key to Comparable[K] does not allocate.
+	compareKey
+
+	// compareNode calls Compare through a pointer to the key of each node, and negates the result. Converting a
+	// pointer to an interface does not allocate, whereas converting the search key would box it on every lookup.
+	compareNode
+)
+
+func compareTo[K Ordered[K]](key K) keyComparer[K] {
+	// Whether a key of a non-interface type implements Comparable[K] depends only on its type, and the method set
+	// of *K includes every method of K, so a nil *K can be checked without allocating. The zero value of an
+	// interface type converts to a nil interface; in that case the search key itself is checked.
+	if _, ok := any((*K)(nil)).(Comparable[K]); ok {
+		return keyComparer[K]{key: key, mode: compareNode}
+	}
+	var zero K
+	if any(zero) == nil {
+		if k, ok := any(key).(Comparable[K]); ok {
+			return keyComparer[K]{key: key, mode: compareKey, comparable: k}
+		}
+	}
+	return keyComparer[K]{key: key}
+}
+
+// compare returns a negative number, zero or a positive number if the search key is less than, equal to or greater
+// than *other.
+func (c *keyComparer[K]) compare(other *K) int {
+	switch c.mode {
+	case compareNode:
+		cmp := any(other).(Comparable[K]).Compare(c.key)
+		if cmp < 0 {
+			return 1
+		}
+		if cmp > 0 {
+			return -1
+		}
+		return 0
+	case compareKey:
+		return c.comparable.Compare(*other)
+	}
+	if c.key.Less(*other) {
+		return -1
+	}
+	if (*other).Less(c.key) {
+		return 1
+	}
+	return 0
+}
+
 func abs(i int) int {
 	if i < 0 {
 		return -i
@@ -134,19 +365,75 @@ func abs(i int) int {
 
 // Update returns the root of a new tree with the value for 'key' set to 'value'.
 func (n *TreeEx[K, V]) Update(key K, value V) *TreeEx[K, V] {
+	return n.update(compareTo(key), value)
+}
+
+func (n *TreeEx[K, V]) update(c keyComparer[K], value V) *TreeEx[K, V] {
 	if n.IsEmpty() {
-		return newExNode(nil, nil, key, value)
+		return newExNode(nil, nil, c.key, value)
 	}
 
-	if n.key.Less(key) {
-		return newExNode(n.left, n.right.Update(key, value), n.key, n.value).rebalance()
+	cmp := c.compare(&n.key)
+	if cmp > 0 {
+		return newExNode(n.left, n.right.update(c, value), n.key, n.value).rebalance()
 	}
 
-	if key.Less(n.key) {
-		return newExNode(n.left.Update(key, value), n.right, n.key, n.value).rebalance()
+	if cmp < 0 {
+		return newExNode(n.left.update(c, value), n.right, n.key, n.value).rebalance()
 	}
 
-	return newExNode(n.left, n.right, key, value)
+	return newExNode(n.left, n.right, c.key, value)
+}
+
+// Alter inserts, updates or deletes the entry for 'key' in a single descent. f receives the current value for 'key'
+// (found is false if there is no entry) and returns the new value, along with whether the entry should be kept. If
+// keep is false, the entry is removed.
+//
+// If nothing changes, Alter returns n itself, so callers can detect changes by comparing pointers. Nothing changes
+// when the key is absent and f does not keep it, or when eq is not nil and eq(old, new) reports the values are equal.
+func (n *TreeEx[K, V]) Alter(key K, f func(old V, found bool) (value V, keep bool), eq func(a, b V) bool) *TreeEx[K, V] {
+	return n.alter(compareTo(key), f, eq)
+}
+
+func (n *TreeEx[K, V]) alter(
+	c keyComparer[K],
+	f func(old V, found bool) (value V, keep bool),
+	eq func(a, b V) bool,
+) *TreeEx[K, V] {
+	if n.IsEmpty() {
+		var zv V
+		value, keep := f(zv, false)
+		if !keep {
+			return n
+		}
+		return newExNode(nil, nil, c.key, value)
+	}
+
+	cmp := c.compare(&n.key)
+	if cmp > 0 {
+		right := n.right.alter(c, f, eq)
+		if right == n.right {
+			return n
+		}
+		return newExNode(n.left, right, n.key, n.value).rebalance()
+	}
+
+	if cmp < 0 {
+		left := n.left.alter(c, f, eq)
+		if left == n.left {
+			return n
+		}
+		return newExNode(left, n.right, n.key, n.value).rebalance()
+	}
+
+	value, keep := f(n.value, true)
+	if !keep {
+		return n.deleteCurrent().rebalance()
+	}
+	if eq != nil && eq(n.value, value) {
+		return n
+	}
+	return newExNode(n.left, n.right, n.key, value)
 }
 
 // Height returns the height of the tree rooted at node n. Will return 0 if n is empty.
@@ -173,14 +460,14 @@ func (n *TreeEx[K, V]) rebalance() *TreeEx[K, V] {
 	if balance > 0 {
 		rightBalance := n.right.balanceFactor()
 
-		if rightBalance > 0 {
+		if rightBalance >= 0 {
 			return n.rotateLeft()
 		}
 
 		return n.rotateRightLeft()
 	} else {
 		leftBalance := n.left.balanceFactor()
-		if leftBalance < 0 {
+		if leftBalance <= 0 {
 			return n.rotateRight()
 		}
 
@@ -244,16 +531,21 @@ func (n *TreeEx[K, V]) Right() *TreeEx[K, V] {
 
 // Delete returns the root of a new tree with the entry for 'key' removed.
 func (n *TreeEx[K, V]) Delete(key K) *TreeEx[K, V] {
+	return n.delete(compareTo(key))
+}
+
+func (n *TreeEx[K, V]) delete(c keyComparer[K]) *TreeEx[K, V] {
 	if n.IsEmpty() {
 		return nil
 	}
 
-	if n.key.Less(key) {
-		r := n.right.Delete(key)
+	cmp := c.compare(&n.key)
+	if cmp > 0 {
+		r := n.right.delete(c)
 		if r != n.right {
 			return newExNode(
 				n.left,
-				n.right.Delete(key),
+				r,
 				n.key,
 				n.value,
 			).rebalance()
@@ -261,11 +553,11 @@ func (n *TreeEx[K, V]) Delete(key K) *TreeEx[K, V] {
 		return n
 	}
 
-	if key.Less(n.key) {
-		l := n.left.Delete(key)
+	if cmp < 0 {
+		l := n.left.delete(c)
 		if l != n.left {
 			return newExNode(
-				n.left.Delete(key),
+				l,
 				n.right,
 				n.key,
 				n.value,
@@ -318,85 +610,510 @@ func (n *TreeEx[K, V]) Size() int {
 	return n.size
 }
 
-//LeastUpperBound returns the key-value-pair for the smallest node n such that n.Key() >= key. If there is no such
-//node then false is returned.
-func (n *TreeEx[K, V]) LeastUpperBound(key K) (Pair[K, V], bool) {
+func (n *TreeEx[K, V]) leftMost() *TreeEx[K, V] {
 	if n.IsEmpty() {
-		return n.pair(), false
+		return nil
+	}
+	current := n
+
+	for !current.left.IsEmpty() {
+		current = current.left
+	}
+	return current
+}
+
+// Split partitions the tree around 'key'. It returns a tree containing every entry with a key less than 'key', the
+// entry for 'key' itself (found is false if there is no such entry), and a tree containing every entry with a key
+// greater than 'key'. Split is O(log(N)), and the returned trees share all untouched subtrees with n.
+func (n *TreeEx[K, V]) Split(key K) (less *TreeEx[K, V], p Pair[K, V], found bool, greater *TreeEx[K, V]) {
+	if n.IsEmpty() {
+		return nil, p, false, nil
 	}
 
 	if n.key.Less(key) {
-		return n.right.LeastUpperBound(key)
+		less, p, found, greater = n.right.Split(key)
+		return n.left.join(n.key, n.value, less), p, found, greater
 	}
 
 	if key.Less(n.key) {
-		ret, found := n.left.LeastUpperBound(key)
+		less, p, found, greater = n.left.Split(key)
+		return less, p, found, greater.join(n.key, n.value, n.right)
+	}
 
-		if !found {
-			return n.pair(), true
-		}
-		return ret, true
+	return n.left, n.pair(), true, n.right
+}
+
+// Join returns the root of a new tree containing every entry of n, the entry 'pivot', and every entry of 'right'.
+// Every key in n must be less than pivot.Key, and every key in 'right' must be greater than pivot.Key. Join panics
+// if the key ranges overlap. Join is O(log(N)) and reuses the nodes of both n and 'right'.
+func (n *TreeEx[K, V]) Join(pivot Pair[K, V], right *TreeEx[K, V]) *TreeEx[K, V] {
+	if !n.IsEmpty() && !n.rightMost().key.Less(pivot.Key) {
+		panic("join: keys in the left tree must be less than the pivot")
 	}
+	if !right.IsEmpty() && !pivot.Key.Less(right.leftMost().key) {
+		panic("join: keys in the right tree must be greater than the pivot")
+	}
+	return n.join(pivot.Key, pivot.Value, right)
+}
 
-	return n.pair(), true
+// Join2 returns the root of a new tree containing every entry of n followed by every entry of 'right'. Every key in
+// n must be less than every key in 'right'. Join2 panics if the key ranges overlap. Join2 is O(log(N)) and reuses the
+// nodes of both n and 'right'.
+func (n *TreeEx[K, V]) Join2(right *TreeEx[K, V]) *TreeEx[K, V] {
+	if !n.IsEmpty() && !right.IsEmpty() && !n.rightMost().key.Less(right.leftMost().key) {
+		panic("join: keys in the left tree must be less than keys in the right tree")
+	}
+	return n.join2(right)
 }
 
-//GreatestLowerBound returns the key-value-par for the largest node n such that n.Key() <= key. If there is no such
-//node then false is returned.
-func (n *TreeEx[K, V]) GreatestLowerBound(key K) (Pair[K, V], bool) {
+// join concatenates n, the entry (key, value) and right without checking that the key ranges are ordered. The taller
+// tree is descended along its inner spine until the heights are within one of each other, so only
+// O(|n.Height() - right.Height()|) nodes are replaced.
+func (n *TreeEx[K, V]) join(key K, value V, right *TreeEx[K, V]) *TreeEx[K, V] {
+	lh, rh := n.Height(), right.Height()
+
+	if lh > rh+1 {
+		return newExNode(n.left, n.right.join(key, value, right), n.key, n.value).rebalance()
+	}
+
+	if rh > lh+1 {
+		return newExNode(n.join(key, value, right.left), right.right, right.key, right.value).rebalance()
+	}
+
+	return newExNode(n, right, key, value)
+}
+
+// join2 concatenates n and right without checking that the key ranges are ordered.
+func (n *TreeEx[K, V]) join2(right *TreeEx[K, V]) *TreeEx[K, V] {
 	if n.IsEmpty() {
-		return n.pair(), false
+		return right
+	}
+	if right.IsEmpty() {
+		return n
 	}
+	rest, last := n.splitMost()
+	return rest.join(last.Key, last.Value, right)
+}
 
-	if n.key.Less(key) {
-		ret, found := n.right.GreatestLowerBound(key)
-		if !found {
-			return n.pair(), true
+// splitMost returns n with its greatest entry removed, along with that entry. n must not be empty.
+func (n *TreeEx[K, V]) splitMost() (*TreeEx[K, V], Pair[K, V]) {
+	if n.right.IsEmpty() {
+		return n.left, n.pair()
+	}
+	rest, last := n.right.splitMost()
+	return n.left.join(n.key, n.value, rest), last
+}
+
+// splitLeast returns n with its least entry removed, along with that entry. n must not be empty.
+func (n *TreeEx[K, V]) splitLeast() (*TreeEx[K, V], Pair[K, V]) {
+	if n.left.IsEmpty() {
+		return n.right, n.pair()
+	}
+	rest, first := n.left.splitLeast()
+	return rest.join(n.key, n.value, n.right), first
+}
+
+// Union returns the root of a new tree containing every key found in either n or 'other'. For keys found in both
+// trees the new value is merge(key, a, b), where a is the value from n and b is the value from 'other'. If merge is nil
+// the value from 'other' is used. Subtrees shared by both inputs are reused as-is, without calling merge.
+//
+// Union uses split and join rather than repeated updates, so it is O(m*log(n/m + 1)) where m is the size of the
+// smaller input and n the size of the larger one.
+func (n *TreeEx[K, V]) Union(other *TreeEx[K, V], merge func(key K, a, b V) V) *TreeEx[K, V] {
+	return n.union(other, merge, nil)
+}
+
+func (n *TreeEx[K, V]) union(other *TreeEx[K, V], merge func(key K, a, b V) V, s *scheduler) *TreeEx[K, V] {
+	if n == other || other.IsEmpty() {
+		return n
+	}
+	if n.IsEmpty() {
+		return other
+	}
+
+	less, p, found, greater := other.Split(n.key)
+	var left, right *TreeEx[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.union(less, merge, s)
+		}, func() {
+			right = n.right.union(greater, merge, s)
+		})
+	} else {
+		left = n.left.union(less, merge, s)
+		right = n.right.union(greater, merge, s)
+	}
+
+	value := n.value
+	if found {
+		if merge != nil {
+			value = merge(n.key, n.value, p.Value)
+		} else {
+			value = p.Value
 		}
-		return ret, true
+	} else if left == n.left && right == n.right {
+		return n
 	}
 
-	if key.Less(n.key) {
-		return n.left.GreatestLowerBound(key)
+	return left.join(n.key, value, right)
+}
+
+// Intersection returns the root of a new tree containing only the keys found in both n and 'other'. The new value for
+// each key is combine(key, a, b), where a is the value from n and b is the value from 'other'. If combine is nil the
+// value from n is used. Subtrees shared by both inputs are reused as-is, without calling combine.
+//
+// Like Union, Intersection is O(m*log(n/m + 1)).
+func (n *TreeEx[K, V]) Intersection(other *TreeEx[K, V], combine func(key K, a, b V) V) *TreeEx[K, V] {
+	return n.intersection(other, combine, nil)
+}
+
+func (n *TreeEx[K, V]) intersection(other *TreeEx[K, V], combine func(key K, a, b V) V, s *scheduler) *TreeEx[K, V] {
+	if n == other {
+		return n
+	}
+	if n.IsEmpty() || other.IsEmpty() {
+		return nil
+	}
+
+	less, p, found, greater := other.Split(n.key)
+	var left, right *TreeEx[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.intersection(less, combine, s)
+		}, func() {
+			right = n.right.intersection(greater, combine, s)
+		})
+	} else {
+		left = n.left.intersection(less, combine, s)
+		right = n.right.intersection(greater, combine, s)
+	}
+
+	if !found {
+		return left.join2(right)
 	}
 
-	return n.pair(), true
+	value := n.value
+	if combine != nil {
+		value = combine(n.key, n.value, p.Value)
+	} else if left == n.left && right == n.right {
+		return n
+	}
+
+	return left.join(n.key, value, right)
 }
 
-//Iter returns an in-order iterator for the tree.
-func (n *TreeEx[K, V]) Iter() Iterator[Pair[K, V]] {
-	ret := TreeExIterator[K, V]{
-		stack:   nil,
-		current: n,
+// Difference returns the root of a new tree containing the entries of n whose keys are not found in 'other'.
+//
+// Like Union, Difference is O(m*log(n/m + 1)).
+func (n *TreeEx[K, V]) Difference(other *TreeEx[K, V]) *TreeEx[K, V] {
+	return n.difference(other, nil)
+}
+
+func (n *TreeEx[K, V]) difference(other *TreeEx[K, V], s *scheduler) *TreeEx[K, V] {
+	if n == other || n.IsEmpty() {
+		return nil
+	}
+	if other.IsEmpty() {
+		return n
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	var left, right *TreeEx[K, V]
+	if s.shouldFork(n.size + other.Size()) {
+		s.fork(func() {
+			left = n.left.difference(less, s)
+		}, func() {
+			right = n.right.difference(greater, s)
+		})
+	} else {
+		left = n.left.difference(less, s)
+		right = n.right.difference(greater, s)
 	}
 
-	for !ret.current.IsEmpty() {
-		ret.stack = append(ret.stack, ret.current)
-		ret.current = ret.current.left
+	if found {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
 	}
-	return &ret
+	return left.join(n.key, n.value, right)
 }
 
-//IterGte returns an in-order iterator for the tree for all nodes n such that n.Key() >= glb.
-func (n *TreeEx[K, V]) IterGte(glb K) Iterator[Pair[K, V]] {
-	ret := TreeExIterator[K, V]{
-		stack:   nil,
-		current: n,
+// symmetricDifference returns the root of a new tree containing the entries of n and 'other' whose keys are found in
+// exactly one of the two trees.
+func (n *TreeEx[K, V]) symmetricDifference(other *TreeEx[K, V]) *TreeEx[K, V] {
+	if n == other {
+		return nil
+	}
+	if n.IsEmpty() {
+		return other
+	}
+	if other.IsEmpty() {
+		return n
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	left := n.left.symmetricDifference(less)
+	right := n.right.symmetricDifference(greater)
+
+	if found {
+		return left.join2(right)
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// isSubsetOf returns true iif every key in n is also found in 'other'.
+func (n *TreeEx[K, V]) isSubsetOf(other *TreeEx[K, V]) bool {
+	if n == other || n.IsEmpty() {
+		return true
+	}
+	if n.Size() > other.Size() {
+		return false
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	return found && n.left.isSubsetOf(less) && n.right.isSubsetOf(greater)
+}
+
+// isDisjoint returns true iif n and 'other' have no keys in common.
+func (n *TreeEx[K, V]) isDisjoint(other *TreeEx[K, V]) bool {
+	if n.IsEmpty() || other.IsEmpty() {
+		return true
+	}
+	if n == other {
+		return false
+	}
+
+	less, _, found, greater := other.Split(n.key)
+	return !found && n.left.isDisjoint(less) && n.right.isDisjoint(greater)
+}
+
+// LeftOuterJoinEx returns a tree with an entry for every key in 'left'. Each entry holds the value from 'left' and, if
+// the key is also found in 'right', the value from 'right'.
+func LeftOuterJoinEx[K Ordered[K], A any, B any](left *TreeEx[K, A], right *TreeEx[K, B]) *TreeEx[K, Joined[A, B]] {
+	if left.IsEmpty() {
+		return nil
+	}
+	if right.IsEmpty() {
+		return MapValuesEx(left, func(_ K, a A) Joined[A, B] {
+			return Joined[A, B]{Left: Some(a)}
+		})
+	}
+
+	less, p, found, greater := right.Split(left.key)
+	value := Joined[A, B]{Left: Some(left.value)}
+	if found {
+		value.Right = Some(p.Value)
+	}
+
+	return LeftOuterJoinEx(left.left, less).join(left.key, value, LeftOuterJoinEx(left.right, greater))
+}
+
+// FullOuterJoinEx returns a tree with an entry for every key found in either 'left' or 'right'. Each entry holds the
+// values from whichever of the inputs contain the key.
+func FullOuterJoinEx[K Ordered[K], A any, B any](left *TreeEx[K, A], right *TreeEx[K, B]) *TreeEx[K, Joined[A, B]] {
+	if left.IsEmpty() {
+		return MapValuesEx(right, func(_ K, b B) Joined[A, B] {
+			return Joined[A, B]{Right: Some(b)}
+		})
+	}
+	if right.IsEmpty() {
+		return MapValuesEx(left, func(_ K, a A) Joined[A, B] {
+			return Joined[A, B]{Left: Some(a)}
+		})
+	}
+
+	less, p, found, greater := right.Split(left.key)
+	value := Joined[A, B]{Left: Some(left.value)}
+	if found {
+		value.Right = Some(p.Value)
+	}
+
+	return FullOuterJoinEx(left.left, less).join(left.key, value, FullOuterJoinEx(left.right, greater))
+}
+
+// MapValuesEx returns a tree with the same shape as n, with each value replaced by f(key, value). Entries are visited
+// in order. MapValuesEx is O(n): the new tree shares no nodes with n, but it needs no comparisons or rebalancing.
+func MapValuesEx[K Ordered[K], V any, W any](n *TreeEx[K, V], f func(key K, value V) W) *TreeEx[K, W] {
+	if n.IsEmpty() {
+		return nil
+	}
+
+	left := MapValuesEx(n.left, f)
+	value := f(n.key, n.value)
+	right := MapValuesEx(n.right, f)
+
+	return &TreeEx[K, W]{
+		left:   left,
+		right:  right,
+		key:    n.key,
+		value:  value,
+		size:   n.size,
+		height: n.height,
+	}
+}
+
+// Filter returns the root of a new tree containing the entries of n for which pred(key, value) returns true. Entries
+// are visited in order. Filter rebuilds the tree with joins, so it is O(N), and it reuses every subtree whose entries
+// are all kept.
+func (n *TreeEx[K, V]) Filter(pred func(key K, value V) bool) *TreeEx[K, V] {
+	if n.IsEmpty() {
+		return nil
+	}
+
+	left := n.left.Filter(pred)
+	keep := pred(n.key, n.value)
+	right := n.right.Filter(pred)
+
+	if !keep {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// Partition splits n into a tree containing the entries for which pred(key, value) returns true and a tree containing
+// the remaining entries, in a single O(N) pass. Entries are visited in order.
+func (n *TreeEx[K, V]) Partition(pred func(key K, value V) bool) (matching *TreeEx[K, V], rest *TreeEx[K, V]) {
+	if n.IsEmpty() {
+		return nil, nil
 	}
 
-	for !ret.current.IsEmpty() {
-		if ret.current.Key().Less(glb) {
-			ret.current = ret.current.right
-		} else if glb.Less(ret.current.Key()) {
-			ret.stack = append(ret.stack, ret.current)
-			ret.current = ret.current.left
+	leftIn, leftOut := n.left.Partition(pred)
+	keep := pred(n.key, n.value)
+	rightIn, rightOut := n.right.Partition(pred)
+
+	if !keep {
+		if leftOut == n.left && rightOut == n.right {
+			return leftIn.join2(rightIn), n
+		}
+		return leftIn.join2(rightIn), leftOut.join(n.key, n.value, rightOut)
+	}
+	if leftIn == n.left && rightIn == n.right {
+		return n, leftOut.join2(rightOut)
+	}
+	return leftIn.join(n.key, n.value, rightIn), leftOut.join2(rightOut)
+}
+
+//LeastUpperBound returns the key-value-pair for the smallest node n such that n.Key() >= key. If there is no such
+//node then false is returned.
+func (n *TreeEx[K, V]) LeastUpperBound(key K) (Pair[K, V], bool) {
+	c := compareTo(key)
+	var ret *TreeEx[K, V]
+	for !n.IsEmpty() {
+		cmp := c.compare(&n.key)
+		if cmp > 0 {
+			n = n.right
+		} else if cmp < 0 {
+			ret = n
+			n = n.left
+		} else {
+			return n.pair(), true
+		}
+	}
+	if ret == nil {
+		return n.pair(), false
+	}
+	return ret.pair(), true
+}
+
+//GreatestLowerBound returns the key-value-par for the largest node n such that n.Key() <= key. If there is no such
+//node then false is returned.
+func (n *TreeEx[K, V]) GreatestLowerBound(key K) (Pair[K, V], bool) {
+	c := compareTo(key)
+	var ret *TreeEx[K, V]
+	for !n.IsEmpty() {
+		cmp := c.compare(&n.key)
+		if cmp < 0 {
+			n = n.left
+		} else if cmp > 0 {
+			ret = n
+			n = n.right
 		} else {
-			ret.stack = append(ret.stack, ret.current)
-			ret.current = nil
+			return n.pair(), true
 		}
 	}
+	if ret == nil {
+		return n.pair(), false
+	}
+	return ret.pair(), true
+}
+
+//Iter returns an in-order iterator for the tree.
+func (n *TreeEx[K, V]) Iter() Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{}
+	ret.pushLeft(n)
+	return ret
+}
+
+//IterGte returns an in-order iterator for the tree for all nodes n such that n.Key() >= glb.
+func (n *TreeEx[K, V]) IterGte(glb K) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{}
+	ret.seekAscending(n, glb, false)
+	return ret
+}
+
+// IterRange returns an in-order iterator for all nodes n such that lo <= n.Key() <= hi. Use 'bounds' to exclude
+// either endpoint from the range.
+func (n *TreeEx[K, V]) IterRange(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{
+		bounded:   true,
+		limit:     hi,
+		inclusive: !bounds.excludesHigh(),
+	}
+	ret.seekAscending(n, lo, bounds.excludesLow())
+	return ret
+}
+
+// IterLt returns an in-order iterator for all nodes n such that n.Key() < lub.
+func (n *TreeEx[K, V]) IterLt(lub K) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{
+		bounded: true,
+		limit:   lub,
+	}
+	ret.pushLeft(n)
+	return ret
+}
+
+// IterLte returns an in-order iterator for all nodes n such that n.Key() <= lub.
+func (n *TreeEx[K, V]) IterLte(lub K) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{
+		bounded:   true,
+		limit:     lub,
+		inclusive: true,
+	}
+	ret.pushLeft(n)
+	return ret
+}
+
+// IterDesc returns an iterator that visits every node in the tree from the greatest key to the least.
+func (n *TreeEx[K, V]) IterDesc() Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{descending: true}
+	ret.pushRight(n)
+	return ret
+}
 
-	return &ret
+// IterDescFrom returns an iterator that visits all nodes n such that n.Key() <= key, from the greatest key to the
+// least.
+func (n *TreeEx[K, V]) IterDescFrom(key K) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{descending: true}
+	ret.seekDescending(n, key, false)
+	return ret
+}
+
+// IterRangeDesc returns an iterator for all nodes n such that lo <= n.Key() <= hi, from the greatest key to the least.
+// Use 'bounds' to exclude either endpoint from the range.
+func (n *TreeEx[K, V]) IterRangeDesc(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	ret := &TreeExIterator[K, V]{
+		descending: true,
+		bounded:    true,
+		limit:      lo,
+		inclusive:  !bounds.excludesLow(),
+	}
+	ret.seekDescending(n, hi, bounds.excludesHigh())
+	return ret
 }
 
 //Least returns the key-value-pair for the lowest element in the tree. If the tree is empty, then return false
@@ -430,6 +1147,60 @@ func (n *TreeEx[K, V]) Most() (Pair[K, V], bool) {
 	return ret.pair(), true
 }
 
+// Successor returns the entry with the least key strictly greater than 'key'. If there is no such entry, ok is false.
+func (n *TreeEx[K, V]) Successor(key K) (p Pair[K, V], ok bool) {
+	var ret *TreeEx[K, V]
+	for !n.IsEmpty() {
+		if key.Less(n.key) {
+			ret = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+// Predecessor returns the entry with the greatest key strictly less than 'key'. If there is no such entry, ok is false.
+func (n *TreeEx[K, V]) Predecessor(key K) (p Pair[K, V], ok bool) {
+	var ret *TreeEx[K, V]
+	for !n.IsEmpty() {
+		if n.key.Less(key) {
+			ret = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+// PopLeast removes the entry with the least key from the tree in a single descent. It returns the removed entry along
+// with the root of the new tree. If the tree is empty, ok is false. PopLeast is O(log(N)).
+func (n *TreeEx[K, V]) PopLeast() (p Pair[K, V], rest *TreeEx[K, V], ok bool) {
+	if n.IsEmpty() {
+		return p, nil, false
+	}
+	rest, p = n.splitLeast()
+	return p, rest, true
+}
+
+// PopMost removes the entry with the greatest key from the tree in a single descent. It returns the removed entry
+// along with the root of the new tree. If the tree is empty, ok is false. PopMost is O(log(N)).
+func (n *TreeEx[K, V]) PopMost() (p Pair[K, V], rest *TreeEx[K, V], ok bool) {
+	if n.IsEmpty() {
+		return p, nil, false
+	}
+	rest, p = n.splitMost()
+	return p, rest, true
+}
+
 func (n *TreeEx[K, V]) MarshalJSON() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	_, err := buf.WriteString("{")
@@ -474,7 +1245,7 @@ func (n *TreeEx[K, V]) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	tree := &TreeEx[K, V]{}
+	pairs := make([]Pair[K, V], 0, len(m))
 	for strKey, v := range m {
 		jsonStr, err := json.Marshal(strKey)
 		if err != nil {
@@ -485,30 +1256,133 @@ func (n *TreeEx[K, V]) UnmarshalJSON(data []byte) error {
 		if err != nil {
 			return err
 		}
-		tree = tree.Update(key, v)
+		pairs = append(pairs, Pair[K, V]{Key: key, Value: v})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Less(pairs[j].Key)
+	})
+
+	// Distinct strings may decode to equal keys, so keep a single entry for each key.
+	unique := pairs[:0]
+	for _, p := range pairs {
+		if len(unique) != 0 && !unique[len(unique)-1].Key.Less(p.Key) {
+			unique[len(unique)-1] = p
+			continue
+		}
+		unique = append(unique, p)
+	}
+
+	*n = TreeEx[K, V]{}
+	if tree := buildTreeEx(unique); tree != nil {
+		*n = *tree
 	}
-	*n = *tree
 	return nil
 }
 
 func (i *TreeExIterator[K, V]) Next() bool {
 	if !i.current.IsEmpty() {
-		i.current = i.current.right
-		for !i.current.IsEmpty() {
-			i.stack = append(i.stack, i.current)
-			i.current = i.current.left
+		if i.descending {
+			i.pushRight(i.current.left)
+		} else {
+			i.pushLeft(i.current.right)
 		}
+		i.current = nil
 	}
 
 	if len(i.stack) != 0 {
 		i.current = i.stack[len(i.stack)-1]
 		i.stack = i.stack[:len(i.stack)-1]
+		if i.bounded && i.pastLimit(i.current.key) {
+			i.stack = nil
+			i.current = nil
+			return false
+		}
 		return true
 	}
 
 	return false
 }
 
+func (i *TreeExIterator[K, V]) pastLimit(key K) bool {
+	if i.descending {
+		return key.Less(i.limit) || (!i.inclusive && !i.limit.Less(key))
+	}
+	return i.limit.Less(key) || (!i.inclusive && !key.Less(i.limit))
+}
+
+// pushLeft pushes n and the left spine below it onto the stack.
+func (i *TreeExIterator[K, V]) pushLeft(n *TreeEx[K, V]) {
+	for !n.IsEmpty() {
+		i.stack = append(i.stack, n)
+		n = n.left
+	}
+}
+
+// pushRight pushes n and the right spine below it onto the stack.
+func (i *TreeExIterator[K, V]) pushRight(n *TreeEx[K, V]) {
+	for !n.IsEmpty() {
+		i.stack = append(i.stack, n)
+		n = n.right
+	}
+}
+
+// seekAscending positions an ascending iterator so that the first call to Next visits the least key k in n such that
+// k >= key, or k > key if strict is true.
+func (i *TreeExIterator[K, V]) seekAscending(n *TreeEx[K, V], key K, strict bool) {
+	c := compareTo(key)
+	for !n.IsEmpty() {
+		cmp := c.compare(&n.key)
+		if cmp > 0 {
+			n = n.right
+		} else if cmp < 0 {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if strict {
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekDescending positions a descending iterator so that the first call to Next visits the greatest key k in n such
+// that k <= key, or k < key if strict is true.
+func (i *TreeExIterator[K, V]) seekDescending(n *TreeEx[K, V], key K, strict bool) {
+	c := compareTo(key)
+	for !n.IsEmpty() {
+		cmp := c.compare(&n.key)
+		if cmp < 0 {
+			n = n.left
+		} else if cmp > 0 {
+			i.stack = append(i.stack, n)
+			n = n.right
+		} else if strict {
+			n = n.left
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekIndex positions an ascending iterator so that the first call to Next visits the k'th smallest key in n.
+func (i *TreeExIterator[K, V]) seekIndex(n *TreeEx[K, V], k int) {
+	for !n.IsEmpty() {
+		leftSize := n.left.Size()
+		if k < leftSize {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if k > leftSize {
+			k -= leftSize + 1
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
 func (i *TreeExIterator[K, V]) Current() Pair[K, V] {
 	if i.current.IsEmpty() {
 		panic("invalid iterator position")
@@ -520,6 +1394,55 @@ func EmptyTreeEx[K Ordered[K], V any]() *TreeEx[K, V] {
 	return nil
 }
 
+// TreeExFromSorted returns a tree containing 'pairs', which must be sorted by key in strictly increasing order. If
+// they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey.
+//
+// TreeExFromSorted builds a perfectly balanced tree in O(n) time, allocating exactly one node per pair. Prefer it over
+// repeated calls to Update when loading large data sets.
+func TreeExFromSorted[K Ordered[K], V any](pairs []Pair[K, V]) (*TreeEx[K, V], error) {
+	err := checkSortedEx(len(pairs), func(i int) K {
+		return pairs[i].Key
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildTreeEx(pairs), nil
+}
+
+// TreeExFromSortedIterator returns a tree containing the pairs produced by 'iter', which must be sorted by key in
+// strictly increasing order. If they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey.
+// TreeExFromSortedIterator is O(n).
+func TreeExFromSortedIterator[K Ordered[K], V any](iter Iterator[Pair[K, V]]) (*TreeEx[K, V], error) {
+	var pairs []Pair[K, V]
+	for iter.Next() {
+		pairs = append(pairs, iter.Current())
+	}
+	return TreeExFromSorted(pairs)
+}
+
+// checkSortedEx verifies that the n keys returned by key(0) ... key(n-1) are in strictly increasing order.
+func checkSortedEx[K Ordered[K]](n int, key func(i int) K) error {
+	for i := 1; i < n; i++ {
+		prev, cur := key(i-1), key(i)
+		if cur.Less(prev) {
+			return fmt.Errorf("%w: the key at index %v is less than the key before it", ErrUnsorted, i)
+		}
+		if !prev.Less(cur) {
+			return fmt.Errorf("%w at index %v", ErrDuplicateKey, i)
+		}
+	}
+	return nil
+}
+
+// buildTreeEx returns a perfectly balanced tree containing 'pairs', which must already be sorted and unique.
+func buildTreeEx[K Ordered[K], V any](pairs []Pair[K, V]) *TreeEx[K, V] {
+	if len(pairs) == 0 {
+		return nil
+	}
+	mid := len(pairs) / 2
+	return newExNode(buildTreeEx(pairs[:mid]), buildTreeEx(pairs[mid+1:]), pairs[mid].Key, pairs[mid].Value)
+}
+
 func (n *TreeEx[K, V]) pair() Pair[K, V] {
 	return Pair[K, V]{Key: n.Key(), Value: n.Value()}
 }
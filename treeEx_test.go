@@ -2,8 +2,10 @@ package persistent
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +29,40 @@ func (x String) Less(y String) bool {
 	return x < y
 }
 
+// CompareString is a key type that implements both Ordered and Comparable.
+type CompareString string
+
+func (x CompareString) Less(y CompareString) bool {
+	return x < y
+}
+
+func (x CompareString) Compare(y CompareString) int {
+	return strings.Compare(string(x), string(y))
+}
+
+// comparisons counts the comparisons made between countedKeys.
+type comparisons struct {
+	less    int
+	compare int
+}
+
+// countedKey is a key type that implements both Ordered and Comparable, and counts its comparisons in 'calls'. Keys
+// compared with each other should share the same counter.
+type countedKey struct {
+	value string
+	calls *comparisons
+}
+
+func (x countedKey) Less(y countedKey) bool {
+	x.calls.less++
+	return x.value < y.value
+}
+
+func (x countedKey) Compare(y countedKey) int {
+	x.calls.compare++
+	return strings.Compare(x.value, y.value)
+}
+
 func TestExNilEmpty(t *testing.T) {
 	var tree *TreeEx[Int, int]
 	require.True(t, tree.IsEmpty())
@@ -482,3 +518,448 @@ func TestExUnMarshalJsonStringKey(t *testing.T) {
 	require.Equal(t, 2, x.Find("Hello"))
 	require.Equal(t, 4, x.Find("World"))
 }
+
+func requireValidTreeEx[K Ordered[K], V any](t *testing.T, n *TreeEx[K, V]) {
+	t.Helper()
+	if n.IsEmpty() {
+		return
+	}
+	requireValidTreeEx(t, n.left)
+	requireValidTreeEx(t, n.right)
+	if !n.left.IsEmpty() {
+		require.True(t, n.left.rightMost().key.Less(n.key))
+	}
+	if !n.right.IsEmpty() {
+		require.True(t, n.key.Less(n.right.leftMost().key))
+	}
+	require.Equal(t, n.left.Size()+n.right.Size()+1, n.size)
+	require.Equal(t, max(n.left.Height(), n.right.Height())+1, n.height)
+	require.LessOrEqual(t, abs(n.balanceFactor()), 1)
+}
+
+func treeExOfRange(lo, hi, step int) *TreeEx[Int, int] {
+	var tree *TreeEx[Int, int]
+	for i := lo; i < hi; i += step {
+		tree = tree.Update(Int(i), i)
+	}
+	return tree
+}
+
+func treeExKeys[K Ordered[K], V any](n *TreeEx[K, V]) []K {
+	var ret []K
+	iter := n.Iter()
+	for iter.Next() {
+		ret = append(ret, iter.Current().Key)
+	}
+	return ret
+}
+
+func TestExSplit(t *testing.T) {
+	tree := treeExOfRange(0, 100, 2)
+	for key := Int(-1); key <= 100; key++ {
+		less, p, found, greater := tree.Split(key)
+		requireValidTreeEx(t, less)
+		requireValidTreeEx(t, greater)
+		require.Equal(t, key >= 0 && key < 100 && key%2 == 0, found)
+		if found {
+			require.Equal(t, key, p.Key)
+		}
+		for _, k := range treeExKeys(less) {
+			require.Less(t, k, key)
+		}
+		for _, k := range treeExKeys(greater) {
+			require.Greater(t, k, key)
+		}
+	}
+}
+
+func TestExJoin(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1, 50}, {50, 1}, {30, 30}} {
+		left := treeExOfRange(0, sizes[0], 1)
+		right := treeExOfRange(sizes[0]+1, sizes[0]+1+sizes[1], 1)
+		joined := left.Join(Pair[Int, int]{Key: Int(sizes[0]), Value: sizes[0]}, right)
+		requireValidTreeEx(t, joined)
+		for i, k := range treeExKeys(joined) {
+			require.Equal(t, Int(i), k)
+		}
+		require.Equal(t, sizes[0]+sizes[1]+1, joined.Size())
+
+		joined2 := left.Join2(right)
+		requireValidTreeEx(t, joined2)
+		require.Equal(t, sizes[0]+sizes[1], joined2.Size())
+	}
+}
+
+func TestExJoinOverlapPanics(t *testing.T) {
+	left := treeExOfRange(0, 10, 1)
+	right := treeExOfRange(5, 15, 1)
+	require.Panics(t, func() { left.Join(Pair[Int, int]{Key: 9}, nil) })
+	require.Panics(t, func() { left.Join2(right) })
+}
+
+func TestExDeleteRebalance(t *testing.T) {
+	tree := treeExOfRange(0, 256, 1)
+	for i := 0; i < 256; i++ {
+		tree = tree.Delete(Int((i * 37) % 256))
+		requireValidTreeEx(t, tree)
+	}
+}
+
+func TestExUnion(t *testing.T) {
+	a := treeExOfRange(0, 100, 2)
+	b := treeExOfRange(0, 150, 3)
+	u := a.Union(b, func(key Int, x, y int) int { return x + y })
+	requireValidTreeEx(t, u)
+	require.Equal(t, 50+50-17, u.Size())
+	require.Equal(t, 12, u.Find(6))
+	require.Equal(t, 4, u.Find(4))
+	require.Equal(t, 99, u.Find(99))
+	require.True(t, a == a.Union(a, nil))
+}
+
+func TestExIntersection(t *testing.T) {
+	a := treeExOfRange(0, 100, 2)
+	b := treeExOfRange(0, 150, 3)
+	i := a.Intersection(b, nil)
+	requireValidTreeEx(t, i)
+	require.Equal(t, 17, i.Size())
+	for _, k := range treeExKeys(i) {
+		require.Zero(t, k%6)
+	}
+}
+
+func TestExDifference(t *testing.T) {
+	a := treeExOfRange(0, 100, 2)
+	b := treeExOfRange(0, 150, 3)
+	d := a.Difference(b)
+	requireValidTreeEx(t, d)
+	require.Equal(t, 33, d.Size())
+	for _, k := range treeExKeys(d) {
+		require.NotZero(t, k%3)
+	}
+	require.True(t, a.Difference(a).IsEmpty())
+}
+
+func TestExOuterJoins(t *testing.T) {
+	a := treeExOfRange(0, 20, 2)
+	b := EmptyTreeEx[Int, string]().Update(4, "four").Update(5, "five")
+
+	left := LeftOuterJoinEx(a, b)
+	requireValidTreeEx(t, left)
+	require.Equal(t, a.Size(), left.Size())
+	require.Equal(t, Joined[int, string]{Left: Some(4), Right: Some("four")}, left.Find(4))
+
+	full := FullOuterJoinEx(a, b)
+	requireValidTreeEx(t, full)
+	require.Equal(t, a.Size()+1, full.Size())
+	require.Equal(t, Joined[int, string]{Right: Some("five")}, full.Find(5))
+}
+
+func TestExIterRange(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+	require.Equal(t, []Int{4, 6, 8}, collectKeys(tree.IterRange(4, 8, Closed)))
+	require.Equal(t, []Int{6}, collectKeys(tree.IterRange(4, 8, Open)))
+	require.Equal(t, []Int{0, 2}, collectKeys(tree.IterLt(4)))
+	require.Equal(t, []Int{0, 2, 4}, collectKeys(tree.IterLte(4)))
+}
+
+func TestExIterRangeDesc(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+	require.Equal(t, []Int{8, 6, 4}, collectKeys(tree.IterRangeDesc(4, 8, Closed)))
+	require.Equal(t, []Int{8, 6}, collectKeys(tree.IterRangeDesc(4, 8, OpenLow)))
+	require.Equal(t, []Int{6, 4}, collectKeys(tree.IterRangeDesc(4, 8, OpenHigh)))
+	require.Equal(t, []Int{6}, collectKeys(tree.IterRangeDesc(4, 8, Open)))
+	require.Equal(t, []Int{8, 6, 4}, collectKeys(tree.IterRangeDesc(3, 9, Open)))
+	require.Empty(t, collectKeys(tree.IterRangeDesc(8, 4, Closed)))
+}
+
+func TestExIterDesc(t *testing.T) {
+	tree := treeExOfRange(0, 10, 2)
+	require.Equal(t, []Int{8, 6, 4, 2, 0}, collectKeys(tree.IterDesc()))
+	require.Equal(t, []Int{4, 2, 0}, collectKeys(tree.IterDescFrom(5)))
+	require.Empty(t, collectKeys(tree.IterDescFrom(-1)))
+}
+
+func TestExOrderStatistics(t *testing.T) {
+	tree := treeExOfRange(1, 11, 1)
+	require.Equal(t, 4, tree.Rank(5))
+	require.Equal(t, 3, tree.CountRange(3, 6, OpenHigh))
+	require.Equal(t, 0, tree.CountRange(6, 3, Closed))
+
+	m, ok := tree.Median()
+	require.True(t, ok)
+	require.Equal(t, Int(5), m.Key)
+
+	p, ok := tree.Percentile(95)
+	require.True(t, ok)
+	require.Equal(t, Int(10), p.Key)
+
+	p, ok = treeExOfRange(1, 26, 1).Percentile(28)
+	require.True(t, ok)
+	require.Equal(t, Int(7), p.Key)
+}
+
+func TestExPositional(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+
+	d := tree.DeleteAt(3)
+	requireValidTreeEx(t, d)
+	require.False(t, d.Contains(6))
+	require.True(t, tree == tree.DeleteAt(10))
+
+	left, right := tree.SplitAt(4)
+	requireValidTreeEx(t, left)
+	requireValidTreeEx(t, right)
+	require.Equal(t, []Int{0, 2, 4, 6}, treeExKeys(left))
+	require.Equal(t, 6, right.Size())
+
+	require.Equal(t, []Int{4, 6}, treeExKeys(tree.SliceByIndex(2, 4)))
+	require.Equal(t, []Int{16, 18}, collectKeys(tree.IterFromIndex(8)))
+}
+
+func TestExTreeFromSorted(t *testing.T) {
+	pairs := make([]Pair[Int, int], 100)
+	for i := range pairs {
+		pairs[i] = Pair[Int, int]{Key: Int(i), Value: i}
+	}
+	tree, err := TreeExFromSorted(pairs)
+	require.NoError(t, err)
+	requireValidTreeEx(t, tree)
+	require.Equal(t, 100, tree.Size())
+
+	tree, err = TreeExFromSortedIterator(tree.Iter())
+	require.NoError(t, err)
+	require.Equal(t, 100, tree.Size())
+
+	_, err = TreeExFromSorted([]Pair[Int, int]{{Key: 2}, {Key: 1}})
+	require.ErrorIs(t, err, ErrUnsorted)
+	_, err = TreeExFromSorted([]Pair[Int, int]{{Key: 1}, {Key: 1}})
+	require.ErrorIs(t, err, ErrDuplicateKey)
+}
+
+func TestExUnmarshalJsonEquivalentKeys(t *testing.T) {
+	var tree *TreeEx[Int, int]
+	err := json.Unmarshal([]byte(`{"1": 1, "01": 1, "2": 2}`), &tree)
+	require.NoError(t, err)
+	requireValidTreeEx(t, tree)
+	require.Equal(t, 2, tree.Size())
+}
+
+func TestExAlter(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+	eq := func(a, b int) bool {
+		return a == b
+	}
+	double := func(old int, found bool) (int, bool) {
+		return old * 2, found
+	}
+
+	updated := tree.Alter(4, double, eq)
+	requireValidTreeEx(t, updated)
+	require.Equal(t, 8, updated.Find(4))
+	require.True(t, tree == tree.Alter(0, double, eq))
+	require.True(t, tree == tree.Alter(3, double, eq))
+
+	for i := Int(0); i < 20; i += 2 {
+		tree = tree.Alter(i, func(old int, found bool) (int, bool) {
+			return 0, false
+		}, eq)
+		requireValidTreeEx(t, tree)
+	}
+	require.True(t, tree.IsEmpty())
+}
+
+func TestExComparable(t *testing.T) {
+	calls := &comparisons{}
+	key := func(s string) countedKey { return countedKey{value: s, calls: calls} }
+
+	var tree *TreeEx[countedKey, int]
+	for i := 0; i < 100; i += 2 {
+		tree = tree.Update(key(fmt.Sprintf("%03d", i)), i)
+	}
+	requireValidTreeEx(t, tree)
+
+	*calls = comparisons{}
+	value, found := tree.FindOpt(key("042"))
+	require.True(t, found)
+	require.Equal(t, 42, value)
+	require.False(t, tree.Contains(key("043")))
+	require.Zero(t, calls.less)
+	require.NotZero(t, calls.compare)
+
+	p, ok := tree.LeastUpperBound(key("043"))
+	require.True(t, ok)
+	require.Equal(t, "044", p.Key.value)
+	p, ok = tree.GreatestLowerBound(key("043"))
+	require.True(t, ok)
+	require.Equal(t, "042", p.Key.value)
+
+	iter := tree.IterGte(key("097"))
+	require.True(t, iter.Next())
+	require.Equal(t, "098", iter.Current().Key.value)
+	require.False(t, iter.Next())
+
+	tree = tree.Delete(key("042")).Delete(key("043"))
+	requireValidTreeEx(t, tree)
+	require.Equal(t, 49, tree.Size())
+	require.False(t, tree.Contains(key("042")))
+}
+
+func TestExFindDoesNotAllocate(t *testing.T) {
+	var tree *TreeEx[String, int]
+	for i := 0; i < 100; i++ {
+		tree = tree.Update(String(fmt.Sprintf("%03d", i)), i)
+	}
+	key := String("042")
+	allocs := testing.AllocsPerRun(100, func() {
+		tree.FindOpt(key)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestExFindComparableDoesNotAllocate(t *testing.T) {
+	calls := &comparisons{}
+	var tree *TreeEx[countedKey, int]
+	for i := 0; i < 100; i++ {
+		tree = tree.Update(countedKey{value: fmt.Sprintf("%03d", i), calls: calls}, i)
+	}
+	key := countedKey{value: "042", calls: calls}
+	*calls = comparisons{}
+	allocs := testing.AllocsPerRun(100, func() {
+		tree.Find(key)
+	})
+	require.Zero(t, allocs)
+	require.Zero(t, calls.less)
+	require.NotZero(t, calls.compare)
+}
+
+// orderedKey is an interface key type whose dynamic types implement Comparable.
+type orderedKey interface {
+	Less(rhs orderedKey) bool
+	Compare(rhs orderedKey) int
+}
+
+type countedIntKey struct {
+	value int
+	calls *comparisons
+}
+
+func (x countedIntKey) Less(y orderedKey) bool {
+	x.calls.less++
+	return x.value < y.(countedIntKey).value
+}
+
+func (x countedIntKey) Compare(y orderedKey) int {
+	x.calls.compare++
+	return x.value - y.(countedIntKey).value
+}
+
+func TestExComparableInterfaceKeys(t *testing.T) {
+	calls := &comparisons{}
+	var tree *TreeEx[orderedKey, int]
+	for i := 0; i < 100; i++ {
+		tree = tree.Update(countedIntKey{value: i, calls: calls}, i)
+	}
+	requireValidTreeEx(t, tree)
+
+	var key orderedKey = countedIntKey{value: 42, calls: calls}
+	*calls = comparisons{}
+	require.Equal(t, 42, tree.Find(key))
+	require.Zero(t, calls.less)
+	require.NotZero(t, calls.compare)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		tree.Find(key)
+	})
+	require.Zero(t, allocs)
+}
+
+// benchmarkKeys returns n distinct keys sharing a long common prefix, so that comparing them is expensive.
+func benchmarkKeys(n int) []string {
+	prefix := strings.Repeat("x", 256)
+	ret := make([]string, n)
+	for i := range ret {
+		ret[i] = fmt.Sprintf("%s%08d", prefix, (i*7919)%n)
+	}
+	return ret
+}
+
+func benchmarkTreeExFind[K Ordered[K]](b *testing.B, wrap func(string) K) {
+	keys := benchmarkKeys(1 << 12)
+	var tree *TreeEx[K, int]
+	for i, k := range keys {
+		tree = tree.Update(wrap(k), i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.FindOpt(wrap(keys[i%len(keys)]))
+	}
+}
+
+func benchmarkTreeExUpdate[K Ordered[K]](b *testing.B, wrap func(string) K) {
+	keys := benchmarkKeys(1 << 12)
+	b.ResetTimer()
+	var tree *TreeEx[K, int]
+	for i := 0; i < b.N; i++ {
+		tree = tree.Update(wrap(keys[i%len(keys)]), i)
+	}
+}
+
+func BenchmarkTreeExFind(b *testing.B) {
+	b.Run("Less", func(b *testing.B) {
+		benchmarkTreeExFind(b, func(s string) String { return String(s) })
+	})
+	b.Run("Compare", func(b *testing.B) {
+		benchmarkTreeExFind(b, func(s string) CompareString { return CompareString(s) })
+	})
+}
+
+func BenchmarkTreeExUpdate(b *testing.B) {
+	b.Run("Less", func(b *testing.B) {
+		benchmarkTreeExUpdate(b, func(s string) String { return String(s) })
+	})
+	b.Run("Compare", func(b *testing.B) {
+		benchmarkTreeExUpdate(b, func(s string) CompareString { return CompareString(s) })
+	})
+}
+
+func TestExNavigation(t *testing.T) {
+	tree := treeExOfRange(0, 20, 2)
+
+	p, ok := tree.Most()
+	require.True(t, ok)
+	require.Equal(t, Int(18), p.Key)
+	p, ok = tree.Successor(Int(4))
+	require.True(t, ok)
+	require.Equal(t, Int(6), p.Key)
+	p, ok = tree.Predecessor(Int(5))
+	require.True(t, ok)
+	require.Equal(t, Int(4), p.Key)
+
+	p, rest, ok := tree.PopLeast()
+	require.True(t, ok)
+	require.Equal(t, Int(0), p.Key)
+	requireValidTreeEx(t, rest)
+	p, rest, ok = rest.PopMost()
+	require.True(t, ok)
+	require.Equal(t, Int(18), p.Key)
+	require.Equal(t, 8, rest.Size())
+}
+
+func TestExFilterPartition(t *testing.T) {
+	tree := treeExOfRange(0, 100, 1)
+	filtered := tree.Filter(func(key Int, _ int) bool { return key%3 == 0 })
+	requireValidTreeEx(t, filtered)
+	require.Equal(t, treeExKeys(treeExOfRange(0, 100, 3)), treeExKeys(filtered))
+
+	in, out := tree.Partition(func(key Int, _ int) bool { return key%3 == 0 })
+	requireValidTreeEx(t, in)
+	requireValidTreeEx(t, out)
+	require.Equal(t, treeExKeys(filtered), treeExKeys(in))
+	require.Equal(t, 66, out.Size())
+
+	mapped := MapValuesEx(tree, func(key Int, value int) bool { return value%2 == 0 })
+	requireValidTreeEx(t, mapped)
+	require.True(t, mapped.Find(Int(42)))
+	require.False(t, mapped.Find(Int(43)))
+}
@@ -0,0 +1,1342 @@
+package persistent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// TreeFunc implements a persistent AVL tree ordered by a comparator function captured at construction, for key types
+// that neither support the < operator nor implement Ordered[K]. It offers the same API as TreeEx[K,V].
+//
+// The comparator returns a negative number if a < b, zero if a == b and a positive number if a > b. It must define a
+// strict weak ordering over K and must not change the order of keys already in a tree.
+//
+// Note: Trees must be created with NewTreeFunc (or TreeFuncFromSorted) so they carry a comparator. A nil *TreeFunc is a
+// valid empty tree for all read-only operations. Every operation on a tree created by NewTreeFunc returns a non-nil
+// tree that carries the same comparator, even when the result is empty. Operations combining two trees require both of
+// them to use the same comparator.
+//
+// Like TreeEx, a TreeFunc is immutable, uses structural sharing, and may be accessed from multiple go-routines without
+// synchronization. The comparator is stored once, in the TreeFunc itself, and passed down to the operations that
+// compare keys, so the nodes of the tree hold only their keys and values.
+//
+// TreeFunc also supports json encoding / decoding, following the same rules as TreeEx. Since a comparator cannot be
+// decoded, UnmarshalJSON must be called on a tree created by NewTreeFunc.
+type TreeFunc[K any, V any] struct {
+	root *funcNode[K, V]
+	cmp  func(a, b K) int
+}
+
+// funcNode is a node of the AVL tree behind a TreeFunc or SetFunc. Operations that compare keys take the comparator
+// of the tree as a parameter.
+type funcNode[K any, V any] struct {
+	left   *funcNode[K, V]
+	right  *funcNode[K, V]
+	key    K
+	value  V
+	size   int
+	height int
+}
+
+// TreeFuncIterator defines an iterator over a TreeFunc.
+type TreeFuncIterator[K any, V any] struct {
+	stack   []*funcNode[K, V]
+	current *funcNode[K, V]
+	cmp     func(a, b K) int
+
+	// descending is true for iterators that visit keys from greatest to least.
+	descending bool
+
+	// bounded is true for iterators that stop at 'limit'. The limit is an upper bound for ascending iterators and a
+	// lower bound for descending ones. It is visited only if 'inclusive' is true.
+	bounded   bool
+	limit     K
+	inclusive bool
+}
+
+// NewTreeFunc returns an empty tree ordered by cmp.
+func NewTreeFunc[K any, V any](cmp func(a, b K) int) *TreeFunc[K, V] {
+	return &TreeFunc[K, V]{cmp: cmp}
+}
+
+// TreeFuncFromSorted returns a tree ordered by cmp containing 'pairs', which must be sorted by key in strictly
+// increasing order. If they are not, the returned error wraps ErrUnsorted or ErrDuplicateKey. TreeFuncFromSorted
+// builds a perfectly balanced tree in O(n) time.
+func TreeFuncFromSorted[K any, V any](cmp func(a, b K) int, pairs []Pair[K, V]) (*TreeFunc[K, V], error) {
+	err := checkSortedFunc(cmp, len(pairs), func(i int) K {
+		return pairs[i].Key
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TreeFunc[K, V]{root: buildFuncNode(pairs), cmp: cmp}, nil
+}
+
+// MapValuesFunc returns a tree with the same keys and comparator as t, with each value replaced by f(key, value).
+// Entries are visited in order. MapValuesFunc is O(n) and needs no comparisons or rebalancing.
+func MapValuesFunc[K any, V any, W any](t *TreeFunc[K, V], f func(key K, value V) W) *TreeFunc[K, W] {
+	if t == nil {
+		return nil
+	}
+	return &TreeFunc[K, W]{root: mapFuncNode(t.root, f), cmp: t.cmp}
+}
+
+// GetKthElement returns the k'th smallest element in the tree.
+// If no such element exists, ok will be false.
+func (t *TreeFunc[K, V]) GetKthElement(k int) (p Pair[K, V], ok bool) {
+	return t.node().getKth(k)
+}
+
+// Rank returns the number of keys in the tree that are less than 'key'. Rank is O(log(N)).
+func (t *TreeFunc[K, V]) Rank(key K) int {
+	return t.node().rank(t.comparator(), key)
+}
+
+// CountRange returns the number of keys k in the tree such that lo <= k <= hi. Use 'bounds' to exclude either endpoint
+// from the range. CountRange is O(log(N)).
+func (t *TreeFunc[K, V]) CountRange(lo K, hi K, bounds Bounds) int {
+	return t.node().countRange(t.comparator(), lo, hi, bounds)
+}
+
+// Median returns the median element of the tree. If the tree has an even number of elements, the lower of the two
+// middle elements is returned. If the tree is empty, ok will be false.
+func (t *TreeFunc[K, V]) Median() (p Pair[K, V], ok bool) {
+	return t.node().getKth((t.Size() - 1) / 2)
+}
+
+// Percentile returns the element at the p'th percentile of the tree, using the nearest-rank method. p must be between
+// 0 and 100. If the tree is empty or p is out of range, ok will be false.
+func (t *TreeFunc[K, V]) Percentile(p float64) (e Pair[K, V], ok bool) {
+	return t.node().percentile(p)
+}
+
+// DeleteAt returns a new tree with the i'th smallest entry removed. If i is out of range, t is returned unchanged.
+// DeleteAt is O(log(N)).
+func (t *TreeFunc[K, V]) DeleteAt(i int) *TreeFunc[K, V] {
+	if i < 0 || i >= t.Size() {
+		return t
+	}
+	return t.withRoot(t.node().deleteAt(i))
+}
+
+// SplitAt partitions the tree by position. It returns a tree containing the i smallest entries and a tree containing
+// the remaining ones. Values of i outside of [0, t.Size()] are clamped to that range. SplitAt is O(log(N)).
+func (t *TreeFunc[K, V]) SplitAt(i int) (left *TreeFunc[K, V], right *TreeFunc[K, V]) {
+	l, r := t.node().splitAt(i)
+	return t.withRoot(l), t.withRoot(r)
+}
+
+// SliceByIndex returns a new tree containing the entries whose positions lie in [i, j). Indexes outside of
+// [0, t.Size()] are clamped to that range. SliceByIndex is O(log(N)).
+func (t *TreeFunc[K, V]) SliceByIndex(i int, j int) *TreeFunc[K, V] {
+	return t.withRoot(t.node().sliceByIndex(i, j))
+}
+
+// IterFromIndex returns an in-order iterator that starts at the i'th smallest entry of the tree.
+func (t *TreeFunc[K, V]) IterFromIndex(i int) Iterator[Pair[K, V]] {
+	return t.node().iterFromIndex(i)
+}
+
+// IsEmpty returns true iif the tree is empty.
+func (t *TreeFunc[K, V]) IsEmpty() bool {
+	return t.node() == nil
+}
+
+// Contains returns true if the tree contains the key.
+func (t *TreeFunc[K, V]) Contains(key K) bool {
+	_, found := t.FindOpt(key)
+	return found
+}
+
+// Find returns the value associated with key in the tree. Will return a zero value if no such item exists.
+func (t *TreeFunc[K, V]) Find(key K) V {
+	value, _ := t.FindOpt(key)
+	return value
+}
+
+// FindOpt returns the value associated with key in the tree. Returns true if found; otherwise false.
+// Zero value is returned when found is false.
+func (t *TreeFunc[K, V]) FindOpt(key K) (V, bool) {
+	return t.node().find(t.comparator(), key)
+}
+
+// Update returns a new tree with the value for 'key' set to 'value'.
+func (t *TreeFunc[K, V]) Update(key K, value V) *TreeFunc[K, V] {
+	t.checkComparator("update")
+	return t.withRoot(t.root.update(t.cmp, key, value))
+}
+
+// Alter inserts, updates or deletes the entry for 'key' in a single descent. See TreeEx.Alter for details. If nothing
+// changes, Alter returns t itself.
+func (t *TreeFunc[K, V]) Alter(
+	key K,
+	f func(old V, found bool) (value V, keep bool),
+	eq func(a, b V) bool,
+) *TreeFunc[K, V] {
+	t.checkComparator("alter")
+	return t.withRoot(t.root.alter(t.cmp, key, f, eq))
+}
+
+// Delete returns a new tree with the entry for 'key' removed.
+func (t *TreeFunc[K, V]) Delete(key K) *TreeFunc[K, V] {
+	return t.withRoot(t.node().delete(t.comparator(), key))
+}
+
+// Size returns the number of entries in the tree.
+func (t *TreeFunc[K, V]) Size() int {
+	return t.node().Size()
+}
+
+// Height returns the height of the tree. Will return 0 if the tree is empty.
+func (t *TreeFunc[K, V]) Height() int {
+	return t.node().Height()
+}
+
+// Split partitions the tree around 'key'. It returns a tree containing every entry with a key less than 'key', the
+// entry for 'key' itself (found is false if there is no such entry), and a tree containing every entry with a key
+// greater than 'key'. Split is O(log(N)).
+func (t *TreeFunc[K, V]) Split(key K) (less *TreeFunc[K, V], p Pair[K, V], found bool, greater *TreeFunc[K, V]) {
+	l, p, found, g := t.node().split(t.comparator(), key)
+	return t.withRoot(l), p, found, t.withRoot(g)
+}
+
+// Join returns a new tree containing the entries of t, the pivot entry, and the entries of 'right'. Every key in t must
+// be less than pivot.Key, and every key in 'right' must be greater than it; Join panics otherwise.
+func (t *TreeFunc[K, V]) Join(pivot Pair[K, V], right *TreeFunc[K, V]) *TreeFunc[K, V] {
+	ret := t.orOther(right)
+	ret.checkComparator("join")
+	n, r := t.node(), right.node()
+	if n != nil && ret.cmp(n.rightMost().key, pivot.Key) >= 0 {
+		panic("join: keys in the left tree must be less than the pivot")
+	}
+	if r != nil && ret.cmp(pivot.Key, r.leftMost().key) >= 0 {
+		panic("join: keys in the right tree must be greater than the pivot")
+	}
+	return ret.withRoot(n.join(pivot.Key, pivot.Value, r))
+}
+
+// Join2 returns a new tree containing the entries of t and the entries of 'right'. Every key in t must be less than
+// every key in 'right'; Join2 panics otherwise.
+func (t *TreeFunc[K, V]) Join2(right *TreeFunc[K, V]) *TreeFunc[K, V] {
+	ret := t.orOther(right)
+	n, r := t.node(), right.node()
+	if n != nil && r != nil && ret.cmp(n.rightMost().key, r.leftMost().key) >= 0 {
+		panic("join: keys in the left tree must be less than keys in the right tree")
+	}
+	return ret.withRoot(n.join2(r))
+}
+
+// Union returns a new tree containing the entries of both t and 'other'. For keys present in both trees, the value is
+// merge(key, a, b) where a is the value from t and b is the value from 'other'. If merge is nil, the value from 'other'
+// wins.
+func (t *TreeFunc[K, V]) Union(other *TreeFunc[K, V], merge func(key K, a, b V) V) *TreeFunc[K, V] {
+	ret := t.orOther(other)
+	return ret.withRoot(t.node().union(ret.comparator(), other.node(), merge))
+}
+
+// Intersection returns a new tree containing the entries of t whose keys are also present in 'other'. The value is
+// combine(key, a, b) where a is the value from t and b is the value from 'other'. If combine is nil, the value from t
+// wins.
+func (t *TreeFunc[K, V]) Intersection(other *TreeFunc[K, V], combine func(key K, a, b V) V) *TreeFunc[K, V] {
+	ret := t.orOther(other)
+	return ret.withRoot(t.node().intersection(ret.comparator(), other.node(), combine))
+}
+
+// Difference returns a new tree containing the entries of t whose keys are not present in 'other'.
+func (t *TreeFunc[K, V]) Difference(other *TreeFunc[K, V]) *TreeFunc[K, V] {
+	return t.withRoot(t.node().difference(t.orOther(other).comparator(), other.node()))
+}
+
+// Filter returns a new tree containing the entries of t for which pred(key, value) returns true. Filter is O(N).
+func (t *TreeFunc[K, V]) Filter(pred func(key K, value V) bool) *TreeFunc[K, V] {
+	return t.withRoot(t.node().filter(pred))
+}
+
+// Partition splits t into a tree containing the entries for which pred(key, value) returns true and a tree containing
+// the remaining entries, in a single O(N) pass.
+func (t *TreeFunc[K, V]) Partition(pred func(key K, value V) bool) (matching *TreeFunc[K, V], rest *TreeFunc[K, V]) {
+	in, out := t.node().partition(pred)
+	return t.withRoot(in), t.withRoot(out)
+}
+
+// LeastUpperBound returns the entry with the smallest key greater than or equal to 'key'. If no such entry exists, ok
+// will be false.
+func (t *TreeFunc[K, V]) LeastUpperBound(key K) (Pair[K, V], bool) {
+	return t.node().leastUpperBound(t.comparator(), key)
+}
+
+// GreatestLowerBound returns the entry with the largest key less than or equal to 'key'. If no such entry exists, ok
+// will be false.
+func (t *TreeFunc[K, V]) GreatestLowerBound(key K) (Pair[K, V], bool) {
+	return t.node().greatestLowerBound(t.comparator(), key)
+}
+
+// Iter returns an in-order iterator for the tree.
+func (t *TreeFunc[K, V]) Iter() Iterator[Pair[K, V]] {
+	return t.node().iter()
+}
+
+// IterGte returns an in-order iterator for all entries whose key is >= glb.
+func (t *TreeFunc[K, V]) IterGte(glb K) Iterator[Pair[K, V]] {
+	return t.node().iterGte(t.comparator(), glb)
+}
+
+// IterRange returns an in-order iterator for all entries whose key k satisfies lo <= k <= hi. Use 'bounds' to exclude
+// either endpoint from the range.
+func (t *TreeFunc[K, V]) IterRange(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	return t.node().iterRange(t.comparator(), lo, hi, bounds)
+}
+
+// IterLt returns an in-order iterator for all entries whose key is < lub.
+func (t *TreeFunc[K, V]) IterLt(lub K) Iterator[Pair[K, V]] {
+	return t.node().iterLess(t.comparator(), lub, false)
+}
+
+// IterLte returns an in-order iterator for all entries whose key is <= lub.
+func (t *TreeFunc[K, V]) IterLte(lub K) Iterator[Pair[K, V]] {
+	return t.node().iterLess(t.comparator(), lub, true)
+}
+
+// IterDesc returns an iterator that visits every entry of the tree from the greatest key to the least.
+func (t *TreeFunc[K, V]) IterDesc() Iterator[Pair[K, V]] {
+	return t.node().iterDesc()
+}
+
+// IterDescFrom returns an iterator that visits every entry whose key is <= 'key', from the greatest key to the least.
+func (t *TreeFunc[K, V]) IterDescFrom(key K) Iterator[Pair[K, V]] {
+	return t.node().iterDescFrom(t.comparator(), key)
+}
+
+// IterRangeDesc returns an iterator for all entries whose key k satisfies lo <= k <= hi, from the greatest key to the
+// least. Use 'bounds' to exclude either endpoint from the range.
+func (t *TreeFunc[K, V]) IterRangeDesc(lo K, hi K, bounds Bounds) Iterator[Pair[K, V]] {
+	return t.node().iterRangeDesc(t.comparator(), lo, hi, bounds)
+}
+
+// Least returns the entry with the smallest key in the tree. If the tree is empty, ok will be false.
+func (t *TreeFunc[K, V]) Least() (Pair[K, V], bool) {
+	n := t.node().leftMost()
+	if n == nil {
+		return Pair[K, V]{}, false
+	}
+	return n.pair(), true
+}
+
+// Most returns the entry with the largest key in the tree. If the tree is empty, ok will be false.
+func (t *TreeFunc[K, V]) Most() (Pair[K, V], bool) {
+	n := t.node().rightMost()
+	if n == nil {
+		return Pair[K, V]{}, false
+	}
+	return n.pair(), true
+}
+
+// Successor returns the entry with the least key strictly greater than 'key'. If there is no such entry, ok is false.
+func (t *TreeFunc[K, V]) Successor(key K) (p Pair[K, V], ok bool) {
+	return t.node().successor(t.comparator(), key)
+}
+
+// Predecessor returns the entry with the greatest key strictly less than 'key'. If there is no such entry, ok is false.
+func (t *TreeFunc[K, V]) Predecessor(key K) (p Pair[K, V], ok bool) {
+	return t.node().predecessor(t.comparator(), key)
+}
+
+// PopLeast removes the entry with the least key in a single descent. It returns the removed entry along with the new
+// tree. If the tree is empty, ok is false. PopLeast is O(log(N)).
+func (t *TreeFunc[K, V]) PopLeast() (p Pair[K, V], rest *TreeFunc[K, V], ok bool) {
+	if t.IsEmpty() {
+		return p, t.withRoot(nil), false
+	}
+	root, p := t.root.splitLeast()
+	return p, t.withRoot(root), true
+}
+
+// PopMost removes the entry with the greatest key in a single descent. It returns the removed entry along with the new
+// tree. If the tree is empty, ok is false. PopMost is O(log(N)).
+func (t *TreeFunc[K, V]) PopMost() (p Pair[K, V], rest *TreeFunc[K, V], ok bool) {
+	if t.IsEmpty() {
+		return p, t.withRoot(nil), false
+	}
+	root, p := t.root.splitMost()
+	return p, t.withRoot(root), true
+}
+
+// MarshalJSON marshals the tree as a json object, using fmt.Sprint to convert keys to strings.
+func (t *TreeFunc[K, V]) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	_, err := buf.WriteString("{")
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(buf)
+	first := true
+	iter := t.Iter()
+	for iter.Next() {
+		if !first {
+			_, err = buf.WriteString(",")
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			first = false
+		}
+
+		key := fmt.Sprint(iter.Current().Key)
+		err = encoder.Encode(key)
+		if err != nil {
+			return nil, err
+		}
+		_, err = buf.WriteString(":")
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Encode(iter.Current().Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON unmarshals a json object into t, replacing its contents. The type K should support the
+// encoding.TextUnmarshaler interface. If t was not created by NewTreeFunc, UnmarshalJSON returns ErrNoComparator.
+func (t *TreeFunc[K, V]) UnmarshalJSON(data []byte) error {
+	if t.cmp == nil {
+		return ErrNoComparator
+	}
+	var m map[string]V
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+	pairs := make([]Pair[K, V], 0, len(m))
+	for strKey, v := range m {
+		jsonStr, err := json.Marshal(strKey)
+		if err != nil {
+			return err
+		}
+		var key K
+		err = json.Unmarshal(jsonStr, &key)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, Pair[K, V]{Key: key, Value: v})
+	}
+
+	// Distinct strings may decode to equal keys, so keep a single entry for each key.
+	t.root = buildFuncNodeUnsorted(t.cmp, pairs)
+	return nil
+}
+
+func (t *TreeFunc[K, V]) node() *funcNode[K, V] {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+// comparator returns the comparator of t, or nil if t has none. Trees without a comparator are empty, so operations
+// on them never call it.
+func (t *TreeFunc[K, V]) comparator() func(a, b K) int {
+	if t == nil {
+		return nil
+	}
+	return t.cmp
+}
+
+// withRoot returns a tree rooted at 'root' that shares the comparator of t, reusing t if it already has that root.
+func (t *TreeFunc[K, V]) withRoot(root *funcNode[K, V]) *TreeFunc[K, V] {
+	if t == nil {
+		if root == nil {
+			return nil
+		}
+		panic("TreeFunc must be created with NewTreeFunc")
+	}
+	if root == t.root {
+		return t
+	}
+	return &TreeFunc[K, V]{root: root, cmp: t.cmp}
+}
+
+// orOther returns t if it carries a comparator, and 'other' otherwise.
+func (t *TreeFunc[K, V]) orOther(other *TreeFunc[K, V]) *TreeFunc[K, V] {
+	if t == nil || t.cmp == nil {
+		return other
+	}
+	return t
+}
+
+func (t *TreeFunc[K, V]) checkComparator(op string) {
+	if t == nil || t.cmp == nil {
+		panic(op + ": TreeFunc must be created with NewTreeFunc")
+	}
+}
+
+// checkSortedFunc verifies that the n keys returned by key(0) ... key(n-1) are in strictly increasing order.
+func checkSortedFunc[K any](cmp func(a, b K) int, n int, key func(i int) K) error {
+	for i := 1; i < n; i++ {
+		c := cmp(key(i-1), key(i))
+		if c > 0 {
+			return fmt.Errorf("%w: the key at index %v is less than the key before it", ErrUnsorted, i)
+		}
+		if c == 0 {
+			return fmt.Errorf("%w at index %v", ErrDuplicateKey, i)
+		}
+	}
+	return nil
+}
+
+// buildFuncNode returns a perfectly balanced tree containing 'pairs', which must already be sorted and unique.
+func buildFuncNode[K any, V any](pairs []Pair[K, V]) *funcNode[K, V] {
+	if len(pairs) == 0 {
+		return nil
+	}
+	mid := len(pairs) / 2
+	return newFuncNode(buildFuncNode(pairs[:mid]), buildFuncNode(pairs[mid+1:]), pairs[mid].Key, pairs[mid].Value)
+}
+
+// buildFuncNodeUnsorted returns a perfectly balanced tree containing 'pairs', which may be in any order. If a key
+// appears more than once, the last of its pairs wins. 'pairs' is sorted in place.
+func buildFuncNodeUnsorted[K any, V any](cmp func(a, b K) int, pairs []Pair[K, V]) *funcNode[K, V] {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return cmp(pairs[i].Key, pairs[j].Key) < 0
+	})
+	unique := pairs[:0]
+	for _, p := range pairs {
+		if len(unique) != 0 && cmp(unique[len(unique)-1].Key, p.Key) == 0 {
+			unique[len(unique)-1] = p
+			continue
+		}
+		unique = append(unique, p)
+	}
+	return buildFuncNode(unique)
+}
+
+func newFuncNode[K any, V any](left *funcNode[K, V], right *funcNode[K, V], key K, value V) *funcNode[K, V] {
+	return &funcNode[K, V]{
+		left:   left,
+		right:  right,
+		key:    key,
+		value:  value,
+		size:   left.Size() + right.Size() + 1,
+		height: max(left.Height(), right.Height()) + 1,
+	}
+}
+
+// mapFuncNode returns a tree with the same shape as n, with each value replaced by f(key, value).
+func mapFuncNode[K any, V any, W any](n *funcNode[K, V], f func(key K, value V) W) *funcNode[K, W] {
+	if n == nil {
+		return nil
+	}
+
+	left := mapFuncNode(n.left, f)
+	value := f(n.key, n.value)
+	right := mapFuncNode(n.right, f)
+
+	return &funcNode[K, W]{
+		left:   left,
+		right:  right,
+		key:    n.key,
+		value:  value,
+		size:   n.size,
+		height: n.height,
+	}
+}
+
+func (n *funcNode[K, V]) Size() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *funcNode[K, V]) Height() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *funcNode[K, V]) pair() Pair[K, V] {
+	return Pair[K, V]{Key: n.key, Value: n.value}
+}
+
+func (n *funcNode[K, V]) getKth(k int) (p Pair[K, V], ok bool) {
+	for n != nil {
+		leftSize := n.left.Size()
+		if k < leftSize {
+			n = n.left
+		} else if k > leftSize {
+			k -= leftSize + 1
+			n = n.right
+		} else {
+			return n.pair(), true
+		}
+	}
+	return p, false
+}
+
+func (n *funcNode[K, V]) percentile(p float64) (e Pair[K, V], ok bool) {
+	if n == nil || !(p >= 0 && p <= 100) {
+		return e, false
+	}
+	return n.getKth(percentileIndex(p, n.Size()))
+}
+
+// rank returns the number of keys in the tree that are less than 'key'.
+func (n *funcNode[K, V]) rank(cmp func(a, b K) int, key K) int {
+	rank := 0
+	for n != nil {
+		if cmp(n.key, key) < 0 {
+			rank += n.left.Size() + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return rank
+}
+
+// rankLte returns the number of keys in the tree that are less than or equal to 'key'.
+func (n *funcNode[K, V]) rankLte(cmp func(a, b K) int, key K) int {
+	rank := 0
+	for n != nil {
+		if cmp(key, n.key) < 0 {
+			n = n.left
+		} else {
+			rank += n.left.Size() + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+func (n *funcNode[K, V]) countRange(cmp func(a, b K) int, lo K, hi K, bounds Bounds) int {
+	var lower, upper int
+	if bounds.excludesLow() {
+		lower = n.rankLte(cmp, lo)
+	} else {
+		lower = n.rank(cmp, lo)
+	}
+	if bounds.excludesHigh() {
+		upper = n.rank(cmp, hi)
+	} else {
+		upper = n.rankLte(cmp, hi)
+	}
+
+	if upper < lower {
+		return 0
+	}
+	return upper - lower
+}
+
+// deleteAt returns n with the i'th smallest entry removed. i must be in range.
+func (n *funcNode[K, V]) deleteAt(i int) *funcNode[K, V] {
+	leftSize := n.left.Size()
+
+	if i < leftSize {
+		return newFuncNode(n.left.deleteAt(i), n.right, n.key, n.value).rebalance()
+	}
+
+	if i > leftSize {
+		return newFuncNode(n.left, n.right.deleteAt(i-leftSize-1), n.key, n.value).rebalance()
+	}
+
+	return n.deleteCurrent()
+}
+
+func (n *funcNode[K, V]) splitAt(i int) (left *funcNode[K, V], right *funcNode[K, V]) {
+	if i <= 0 {
+		return nil, n
+	}
+	if i >= n.Size() {
+		return n, nil
+	}
+
+	leftSize := n.left.Size()
+	if i <= leftSize {
+		left, right = n.left.splitAt(i)
+		return left, right.join(n.key, n.value, n.right)
+	}
+
+	left, right = n.right.splitAt(i - leftSize - 1)
+	return n.left.join(n.key, n.value, left), right
+}
+
+func (n *funcNode[K, V]) sliceByIndex(i int, j int) *funcNode[K, V] {
+	if i >= j {
+		return nil
+	}
+	prefix, _ := n.splitAt(j)
+	_, ret := prefix.splitAt(i)
+	return ret
+}
+
+func (n *funcNode[K, V]) find(cmp func(a, b K) int, key K) (V, bool) {
+	for n != nil {
+		c := cmp(key, n.key)
+		if c > 0 {
+			n = n.right
+		} else if c < 0 {
+			n = n.left
+		} else {
+			return n.value, true
+		}
+	}
+	var ret V
+	return ret, false
+}
+
+func (n *funcNode[K, V]) update(cmp func(a, b K) int, key K, value V) *funcNode[K, V] {
+	if n == nil {
+		return newFuncNode(nil, nil, key, value)
+	}
+
+	c := cmp(key, n.key)
+	if c > 0 {
+		return newFuncNode(n.left, n.right.update(cmp, key, value), n.key, n.value).rebalance()
+	}
+
+	if c < 0 {
+		return newFuncNode(n.left.update(cmp, key, value), n.right, n.key, n.value).rebalance()
+	}
+
+	return newFuncNode(n.left, n.right, key, value)
+}
+
+func (n *funcNode[K, V]) alter(
+	cmp func(a, b K) int,
+	key K,
+	f func(old V, found bool) (value V, keep bool),
+	eq func(a, b V) bool,
+) *funcNode[K, V] {
+	if n == nil {
+		var zv V
+		value, keep := f(zv, false)
+		if !keep {
+			return nil
+		}
+		return newFuncNode(nil, nil, key, value)
+	}
+
+	c := cmp(key, n.key)
+	if c > 0 {
+		right := n.right.alter(cmp, key, f, eq)
+		if right == n.right {
+			return n
+		}
+		return newFuncNode(n.left, right, n.key, n.value).rebalance()
+	}
+
+	if c < 0 {
+		left := n.left.alter(cmp, key, f, eq)
+		if left == n.left {
+			return n
+		}
+		return newFuncNode(left, n.right, n.key, n.value).rebalance()
+	}
+
+	value, keep := f(n.value, true)
+	if !keep {
+		return n.deleteCurrent()
+	}
+	if eq != nil && eq(n.value, value) {
+		return n
+	}
+	return newFuncNode(n.left, n.right, n.key, value)
+}
+
+// delete returns n with the entry for 'key' removed, or n itself if there is no such entry.
+func (n *funcNode[K, V]) delete(cmp func(a, b K) int, key K) *funcNode[K, V] {
+	if n == nil {
+		return nil
+	}
+
+	c := cmp(key, n.key)
+	if c > 0 {
+		right := n.right.delete(cmp, key)
+		if right == n.right {
+			return n
+		}
+		return newFuncNode(n.left, right, n.key, n.value).rebalance()
+	}
+
+	if c < 0 {
+		left := n.left.delete(cmp, key)
+		if left == n.left {
+			return n
+		}
+		return newFuncNode(left, n.right, n.key, n.value).rebalance()
+	}
+
+	return n.deleteCurrent()
+}
+
+// deleteCurrent returns the balanced subtree left after removing the entry held by n.
+func (n *funcNode[K, V]) deleteCurrent() *funcNode[K, V] {
+	if n.left == nil {
+		return n.right
+	}
+	if n.right == nil {
+		return n.left
+	}
+	left, most := n.left.removeMost()
+	return newFuncNode(left, n.right, most.key, most.value).rebalance()
+}
+
+// removeMost returns n with its greatest entry removed, along with the node holding that entry.
+func (n *funcNode[K, V]) removeMost() (*funcNode[K, V], *funcNode[K, V]) {
+	if n.right == nil {
+		return n.left, n
+	}
+	right, most := n.right.removeMost()
+	return newFuncNode(n.left, right, n.key, n.value).rebalance(), most
+}
+
+func (n *funcNode[K, V]) leftMost() *funcNode[K, V] {
+	if n == nil {
+		return nil
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func (n *funcNode[K, V]) rightMost() *funcNode[K, V] {
+	if n == nil {
+		return nil
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+func (n *funcNode[K, V]) balanceFactor() int {
+	if n == nil {
+		return 0
+	}
+	return n.right.Height() - n.left.Height()
+}
+
+func (n *funcNode[K, V]) rebalance() *funcNode[K, V] {
+	balance := n.balanceFactor()
+	if abs(balance) <= 1 {
+		return n
+	}
+
+	if balance > 0 {
+		if n.right.balanceFactor() >= 0 {
+			return n.rotateLeft()
+		}
+		return newFuncNode(n.left, n.right.rotateRight(), n.key, n.value).rotateLeft()
+	}
+
+	if n.left.balanceFactor() <= 0 {
+		return n.rotateRight()
+	}
+	return newFuncNode(n.left.rotateLeft(), n.right, n.key, n.value).rotateRight()
+}
+
+func (n *funcNode[K, V]) rotateLeft() *funcNode[K, V] {
+	return newFuncNode(
+		newFuncNode(n.left, n.right.left, n.key, n.value),
+		n.right.right,
+		n.right.key,
+		n.right.value,
+	)
+}
+
+func (n *funcNode[K, V]) rotateRight() *funcNode[K, V] {
+	return newFuncNode(
+		n.left.left,
+		newFuncNode(n.left.right, n.right, n.key, n.value),
+		n.left.key,
+		n.left.value,
+	)
+}
+
+func (n *funcNode[K, V]) split(
+	cmp func(a, b K) int,
+	key K,
+) (less *funcNode[K, V], p Pair[K, V], found bool, greater *funcNode[K, V]) {
+	if n == nil {
+		return nil, p, false, nil
+	}
+
+	c := cmp(key, n.key)
+	if c > 0 {
+		less, p, found, greater = n.right.split(cmp, key)
+		return n.left.join(n.key, n.value, less), p, found, greater
+	}
+
+	if c < 0 {
+		less, p, found, greater = n.left.split(cmp, key)
+		return less, p, found, greater.join(n.key, n.value, n.right)
+	}
+
+	return n.left, n.pair(), true, n.right
+}
+
+// join concatenates n, the entry (key, value) and right without checking that the key ranges are ordered.
+func (n *funcNode[K, V]) join(key K, value V, right *funcNode[K, V]) *funcNode[K, V] {
+	lh, rh := n.Height(), right.Height()
+
+	if lh > rh+1 {
+		return newFuncNode(n.left, n.right.join(key, value, right), n.key, n.value).rebalance()
+	}
+
+	if rh > lh+1 {
+		return newFuncNode(n.join(key, value, right.left), right.right, right.key, right.value).rebalance()
+	}
+
+	return newFuncNode(n, right, key, value)
+}
+
+// join2 concatenates n and right without checking that the key ranges are ordered.
+func (n *funcNode[K, V]) join2(right *funcNode[K, V]) *funcNode[K, V] {
+	if n == nil {
+		return right
+	}
+	if right == nil {
+		return n
+	}
+	rest, last := n.splitMost()
+	return rest.join(last.Key, last.Value, right)
+}
+
+// splitMost returns n with its greatest entry removed, along with that entry. n must not be empty.
+func (n *funcNode[K, V]) splitMost() (*funcNode[K, V], Pair[K, V]) {
+	if n.right == nil {
+		return n.left, n.pair()
+	}
+	rest, last := n.right.splitMost()
+	return n.left.join(n.key, n.value, rest), last
+}
+
+// splitLeast returns n with its least entry removed, along with that entry. n must not be empty.
+func (n *funcNode[K, V]) splitLeast() (*funcNode[K, V], Pair[K, V]) {
+	if n.left == nil {
+		return n.right, n.pair()
+	}
+	rest, first := n.left.splitLeast()
+	return rest.join(n.key, n.value, n.right), first
+}
+
+func (n *funcNode[K, V]) union(
+	cmp func(a, b K) int,
+	other *funcNode[K, V],
+	merge func(key K, a, b V) V,
+) *funcNode[K, V] {
+	if n == other || other == nil {
+		return n
+	}
+	if n == nil {
+		return other
+	}
+
+	less, p, found, greater := other.split(cmp, n.key)
+	left := n.left.union(cmp, less, merge)
+	right := n.right.union(cmp, greater, merge)
+
+	value := n.value
+	if found {
+		if merge != nil {
+			value = merge(n.key, n.value, p.Value)
+		} else {
+			value = p.Value
+		}
+	} else if left == n.left && right == n.right {
+		return n
+	}
+
+	return left.join(n.key, value, right)
+}
+
+func (n *funcNode[K, V]) intersection(
+	cmp func(a, b K) int,
+	other *funcNode[K, V],
+	combine func(key K, a, b V) V,
+) *funcNode[K, V] {
+	if n == other {
+		return n
+	}
+	if n == nil || other == nil {
+		return nil
+	}
+
+	less, p, found, greater := other.split(cmp, n.key)
+	left := n.left.intersection(cmp, less, combine)
+	right := n.right.intersection(cmp, greater, combine)
+
+	if !found {
+		return left.join2(right)
+	}
+
+	value := n.value
+	if combine != nil {
+		value = combine(n.key, n.value, p.Value)
+	} else if left == n.left && right == n.right {
+		return n
+	}
+
+	return left.join(n.key, value, right)
+}
+
+func (n *funcNode[K, V]) difference(cmp func(a, b K) int, other *funcNode[K, V]) *funcNode[K, V] {
+	if n == other || n == nil {
+		return nil
+	}
+	if other == nil {
+		return n
+	}
+
+	less, _, found, greater := other.split(cmp, n.key)
+	left := n.left.difference(cmp, less)
+	right := n.right.difference(cmp, greater)
+
+	if found {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
+	}
+	return left.join(n.key, n.value, right)
+}
+
+func (n *funcNode[K, V]) symmetricDifference(cmp func(a, b K) int, other *funcNode[K, V]) *funcNode[K, V] {
+	if n == other {
+		return nil
+	}
+	if n == nil {
+		return other
+	}
+	if other == nil {
+		return n
+	}
+
+	less, _, found, greater := other.split(cmp, n.key)
+	left := n.left.symmetricDifference(cmp, less)
+	right := n.right.symmetricDifference(cmp, greater)
+
+	if found {
+		return left.join2(right)
+	}
+	return left.join(n.key, n.value, right)
+}
+
+// isSubsetOf returns true iif every key in n is also found in 'other'.
+func (n *funcNode[K, V]) isSubsetOf(cmp func(a, b K) int, other *funcNode[K, V]) bool {
+	if n == other || n == nil {
+		return true
+	}
+	if n.Size() > other.Size() {
+		return false
+	}
+
+	less, _, found, greater := other.split(cmp, n.key)
+	return found && n.left.isSubsetOf(cmp, less) && n.right.isSubsetOf(cmp, greater)
+}
+
+// isDisjoint returns true iif n and 'other' have no keys in common.
+func (n *funcNode[K, V]) isDisjoint(cmp func(a, b K) int, other *funcNode[K, V]) bool {
+	if n == nil || other == nil {
+		return true
+	}
+	if n == other {
+		return false
+	}
+
+	less, _, found, greater := other.split(cmp, n.key)
+	return !found && n.left.isDisjoint(cmp, less) && n.right.isDisjoint(cmp, greater)
+}
+
+func (n *funcNode[K, V]) filter(pred func(key K, value V) bool) *funcNode[K, V] {
+	if n == nil {
+		return nil
+	}
+
+	left := n.left.filter(pred)
+	keep := pred(n.key, n.value)
+	right := n.right.filter(pred)
+
+	if !keep {
+		return left.join2(right)
+	}
+	if left == n.left && right == n.right {
+		return n
+	}
+	return left.join(n.key, n.value, right)
+}
+
+func (n *funcNode[K, V]) partition(pred func(key K, value V) bool) (matching *funcNode[K, V], rest *funcNode[K, V]) {
+	if n == nil {
+		return nil, nil
+	}
+
+	leftIn, leftOut := n.left.partition(pred)
+	keep := pred(n.key, n.value)
+	rightIn, rightOut := n.right.partition(pred)
+
+	if !keep {
+		if leftOut == n.left && rightOut == n.right {
+			return leftIn.join2(rightIn), n
+		}
+		return leftIn.join2(rightIn), leftOut.join(n.key, n.value, rightOut)
+	}
+	if leftIn == n.left && rightIn == n.right {
+		return n, leftOut.join2(rightOut)
+	}
+	return leftIn.join(n.key, n.value, rightIn), leftOut.join2(rightOut)
+}
+
+func (n *funcNode[K, V]) leastUpperBound(cmp func(a, b K) int, key K) (p Pair[K, V], ok bool) {
+	var ret *funcNode[K, V]
+	for n != nil {
+		c := cmp(key, n.key)
+		if c > 0 {
+			n = n.right
+		} else if c < 0 {
+			ret = n
+			n = n.left
+		} else {
+			return n.pair(), true
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+func (n *funcNode[K, V]) greatestLowerBound(cmp func(a, b K) int, key K) (p Pair[K, V], ok bool) {
+	var ret *funcNode[K, V]
+	for n != nil {
+		c := cmp(key, n.key)
+		if c < 0 {
+			n = n.left
+		} else if c > 0 {
+			ret = n
+			n = n.right
+		} else {
+			return n.pair(), true
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+func (n *funcNode[K, V]) successor(cmp func(a, b K) int, key K) (p Pair[K, V], ok bool) {
+	var ret *funcNode[K, V]
+	for n != nil {
+		if cmp(key, n.key) < 0 {
+			ret = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+func (n *funcNode[K, V]) predecessor(cmp func(a, b K) int, key K) (p Pair[K, V], ok bool) {
+	var ret *funcNode[K, V]
+	for n != nil {
+		if cmp(n.key, key) < 0 {
+			ret = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	if ret == nil {
+		return p, false
+	}
+	return ret.pair(), true
+}
+
+func (n *funcNode[K, V]) iter() *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{}
+	ret.pushLeft(n)
+	return ret
+}
+
+func (n *funcNode[K, V]) iterGte(cmp func(a, b K) int, glb K) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{cmp: cmp}
+	ret.seekAscending(n, glb, false)
+	return ret
+}
+
+func (n *funcNode[K, V]) iterRange(cmp func(a, b K) int, lo K, hi K, bounds Bounds) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{
+		cmp:       cmp,
+		bounded:   true,
+		limit:     hi,
+		inclusive: !bounds.excludesHigh(),
+	}
+	ret.seekAscending(n, lo, bounds.excludesLow())
+	return ret
+}
+
+// iterLess returns an in-order iterator for the keys less than 'lub', or less than or equal to it if inclusive is
+// true.
+func (n *funcNode[K, V]) iterLess(cmp func(a, b K) int, lub K, inclusive bool) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{
+		cmp:       cmp,
+		bounded:   true,
+		limit:     lub,
+		inclusive: inclusive,
+	}
+	ret.pushLeft(n)
+	return ret
+}
+
+func (n *funcNode[K, V]) iterDesc() *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{descending: true}
+	ret.pushRight(n)
+	return ret
+}
+
+func (n *funcNode[K, V]) iterDescFrom(cmp func(a, b K) int, key K) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{cmp: cmp, descending: true}
+	ret.seekDescending(n, key, false)
+	return ret
+}
+
+func (n *funcNode[K, V]) iterRangeDesc(cmp func(a, b K) int, lo K, hi K, bounds Bounds) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{
+		cmp:        cmp,
+		descending: true,
+		bounded:    true,
+		limit:      lo,
+		inclusive:  !bounds.excludesLow(),
+	}
+	ret.seekDescending(n, hi, bounds.excludesHigh())
+	return ret
+}
+
+func (n *funcNode[K, V]) iterFromIndex(i int) *TreeFuncIterator[K, V] {
+	ret := &TreeFuncIterator[K, V]{}
+	ret.seekIndex(n, i)
+	return ret
+}
+
+func (i *TreeFuncIterator[K, V]) Next() bool {
+	if i.current != nil {
+		if i.descending {
+			i.pushRight(i.current.left)
+		} else {
+			i.pushLeft(i.current.right)
+		}
+		i.current = nil
+	}
+
+	if len(i.stack) != 0 {
+		i.current = i.stack[len(i.stack)-1]
+		i.stack = i.stack[:len(i.stack)-1]
+		if i.bounded && i.pastLimit(i.current.key) {
+			i.stack = nil
+			i.current = nil
+			return false
+		}
+		return true
+	}
+
+	return false
+}
+
+func (i *TreeFuncIterator[K, V]) Current() Pair[K, V] {
+	if i.current == nil {
+		panic("invalid iterator position")
+	}
+	return i.current.pair()
+}
+
+func (i *TreeFuncIterator[K, V]) pastLimit(key K) bool {
+	c := i.cmp(key, i.limit)
+	if i.descending {
+		return c < 0 || (!i.inclusive && c == 0)
+	}
+	return c > 0 || (!i.inclusive && c == 0)
+}
+
+// pushLeft pushes n and the left spine below it onto the stack.
+func (i *TreeFuncIterator[K, V]) pushLeft(n *funcNode[K, V]) {
+	for n != nil {
+		i.stack = append(i.stack, n)
+		n = n.left
+	}
+}
+
+// pushRight pushes n and the right spine below it onto the stack.
+func (i *TreeFuncIterator[K, V]) pushRight(n *funcNode[K, V]) {
+	for n != nil {
+		i.stack = append(i.stack, n)
+		n = n.right
+	}
+}
+
+// seekAscending positions an ascending iterator so that the first call to Next visits the least key k in n such that
+// k >= key, or k > key if strict is true.
+func (i *TreeFuncIterator[K, V]) seekAscending(n *funcNode[K, V], key K, strict bool) {
+	for n != nil {
+		c := i.cmp(key, n.key)
+		if c > 0 {
+			n = n.right
+		} else if c < 0 {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if strict {
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekDescending positions a descending iterator so that the first call to Next visits the greatest key k in n such
+// that k <= key, or k < key if strict is true.
+func (i *TreeFuncIterator[K, V]) seekDescending(n *funcNode[K, V], key K, strict bool) {
+	for n != nil {
+		c := i.cmp(key, n.key)
+		if c < 0 {
+			n = n.left
+		} else if c > 0 {
+			i.stack = append(i.stack, n)
+			n = n.right
+		} else if strict {
+			n = n.left
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
+
+// seekIndex positions an ascending iterator so that the first call to Next visits the k'th smallest key in n.
+func (i *TreeFuncIterator[K, V]) seekIndex(n *funcNode[K, V], k int) {
+	for n != nil {
+		leftSize := n.left.Size()
+		if k < leftSize {
+			i.stack = append(i.stack, n)
+			n = n.left
+		} else if k > leftSize {
+			k -= leftSize + 1
+			n = n.right
+		} else {
+			i.stack = append(i.stack, n)
+			return
+		}
+	}
+}
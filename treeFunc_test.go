@@ -0,0 +1,198 @@
+package persistent
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func descending(a, b int) int {
+	return b - a
+}
+
+func treeFuncOfRange(lo, hi int) *TreeFunc[int, int] {
+	tree := NewTreeFunc[int, int](descending)
+	for i := lo; i < hi; i++ {
+		tree = tree.Update(i, i)
+	}
+	return tree
+}
+
+func requireValidFuncNode[K any, V any](t *testing.T, cmp func(a, b K) int, n *funcNode[K, V]) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+	requireValidFuncNode(t, cmp, n.left)
+	requireValidFuncNode(t, cmp, n.right)
+	if n.left != nil {
+		require.Less(t, cmp(n.left.rightMost().key, n.key), 0)
+	}
+	if n.right != nil {
+		require.Less(t, cmp(n.key, n.right.leftMost().key), 0)
+	}
+	require.Equal(t, n.left.Size()+n.right.Size()+1, n.size)
+	require.Equal(t, max(n.left.Height(), n.right.Height())+1, n.height)
+	require.LessOrEqual(t, abs(n.balanceFactor()), 1)
+}
+
+func collectPairKeys[K any, V any](iter Iterator[Pair[K, V]]) []K {
+	var ret []K
+	for iter.Next() {
+		ret = append(ret, iter.Current().Key)
+	}
+	return ret
+}
+
+func TestTreeFuncOrder(t *testing.T) {
+	tree := treeFuncOfRange(0, 10)
+	requireValidFuncNode(t, tree.cmp, tree.root)
+	require.Equal(t, 10, tree.Size())
+	require.Equal(t, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, collectPairKeys(tree.Iter()))
+	require.Equal(t, []int{7, 8, 9}, collectPairKeys(tree.IterDescFrom(7)))
+	require.Equal(t, []int{6, 5, 4}, collectPairKeys(tree.IterRange(7, 3, Open)))
+	require.Equal(t, []int{3, 4, 5, 6}, collectPairKeys(tree.IterRangeDesc(7, 3, OpenLow)))
+	require.Equal(t, []int{9, 8, 7}, collectPairKeys(tree.IterLte(7)))
+	require.Equal(t, []int{1, 0}, collectPairKeys(tree.IterGte(1)))
+
+	p, ok := tree.GetKthElement(0)
+	require.True(t, ok)
+	require.Equal(t, 9, p.Key)
+	p, _ = tree.Least()
+	require.Equal(t, 9, p.Key)
+	p, _ = tree.Most()
+	require.Equal(t, 0, p.Key)
+	require.Equal(t, 3, tree.Rank(6))
+	require.Equal(t, 4, tree.CountRange(7, 4, Closed))
+
+	p, ok = tree.LeastUpperBound(20)
+	require.True(t, ok)
+	require.Equal(t, 9, p.Key)
+	_, ok = tree.GreatestLowerBound(20)
+	require.False(t, ok)
+}
+
+func TestTreeFuncUpdates(t *testing.T) {
+	tree := treeFuncOfRange(0, 10)
+	require.Equal(t, 5, tree.Find(5))
+	require.Same(t, tree, tree.Delete(20))
+
+	deleted := tree.Delete(5)
+	requireValidFuncNode(t, deleted.cmp, deleted.root)
+	require.False(t, deleted.Contains(5))
+	require.True(t, tree.Contains(5))
+
+	empty := tree.SliceByIndex(3, 3)
+	require.NotNil(t, empty)
+	require.True(t, empty.IsEmpty())
+	require.Equal(t, []int{7}, collectPairKeys(empty.Update(7, 7).Iter()))
+
+	less, p, found, greater := tree.Split(4)
+	require.True(t, found)
+	require.Equal(t, 4, p.Key)
+	require.Equal(t, []int{9, 8, 7, 6, 5}, collectPairKeys(less.Iter()))
+	require.Equal(t, []int{3, 2, 1, 0}, collectPairKeys(greater.Iter()))
+	require.Equal(t, collectPairKeys(tree.Iter()), collectPairKeys(less.Join(p, greater).Iter()))
+
+	union := treeFuncOfRange(0, 5).Union(treeFuncOfRange(3, 8), func(key int, a, b int) int { return a + b })
+	require.Equal(t, []int{7, 6, 5, 4, 3, 2, 1, 0}, collectPairKeys(union.Iter()))
+	require.Equal(t, 8, union.Find(4))
+	requireValidFuncNode(t, union.cmp, union.root)
+
+	var nilTree *TreeFunc[int, int]
+	require.True(t, nilTree.IsEmpty())
+	require.False(t, nilTree.Contains(1))
+	require.Nil(t, nilTree.Delete(1))
+	require.Panics(t, func() { nilTree.Update(1, 1) })
+}
+
+func TestTreeFuncTime(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tree := NewTreeFunc[time.Time, string](func(a, b time.Time) int {
+		if a.Before(b) {
+			return -1
+		}
+		if b.Before(a) {
+			return 1
+		}
+		return 0
+	})
+	tree = tree.Update(base.Add(time.Hour), "b").Update(base, "a").Update(base.Add(-time.Hour), "c")
+
+	var values []string
+	iter := tree.Iter()
+	for iter.Next() {
+		values = append(values, iter.Current().Value)
+	}
+	require.Equal(t, []string{"c", "a", "b"}, values)
+}
+
+func TestTreeFuncJSON(t *testing.T) {
+	tree := treeFuncOfRange(0, 3)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"0": 0, "1": 1, "2": 2}`, string(data))
+
+	decoded := NewTreeFunc[Int, int](func(a, b Int) int { return int(b - a) })
+	require.NoError(t, json.Unmarshal([]byte(`{"5": 1, "3": 2, "4": 3}`), decoded))
+	require.Equal(t, []Int{5, 4, 3}, collectPairKeys(decoded.Iter()))
+	require.Equal(t, 2, decoded.Find(3))
+
+	var noComparator TreeFunc[Int, int]
+	require.ErrorIs(t, json.Unmarshal(data, &noComparator), ErrNoComparator)
+}
+
+func TestTreeFuncFromSorted(t *testing.T) {
+	tree, err := TreeFuncFromSorted(descending, []Pair[int, int]{{3, 3}, {2, 2}, {1, 1}})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 2, 1}, collectPairKeys(tree.Iter()))
+
+	_, err = TreeFuncFromSorted(descending, []Pair[int, int]{{1, 1}, {2, 2}})
+	require.ErrorIs(t, err, ErrUnsorted)
+}
+
+func TestTreeFuncNavigation(t *testing.T) {
+	tree := treeFuncOfRange(0, 5)
+
+	p, ok := tree.Successor(3)
+	require.True(t, ok)
+	require.Equal(t, 2, p.Key)
+	p, ok = tree.Predecessor(3)
+	require.True(t, ok)
+	require.Equal(t, 4, p.Key)
+
+	p, rest, ok := tree.PopLeast()
+	require.True(t, ok)
+	require.Equal(t, 4, p.Key)
+	require.Equal(t, 4, rest.Size())
+	p, rest, ok = rest.PopMost()
+	require.True(t, ok)
+	require.Equal(t, 0, p.Key)
+	require.Equal(t, []int{3, 2, 1}, collectPairKeys(rest.Iter()))
+
+	s := setFuncOf(1, 2, 3)
+	e, rest2, ok := s.PopLeast()
+	require.True(t, ok)
+	require.Equal(t, 3, e)
+	e, ok = rest2.Successor(2)
+	require.True(t, ok)
+	require.Equal(t, 1, e)
+}
+
+func TestTreeFuncFilterMap(t *testing.T) {
+	tree := treeFuncOfRange(0, 10)
+	filtered := tree.Filter(func(key int, _ int) bool { return key%2 == 0 })
+	require.Equal(t, []int{8, 6, 4, 2, 0}, collectPairKeys(filtered.Iter()))
+
+	in, out := tree.Partition(func(key int, _ int) bool { return key < 3 })
+	require.Equal(t, []int{2, 1, 0}, collectPairKeys(in.Iter()))
+	require.Equal(t, 7, out.Size())
+
+	mapped := MapValuesFunc(tree, func(key int, value int) int { return value * 10 })
+	require.Equal(t, 50, mapped.Find(5))
+	require.Equal(t, []int{10, 9, 8}, collectPairKeys(mapped.Update(10, 100).IterLte(8)))
+
+	s := setFuncOf(1, 2, 3, 4).Filter(func(e int) bool { return e != 2 })
+	require.Equal(t, []int{4, 3, 1}, setFuncElems(s))
+}
@@ -3,6 +3,8 @@ package persistent
 import (
 	"encoding/json"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/constraints"
+	"strconv"
 	"testing"
 )
 
@@ -455,3 +457,662 @@ func TestGetKth(t *testing.T) {
 	require.False(t, ok)
 	require.Equal(t, 0, p.Key)
 }
+
+func requireValidTree[K constraints.Ordered, V any](t *testing.T, n *Tree[K, V]) {
+	t.Helper()
+	if n.IsEmpty() {
+		return
+	}
+	requireValidTree(t, n.left)
+	requireValidTree(t, n.right)
+	if !n.left.IsEmpty() {
+		require.Less(t, n.left.rightMost().key, n.key)
+	}
+	if !n.right.IsEmpty() {
+		require.Less(t, n.key, n.right.leftMost().key)
+	}
+	require.Equal(t, n.left.Size()+n.right.Size()+1, n.size)
+	require.Equal(t, max(n.left.Height(), n.right.Height())+1, n.height)
+	require.LessOrEqual(t, abs(n.balanceFactor()), 1)
+}
+
+func treeOfRange(lo, hi, step int) *Tree[int, int] {
+	var tree *Tree[int, int]
+	for i := lo; i < hi; i += step {
+		tree = tree.Update(i, i)
+	}
+	return tree
+}
+
+func treeKeys[K constraints.Ordered, V any](n *Tree[K, V]) []K {
+	var ret []K
+	iter := n.Iter()
+	for iter.Next() {
+		ret = append(ret, iter.Current().Key)
+	}
+	return ret
+}
+
+func TestSplit(t *testing.T) {
+	tree := treeOfRange(0, 100, 2)
+	for key := -1; key <= 100; key++ {
+		less, p, found, greater := tree.Split(key)
+		requireValidTree(t, less)
+		requireValidTree(t, greater)
+		require.Equal(t, key >= 0 && key < 100 && key%2 == 0, found)
+		if found {
+			require.Equal(t, key, p.Key)
+			require.Equal(t, key, p.Value)
+		}
+		for _, k := range treeKeys(less) {
+			require.Less(t, k, key)
+		}
+		for _, k := range treeKeys(greater) {
+			require.Greater(t, k, key)
+		}
+		total := less.Size() + greater.Size()
+		if found {
+			total++
+		}
+		require.Equal(t, tree.Size(), total)
+	}
+}
+
+func TestSplitEmpty(t *testing.T) {
+	var tree *Tree[int, int]
+	less, _, found, greater := tree.Split(2)
+	require.True(t, less.IsEmpty())
+	require.False(t, found)
+	require.True(t, greater.IsEmpty())
+}
+
+func TestJoin(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1, 50}, {50, 1}, {30, 30}, {100, 3}} {
+		left := treeOfRange(0, sizes[0], 1)
+		right := treeOfRange(sizes[0]+1, sizes[0]+1+sizes[1], 1)
+		joined := left.Join(Pair[int, int]{Key: sizes[0], Value: sizes[0]}, right)
+		requireValidTree(t, joined)
+		require.Equal(t, sizes[0]+sizes[1]+1, joined.Size())
+		for i, k := range treeKeys(joined) {
+			require.Equal(t, i, k)
+		}
+	}
+}
+
+func TestJoin2(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1, 50}, {50, 1}, {30, 30}, {100, 3}} {
+		left := treeOfRange(0, sizes[0], 1)
+		right := treeOfRange(sizes[0], sizes[0]+sizes[1], 1)
+		joined := left.Join2(right)
+		requireValidTree(t, joined)
+		require.Equal(t, sizes[0]+sizes[1], joined.Size())
+		for i, k := range treeKeys(joined) {
+			require.Equal(t, i, k)
+		}
+	}
+}
+
+func TestSplitJoinRoundTrip(t *testing.T) {
+	tree := treeOfRange(0, 200, 1)
+	less, p, found, greater := tree.Split(77)
+	require.True(t, found)
+	joined := less.Join(p, greater)
+	requireValidTree(t, joined)
+	require.Equal(t, treeKeys(tree), treeKeys(joined))
+	requireValidTree(t, less.Join2(greater))
+	require.Equal(t, 199, less.Join2(greater).Size())
+}
+
+func TestJoinOverlapPanics(t *testing.T) {
+	left := treeOfRange(0, 10, 1)
+	right := treeOfRange(5, 15, 1)
+	require.Panics(t, func() { left.Join(Pair[int, int]{Key: 9}, nil) })
+	require.Panics(t, func() { EmptyTree[int, int]().Join(Pair[int, int]{Key: 5}, right) })
+	require.Panics(t, func() { left.Join2(right) })
+}
+
+func TestDeleteRebalance(t *testing.T) {
+	tree := treeOfRange(0, 256, 1)
+	for i := 0; i < 256; i++ {
+		tree = tree.Delete((i * 37) % 256)
+		requireValidTree(t, tree)
+	}
+}
+
+func treeMap[K constraints.Ordered, V any](n *Tree[K, V]) map[K]V {
+	ret := make(map[K]V)
+	iter := n.Iter()
+	for iter.Next() {
+		ret[iter.Current().Key] = iter.Current().Value
+	}
+	return ret
+}
+
+func TestUnion(t *testing.T) {
+	a := treeOfRange(0, 100, 2)
+	b := treeOfRange(0, 150, 3)
+	u := a.Union(b, func(key int, x, y int) int { return x + y })
+	requireValidTree(t, u)
+
+	expected := treeMap(a)
+	for k, v := range treeMap(b) {
+		expected[k] += v
+	}
+	require.Equal(t, expected, treeMap(u))
+}
+
+func TestUnionNilMerge(t *testing.T) {
+	a := EmptyTree[int, string]().Update(1, "a").Update(2, "a")
+	b := EmptyTree[int, string]().Update(2, "b").Update(3, "b")
+	require.Equal(t, map[int]string{1: "a", 2: "b", 3: "b"}, treeMap(a.Union(b, nil)))
+}
+
+func TestUnionShared(t *testing.T) {
+	a := treeOfRange(0, 100, 1)
+	fail := func(key int, x, y int) int {
+		require.Fail(t, "merge called for a shared subtree")
+		return 0
+	}
+	require.True(t, a == a.Union(a, fail))
+	require.True(t, a == a.Union(nil, fail))
+	require.True(t, a == EmptyTree[int, int]().Union(a, fail))
+
+	b := a.Update(1000, 1000)
+	calls := 0
+	u := a.Union(b, func(key int, x, y int) int {
+		calls++
+		return x
+	})
+	requireValidTree(t, u)
+	require.Equal(t, 101, u.Size())
+	require.Less(t, calls, 20)
+}
+
+func TestIntersection(t *testing.T) {
+	a := treeOfRange(0, 100, 2)
+	b := treeOfRange(0, 150, 3)
+	i := a.Intersection(b, func(key int, x, y int) int { return x * y })
+	requireValidTree(t, i)
+
+	expected := make(map[int]int)
+	for k := 0; k < 100; k += 6 {
+		expected[k] = k * k
+	}
+	require.Equal(t, expected, treeMap(i))
+	require.True(t, a.Intersection(nil, nil).IsEmpty())
+	require.True(t, a == a.Intersection(a, nil))
+}
+
+func TestDifference(t *testing.T) {
+	a := treeOfRange(0, 100, 2)
+	b := treeOfRange(0, 150, 3)
+	d := a.Difference(b)
+	requireValidTree(t, d)
+
+	expected := make(map[int]int)
+	for k := 0; k < 100; k += 2 {
+		if k%3 != 0 {
+			expected[k] = k
+		}
+	}
+	require.Equal(t, expected, treeMap(d))
+	require.True(t, a.Difference(a).IsEmpty())
+	require.True(t, a == a.Difference(treeOfRange(1, 100, 2)))
+}
+
+func TestLeftOuterJoin(t *testing.T) {
+	a := treeOfRange(0, 20, 2)
+	b := EmptyTree[int, string]().Update(4, "four").Update(5, "five").Update(30, "thirty")
+	j := LeftOuterJoin(a, b)
+	requireValidTree(t, j)
+	require.Equal(t, a.Size(), j.Size())
+	require.Equal(t, Joined[int, string]{Left: Some(4), Right: Some("four")}, j.Find(4))
+	require.Equal(t, Joined[int, string]{Left: Some(6)}, j.Find(6))
+	require.False(t, j.Contains(5))
+	require.True(t, LeftOuterJoin(EmptyTree[int, int](), b).IsEmpty())
+}
+
+func TestFullOuterJoin(t *testing.T) {
+	a := treeOfRange(0, 20, 2)
+	b := EmptyTree[int, string]().Update(4, "four").Update(5, "five").Update(30, "thirty")
+	j := FullOuterJoin(a, b)
+	requireValidTree(t, j)
+	require.Equal(t, a.Size()+2, j.Size())
+	require.Equal(t, Joined[int, string]{Left: Some(4), Right: Some("four")}, j.Find(4))
+	require.Equal(t, Joined[int, string]{Right: Some("five")}, j.Find(5))
+	require.Equal(t, Joined[int, string]{Left: Some(6)}, j.Find(6))
+	require.Equal(t, Joined[int, string]{Right: Some("thirty")}, j.Find(30))
+}
+
+func collectKeys[K any, V any](iter Iterator[Pair[K, V]]) []K {
+	var ret []K
+	for iter.Next() {
+		ret = append(ret, iter.Current().Key)
+	}
+	return ret
+}
+
+func TestIterRange(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	require.Equal(t, []int{4, 6, 8}, collectKeys(tree.IterRange(4, 8, Closed)))
+	require.Equal(t, []int{6, 8}, collectKeys(tree.IterRange(4, 8, OpenLow)))
+	require.Equal(t, []int{4, 6}, collectKeys(tree.IterRange(4, 8, OpenHigh)))
+	require.Equal(t, []int{6}, collectKeys(tree.IterRange(4, 8, Open)))
+	require.Equal(t, []int{4, 6, 8}, collectKeys(tree.IterRange(3, 9, Open)))
+	require.Equal(t, []int{0, 2}, collectKeys(tree.IterRange(-10, 2, Closed)))
+	require.Empty(t, collectKeys(tree.IterRange(8, 4, Closed)))
+	require.Empty(t, collectKeys(tree.IterRange(20, 40, Closed)))
+	require.Empty(t, collectKeys(EmptyTree[int, int]().IterRange(0, 10, Closed)))
+}
+
+func TestIterRangeDesc(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	require.Equal(t, []int{8, 6, 4}, collectKeys(tree.IterRangeDesc(4, 8, Closed)))
+	require.Equal(t, []int{8, 6}, collectKeys(tree.IterRangeDesc(4, 8, OpenLow)))
+	require.Equal(t, []int{6, 4}, collectKeys(tree.IterRangeDesc(4, 8, OpenHigh)))
+	require.Equal(t, []int{6}, collectKeys(tree.IterRangeDesc(4, 8, Open)))
+	for _, bounds := range []Bounds{Closed, OpenLow, OpenHigh, Open} {
+		require.Equal(t, []int{8, 6, 4}, collectKeys(tree.IterRangeDesc(3, 9, bounds)))
+	}
+	require.Equal(t, []int{2, 0}, collectKeys(tree.IterRangeDesc(-10, 2, Closed)))
+	require.Equal(t, []int{18, 16}, collectKeys(tree.IterRangeDesc(16, 40, Closed)))
+	require.Equal(t, []int{4}, collectKeys(tree.IterRangeDesc(4, 4, Closed)))
+	require.Empty(t, collectKeys(tree.IterRangeDesc(4, 4, OpenLow)))
+	require.Empty(t, collectKeys(tree.IterRangeDesc(4, 4, OpenHigh)))
+	require.Empty(t, collectKeys(tree.IterRangeDesc(8, 4, Closed)))
+	require.Empty(t, collectKeys(tree.IterRangeDesc(20, 40, Closed)))
+	require.Empty(t, collectKeys(EmptyTree[int, int]().IterRangeDesc(0, 10, Closed)))
+}
+
+func TestIterLtLte(t *testing.T) {
+	tree := treeOfRange(0, 10, 2)
+	require.Equal(t, []int{0, 2}, collectKeys(tree.IterLt(4)))
+	require.Equal(t, []int{0, 2, 4}, collectKeys(tree.IterLte(4)))
+	require.Equal(t, []int{0, 2, 4}, collectKeys(tree.IterLt(5)))
+	require.Empty(t, collectKeys(tree.IterLt(0)))
+	require.Equal(t, []int{0, 2, 4, 6, 8}, collectKeys(tree.IterLte(100)))
+}
+
+func TestIterDesc(t *testing.T) {
+	tree := treeOfRange(0, 10, 2)
+	require.Equal(t, []int{8, 6, 4, 2, 0}, collectKeys(tree.IterDesc()))
+	require.Equal(t, []int{4, 2, 0}, collectKeys(tree.IterDescFrom(4)))
+	require.Equal(t, []int{4, 2, 0}, collectKeys(tree.IterDescFrom(5)))
+	require.Equal(t, []int{8, 6, 4, 2, 0}, collectKeys(tree.IterDescFrom(100)))
+	require.Empty(t, collectKeys(tree.IterDescFrom(-1)))
+	require.Empty(t, collectKeys(EmptyTree[int, int]().IterDesc()))
+}
+
+func TestIterExhausted(t *testing.T) {
+	iter := treeOfRange(0, 3, 1).IterLt(2)
+	require.True(t, iter.Next())
+	require.True(t, iter.Next())
+	require.False(t, iter.Next())
+	require.False(t, iter.Next())
+	require.Panics(t, func() { iter.Current() })
+}
+
+func TestRank(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	require.Equal(t, 0, tree.Rank(-5))
+	require.Equal(t, 0, tree.Rank(0))
+	require.Equal(t, 1, tree.Rank(1))
+	require.Equal(t, 3, tree.Rank(6))
+	require.Equal(t, 10, tree.Rank(100))
+	require.Equal(t, 0, EmptyTree[int, int]().Rank(3))
+	for i := 0; i < 10; i++ {
+		p, _ := tree.GetKthElement(i)
+		require.Equal(t, i, tree.Rank(p.Key))
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	require.Equal(t, 3, tree.CountRange(4, 8, Closed))
+	require.Equal(t, 2, tree.CountRange(4, 8, OpenLow))
+	require.Equal(t, 2, tree.CountRange(4, 8, OpenHigh))
+	require.Equal(t, 1, tree.CountRange(4, 8, Open))
+	require.Equal(t, 2, tree.CountRange(3, 7, Closed))
+	require.Equal(t, 10, tree.CountRange(-100, 100, Open))
+	require.Equal(t, 0, tree.CountRange(8, 4, Closed))
+	require.Equal(t, 0, tree.CountRange(4, 4, OpenHigh))
+	require.Equal(t, 1, tree.CountRange(4, 4, Closed))
+}
+
+func TestMedianPercentile(t *testing.T) {
+	tree := treeOfRange(1, 11, 1)
+	m, ok := tree.Median()
+	require.True(t, ok)
+	require.Equal(t, 5, m.Key)
+
+	m, _ = tree.Update(11, 11).Median()
+	require.Equal(t, 6, m.Key)
+
+	p, ok := tree.Percentile(90)
+	require.True(t, ok)
+	require.Equal(t, 9, p.Key)
+	p, _ = tree.Percentile(91)
+	require.Equal(t, 10, p.Key)
+	p, _ = tree.Percentile(0)
+	require.Equal(t, 1, p.Key)
+	p, _ = tree.Percentile(100)
+	require.Equal(t, 10, p.Key)
+
+	_, ok = tree.Percentile(101)
+	require.False(t, ok)
+	_, ok = tree.Percentile(-1)
+	require.False(t, ok)
+	_, ok = EmptyTree[int, int]().Median()
+	require.False(t, ok)
+	_, ok = EmptyTree[int, int]().Percentile(50)
+	require.False(t, ok)
+}
+
+func TestPercentileBoundaries(t *testing.T) {
+	// Each case's rank p*n/100 is a whole number, or just above one, where dividing by 100 first used to round up.
+	cases := []struct {
+		p        float64
+		n        int
+		expected int
+	}{
+		{28, 25, 7},
+		{7, 100, 7},
+		{14, 50, 7},
+		{56, 25, 14},
+		{57, 100, 57},
+		{29, 100, 29},
+		{58, 50, 29},
+		{35, 20, 7},
+		{70, 10, 7},
+		{1, 100, 1},
+		{1, 101, 2},
+		{99, 100, 99},
+		{100, 7, 7},
+		{0, 7, 1},
+		{0.5, 1000, 5},
+		{12.5, 8, 1},
+		{12.6, 8, 2},
+	}
+	for _, c := range cases {
+		tree := treeOfRange(1, c.n+1, 1)
+		p, ok := tree.Percentile(c.p)
+		require.True(t, ok)
+		require.Equal(t, c.expected, p.Key, "p=%v n=%v", c.p, c.n)
+	}
+}
+
+func TestPercentileIndexWholePercentiles(t *testing.T) {
+	for n := 1; n <= 1000; n++ {
+		for p := 0; p <= 100; p++ {
+			expected := (p*n+99)/100 - 1
+			if expected < 0 {
+				expected = 0
+			}
+			require.Equal(t, expected, percentileIndex(float64(p), n), "p=%v n=%v", p, n)
+		}
+	}
+}
+
+func TestDeleteAt(t *testing.T) {
+	tree := treeOfRange(0, 50, 1)
+	for i := 0; i < 50; i += 7 {
+		d := tree.DeleteAt(i)
+		requireValidTree(t, d)
+		require.Equal(t, 49, d.Size())
+		require.False(t, d.Contains(i))
+	}
+	require.True(t, tree == tree.DeleteAt(-1))
+	require.True(t, tree == tree.DeleteAt(50))
+	require.True(t, EmptyTree[int, int]().DeleteAt(0).IsEmpty())
+}
+
+func TestSplitAt(t *testing.T) {
+	tree := treeOfRange(0, 50, 1)
+	for i := -1; i <= 51; i++ {
+		left, right := tree.SplitAt(i)
+		requireValidTree(t, left)
+		requireValidTree(t, right)
+		expected := i
+		if expected < 0 {
+			expected = 0
+		} else if expected > 50 {
+			expected = 50
+		}
+		require.Equal(t, expected, left.Size())
+		require.Equal(t, 50-expected, right.Size())
+		if !right.IsEmpty() {
+			least, _ := right.Least()
+			require.Equal(t, expected, least.Key)
+		}
+	}
+}
+
+func TestSliceByIndex(t *testing.T) {
+	tree := treeOfRange(0, 50, 2)
+	slice := tree.SliceByIndex(3, 6)
+	requireValidTree(t, slice)
+	require.Equal(t, []int{6, 8, 10}, treeKeys(slice))
+	require.True(t, tree.SliceByIndex(6, 3).IsEmpty())
+	require.Equal(t, treeKeys(tree), treeKeys(tree.SliceByIndex(-10, 100)))
+}
+
+func TestIterFromIndex(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	require.Equal(t, []int{14, 16, 18}, collectKeys(tree.IterFromIndex(7)))
+	require.Equal(t, treeKeys(tree), collectKeys(tree.IterFromIndex(-3)))
+	require.Empty(t, collectKeys(tree.IterFromIndex(10)))
+	for i := 0; i < 10; i++ {
+		iter := tree.IterFromIndex(i)
+		require.True(t, iter.Next())
+		require.Equal(t, 2*i, iter.Current().Key)
+	}
+}
+
+func TestTreeFromSorted(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 7, 8, 100, 1000} {
+		pairs := make([]Pair[int, int], size)
+		for i := range pairs {
+			pairs[i] = Pair[int, int]{Key: i * 2, Value: i}
+		}
+		tree, err := TreeFromSorted(pairs)
+		require.NoError(t, err)
+		requireValidTree(t, tree)
+		require.Equal(t, size, tree.Size())
+		for i := 0; i < size; i++ {
+			require.Equal(t, i, tree.Find(i*2))
+		}
+	}
+}
+
+func TestTreeFromSortedErrors(t *testing.T) {
+	_, err := TreeFromSorted([]Pair[int, int]{{Key: 1}, {Key: 3}, {Key: 2}})
+	require.ErrorIs(t, err, ErrUnsorted)
+	_, err = TreeFromSorted([]Pair[int, int]{{Key: 1}, {Key: 2}, {Key: 2}})
+	require.ErrorIs(t, err, ErrDuplicateKey)
+}
+
+func TestTreeFromSortedIterator(t *testing.T) {
+	source := treeOfRange(0, 50, 1)
+	tree, err := TreeFromSortedIterator(source.Iter())
+	require.NoError(t, err)
+	requireValidTree(t, tree)
+	require.Equal(t, treeKeys(source), treeKeys(tree))
+
+	_, err = TreeFromSortedIterator(source.IterDesc())
+	require.ErrorIs(t, err, ErrUnsorted)
+}
+
+func TestUnmarshalJsonEmpty(t *testing.T) {
+	tree := treeOfRange(0, 10, 1)
+	err := json.Unmarshal([]byte("{}"), tree)
+	require.NoError(t, err)
+	require.True(t, tree.IsEmpty())
+}
+
+func TestUnmarshalJsonBalanced(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		m[i] = i
+	}
+	serialized, err := json.Marshal(m)
+	require.NoError(t, err)
+	var tree *Tree[int, int]
+	require.NoError(t, json.Unmarshal(serialized, &tree))
+	requireValidTree(t, tree)
+	require.Equal(t, 10, tree.Height())
+}
+
+func TestAlter(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+	increment := func(old int, found bool) (int, bool) {
+		return old + 1, true
+	}
+	remove := func(old int, found bool) (int, bool) {
+		return old, false
+	}
+	eq := func(a, b int) bool {
+		return a == b
+	}
+
+	inserted := tree.Alter(5, increment, eq)
+	requireValidTree(t, inserted)
+	require.Equal(t, 1, inserted.Find(5))
+	require.Equal(t, 11, inserted.Size())
+
+	updated := tree.Alter(4, increment, eq)
+	requireValidTree(t, updated)
+	require.Equal(t, 5, updated.Find(4))
+	require.Equal(t, 4, tree.Find(4))
+
+	deleted := tree.Alter(4, remove, eq)
+	requireValidTree(t, deleted)
+	require.False(t, deleted.Contains(4))
+	require.Equal(t, 9, deleted.Size())
+
+	require.True(t, tree == tree.Alter(5, remove, eq))
+	require.True(t, tree == tree.Alter(4, func(old int, found bool) (int, bool) {
+		require.True(t, found)
+		return old, true
+	}, eq))
+	require.False(t, tree == tree.Alter(4, func(old int, found bool) (int, bool) {
+		return old, true
+	}, nil))
+}
+
+func TestAlterEmpty(t *testing.T) {
+	var tree *Tree[int, int]
+	require.True(t, tree.Alter(1, func(old int, found bool) (int, bool) {
+		require.False(t, found)
+		return 0, false
+	}, nil).IsEmpty())
+	inserted := tree.Alter(1, func(old int, found bool) (int, bool) {
+		return 7, true
+	}, nil)
+	require.Equal(t, 7, inserted.Find(1))
+}
+
+func TestMostFound(t *testing.T) {
+	p, found := treeOfRange(0, 10, 1).Most()
+	require.True(t, found)
+	require.Equal(t, 9, p.Key)
+}
+
+func TestSuccessorPredecessor(t *testing.T) {
+	tree := treeOfRange(0, 20, 2)
+
+	p, ok := tree.Successor(4)
+	require.True(t, ok)
+	require.Equal(t, 6, p.Key)
+	p, ok = tree.Successor(5)
+	require.True(t, ok)
+	require.Equal(t, 6, p.Key)
+	_, ok = tree.Successor(18)
+	require.False(t, ok)
+
+	p, ok = tree.Predecessor(4)
+	require.True(t, ok)
+	require.Equal(t, 2, p.Key)
+	p, ok = tree.Predecessor(100)
+	require.True(t, ok)
+	require.Equal(t, 18, p.Key)
+	_, ok = tree.Predecessor(0)
+	require.False(t, ok)
+
+	var empty *Tree[int, int]
+	_, ok = empty.Successor(1)
+	require.False(t, ok)
+}
+
+func TestPopLeastMost(t *testing.T) {
+	tree := treeOfRange(0, 50, 1)
+	var popped []int
+	for !tree.IsEmpty() {
+		var p Pair[int, int]
+		var ok bool
+		p, tree, ok = tree.PopLeast()
+		require.True(t, ok)
+		requireValidTree(t, tree)
+		popped = append(popped, p.Key)
+	}
+	require.Equal(t, treeKeys(treeOfRange(0, 50, 1)), popped)
+
+	tree = treeOfRange(0, 50, 1)
+	p, rest, ok := tree.PopMost()
+	require.True(t, ok)
+	require.Equal(t, 49, p.Key)
+	requireValidTree(t, rest)
+	require.Equal(t, 49, rest.Size())
+	require.Equal(t, 50, tree.Size())
+
+	_, rest, ok = rest.Delete(rest.Key()).PopMost()
+	require.True(t, ok)
+	requireValidTree(t, rest)
+
+	_, rest, ok = EmptyTree[int, int]().PopLeast()
+	require.False(t, ok)
+	require.Nil(t, rest)
+}
+
+func TestMapValues(t *testing.T) {
+	tree := treeOfRange(0, 100, 1)
+	mapped := MapValues(tree, func(key int, value int) string {
+		return strconv.Itoa(key + value)
+	})
+	requireValidTree(t, mapped)
+	require.Equal(t, tree.Height(), mapped.Height())
+	require.Equal(t, "84", mapped.Find(42))
+	require.Nil(t, MapValues(EmptyTree[int, int](), func(int, int) int { return 0 }))
+}
+
+func TestFilterPartition(t *testing.T) {
+	tree := treeOfRange(0, 200, 1)
+	even := func(key int, _ int) bool { return key%2 == 0 }
+
+	var visited []int
+	filtered := tree.Filter(func(key int, value int) bool {
+		visited = append(visited, key)
+		return even(key, value)
+	})
+	requireValidTree(t, filtered)
+	require.Equal(t, treeKeys(tree), visited)
+	require.Equal(t, treeKeys(treeOfRange(0, 200, 2)), treeKeys(filtered))
+
+	require.Same(t, tree, tree.Filter(func(int, int) bool { return true }))
+	require.True(t, tree.Filter(func(int, int) bool { return false }).IsEmpty())
+
+	in, out := tree.Partition(even)
+	requireValidTree(t, in)
+	requireValidTree(t, out)
+	require.Equal(t, treeKeys(treeOfRange(0, 200, 2)), treeKeys(in))
+	require.Equal(t, treeKeys(treeOfRange(1, 200, 2)), treeKeys(out))
+
+	in, out = tree.Partition(func(key int, _ int) bool { return key < 150 })
+	requireValidTree(t, in)
+	requireValidTree(t, out)
+	require.Equal(t, 150, in.Size())
+	require.Equal(t, 50, out.Size())
+
+	in, out = tree.Partition(func(int, int) bool { return false })
+	require.True(t, in.IsEmpty())
+	require.Same(t, tree, out)
+}